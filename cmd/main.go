@@ -1,6 +1,7 @@
 package main
 
 import (
+	"goredis-lite/internal/core"
 	"goredis-lite/internal/server"
 	"log"
 	"net/http"
@@ -19,6 +20,10 @@ func main() {
 
 	//go server.RunIoMultiplexingServer(&wg) // single-threaded
 	s := server.NewServer()
+	// load the AOF or snapshot into the workers before accepting connections
+	if err := core.LoadDataFromDisk(); err != nil {
+		log.Fatal("error loading data from disk: ", err)
+	}
 	// go s.StartSingleListener(&wg)
 	go s.StartMultiListeners(&wg)
 	go server.WaitForSignal(&wg, signals)
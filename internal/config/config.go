@@ -18,3 +18,65 @@ var (
 )
 
 var ListenerNumber int = 2
+
+// SaveRule triggers a background snapshot once Changes writes happened and
+// at least Seconds elapsed since the last one, like "save <seconds> <changes>"
+type SaveRule struct {
+	Seconds int
+	Changes int
+}
+
+var (
+	Dir         = "."
+	RdbFileName = "dump.rdb"
+	SaveRules   = []SaveRule{{3600, 1}, {300, 100}, {60, 10000}} // empty disables automatic snapshots
+)
+
+var (
+	AppendOnly     = false
+	AppendFsync    = "everysec"       // always, everysec or no
+	AppendDirName  = "appendonlydir"  // under Dir
+	AppendFileName = "appendonly.aof" // the segment of partition N is <AppendFileName>.N
+)
+
+// A hash is kept as a compact list of pairs until it holds more than
+// HashMaxListpackEntries fields or a field or value longer than
+// HashMaxListpackValue bytes, like hash-max-listpack-* in redis.conf
+var (
+	HashMaxListpackEntries = 128
+	HashMaxListpackValue   = 64
+)
+
+// A list is split in nodes of at most ListMaxListpackSize elements, like a
+// positive list-max-listpack-size in redis.conf
+var ListMaxListpackSize = 128
+
+// A set of integers is kept as a sorted array until it holds more than
+// SetMaxIntsetEntries members, other sets as a compact list until they hold
+// more than SetMaxListpackEntries members or a member longer than
+// SetMaxListpackValue bytes, like set-max-* in redis.conf
+var (
+	SetMaxIntsetEntries   = 512
+	SetMaxListpackEntries = 128
+	SetMaxListpackValue   = 64
+)
+
+// A string can't grow past ProtoMaxBulkLen bytes, like proto-max-bulk-len in
+// redis.conf
+var ProtoMaxBulkLen = 512 * 1024 * 1024
+
+// A HyperLogLog stays sparse until its encoding would take more than
+// HLLSparseMaxBytes, like hll-sparse-max-bytes in redis.conf
+var HLLSparseMaxBytes = 3000
+
+// BF.SCANDUMP returns the bit arrays of a bloom filter in chunks of up to
+// BfScanDumpChunkSize bytes
+var BfScanDumpChunkSize = 16 * 1024 * 1024
+
+// A sorted set is kept as a compact sorted list until it holds more than
+// ZSetMaxListpackEntries members or a member longer than ZSetMaxListpackValue
+// bytes, like zset-max-listpack-* in redis.conf
+var (
+	ZSetMaxListpackEntries = 128
+	ZSetMaxListpackValue   = 64
+)
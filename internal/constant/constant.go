@@ -14,12 +14,28 @@ var (
 	ActiveExpireThreshold        = 0.25                  // https://engineering.grab.com/a-key-expired-in-redis-you-wont-believe-what-happened-next
 	MaxActiveExpireExecutionTime = 25 * time.Millisecond // https://groups.google.com/g/redis-db/c/tF1cIg-bXS0
 	IOMultiplexerTimeout         = 50 * time.Millisecond
-	DefaultBPlusTreeDegree       = 64 // https://timmastny.com/blog/tuning-b-plus-trees/
+	ReadBufferSize               = 16 * 1024   // bytes read from a socket per event
+	MaxInlineSize                = 64 * 1024   // longest inline command line accepted
+	MaxMultibulkLen              = 1024 * 1024 // most elements an aggregate frame may announce
+	MaxRandomCount               = 1024 * 1024 // most picks a negative count of ZRANDMEMBER, HRANDFIELD or SRANDMEMBER asks for
+	DefaultBPlusTreeDegree       = 64          // https://timmastny.com/blog/tuning-b-plus-trees/
 )
 
+// ServerVersion is the Redis version whose behaviour the server follows,
+// reported to clients by HELLO
+const ServerVersion = "7.2.0"
+
 const (
 	BfDefaultInitCapacity = 100
 	BfDefaultErrRate      = 0.01
+	BfDefaultExpansion    = 2 // how much larger than the previous one a new layer is
+)
+
+const (
+	CfDefaultCapacity      = 1024
+	CfDefaultBucketSize    = 2  // fingerprints per bucket
+	CfDefaultMaxIterations = 20 // fingerprints moved to make room for a new one before expanding
+	CfDefaultExpansion     = 1  // how much larger than the previous one a new sub-filter is
 )
 
 const (
@@ -27,3 +43,8 @@ const (
 	ServerStatusBusy         = 2
 	ServerStatusShuttingDown = 3
 )
+
+const (
+	SaveRulesCheckFrequency = time.Second     // how often automatic snapshot rules are checked
+	BgSaveRetryDelay        = 5 * time.Second // wait before retrying a failed automatic snapshot
+)
@@ -0,0 +1,374 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goredis-lite/internal/config"
+)
+
+// aofSegment is the append only file of one partition: the RDB dump written
+// by the last rewrite followed, in RESP form, by every write command executed
+// on the partition since
+type aofSegment struct {
+	file       *os.File
+	rewriteBuf []byte // commands executed while a rewrite runs, nil otherwise
+	lastFsync  time.Time
+	fsyncing   int32 // a background fsync is running
+}
+
+// aofRewriteState guards against concurrent rewrites
+type aofRewriteState struct {
+	mu      sync.Mutex
+	running bool
+	wg      sync.WaitGroup // running background rewrite
+}
+
+var aofRewrite = &aofRewriteState{}
+
+var errRewriteInProgress = errors.New("ERR Background append only file rewriting already in progress")
+
+func aofDir() string {
+	return filepath.Join(config.Dir, config.AppendDirName)
+}
+
+// aofSegmentPath returns the segment of partition id, <AppendFileName>.<id>
+func aofSegmentPath(id int) string {
+	return filepath.Join(aofDir(), fmt.Sprintf("%s.%d", config.AppendFileName, id))
+}
+
+// aofSegmentIDs returns the partition ids of the segments on disk, sorted
+func aofSegmentIDs() ([]int, error) {
+	entries, err := os.ReadDir(aofDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	prefix := config.AppendFileName + "."
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimPrefix(e.Name(), prefix)); err == nil && id >= 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func openAOFSegment(path string) (*aofSegment, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &aofSegment{file: f, lastFsync: time.Now()}, nil
+}
+
+func (a *aofSegment) write(data []byte) {
+	if _, err := a.file.Write(data); err != nil {
+		log.Println("error writing to the AOF:", err)
+	}
+	if config.AppendFsync == "always" {
+		if err := a.file.Sync(); err != nil {
+			log.Println("error fsyncing the AOF:", err)
+		}
+		a.lastFsync = time.Now()
+	}
+	if a.rewriteBuf != nil {
+		a.rewriteBuf = append(a.rewriteBuf, data...)
+	}
+}
+
+// expireAt returns the TTL of the first key of a command, 0 without TTL
+func (s *Storage) expireAt(spec *CommandSpec, args []string) (string, int64) {
+	indexes := spec.KeyIndexes(args)
+	if len(indexes) == 0 {
+		return "", 0
+	}
+	key := args[indexes[0]]
+	exp, _ := s.dictStore.GetExpiry(key)
+	return key, exp
+}
+
+// rewriteCommand logs argv in the AOF instead of the command being executed
+// on s, for commands like SPOP whose replay would not have the same effect
+func (s *Storage) rewriteCommand(argv ...string) {
+	s.rewritten = &Command{Cmd: argv[0], Args: argv[1:]}
+}
+
+// feedAOF appends cmd, executed on s, to the segment of s. A TTL set by cmd
+// on key is logged as an absolute PEXPIREAT, so replaying the file later
+// does not extend it.
+func (s *Storage) feedAOF(cmd *Command, key string, expireAtBefore int64) {
+	buf := Encode(append([]string{cmd.Cmd}, cmd.Args...), false)
+	if key != "" {
+		if exp, ok := s.dictStore.GetExpiry(key); ok && exp != expireAtBefore {
+			buf = append(buf, Encode([]string{"PEXPIREAT", key, strconv.FormatInt(exp, 10)}, false)...)
+		}
+	}
+	s.aof.write(buf)
+}
+
+// fsyncAOF fsyncs the segment of s on another goroutine once per second when
+// appendfsync is everysec
+func (s *Storage) fsyncAOF() {
+	if s.aof == nil || config.AppendFsync != "everysec" || time.Since(s.aof.lastFsync) < time.Second {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&s.aof.fsyncing, 0, 1) {
+		return
+	}
+	s.aof.lastFsync = time.Now()
+	a, f := s.aof, s.aof.file
+	go func() {
+		defer atomic.StoreInt32(&a.fsyncing, 0)
+		if err := f.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+			log.Println("error fsyncing the AOF:", err)
+		}
+	}()
+}
+
+// FsyncAOF applies appendfsync everysec to defaultStorage
+func FsyncAOF() {
+	defaultStorage.fsyncAOF()
+}
+
+// swapAOF finishes the rewrite of the segment of s: the commands executed
+// since the dump are appended to tmp, which then replaces the segment
+func (s *Storage) swapAOF(tmp *os.File, path string) error {
+	if s.aof != nil {
+		if _, err := tmp.Write(s.aof.rewriteBuf); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if s.aof == nil {
+		return tmp.Close()
+	}
+	s.aof.file.Close()
+	s.aof.file = tmp
+	s.aof.rewriteBuf = nil
+	return nil
+}
+
+// createAOFBase writes dump as the RDB preamble of a new segment
+func createAOFBase(id int, dump []byte) (*os.File, error) {
+	path := filepath.Join(aofDir(), fmt.Sprintf("temp-rewriteaof-%d.aof", id))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Write(encodeRDB([][]byte{dump})); err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return f, nil
+}
+
+// rewriteAOF replaces every segment with the dump of its partition. Only
+// the dump blocks the partitions; with background set the files are
+// written on another goroutine.
+func rewriteAOF(background bool) error {
+	aofRewrite.mu.Lock()
+	if aofRewrite.running {
+		aofRewrite.mu.Unlock()
+		return errRewriteInProgress
+	}
+	aofRewrite.running = true
+	aofRewrite.mu.Unlock()
+
+	dumps := make([][]byte, partitions.Count())
+	runAll(func(id int, s *Storage) {
+		dumps[id] = s.dumpRDB()
+		if s.aof != nil {
+			s.aof.rewriteBuf = []byte{}
+		}
+	})
+	if !background {
+		return finishRewriteAOF(dumps)
+	}
+	aofRewrite.wg.Add(1)
+	go func() {
+		defer aofRewrite.wg.Done()
+		if err := finishRewriteAOF(dumps); err != nil {
+			log.Println("background AOF rewrite error:", err)
+		} else {
+			log.Println("background AOF rewrite terminated with success")
+		}
+	}()
+	return nil
+}
+
+func finishRewriteAOF(dumps [][]byte) error {
+	defer func() {
+		aofRewrite.mu.Lock()
+		aofRewrite.running = false
+		aofRewrite.mu.Unlock()
+	}()
+
+	tmps := make([]*os.File, len(dumps))
+	failed := os.MkdirAll(aofDir(), 0755)
+	for id := 0; id < len(dumps) && failed == nil; id++ {
+		tmps[id], failed = createAOFBase(id, dumps[id])
+	}
+	// the segments are only replaced once every new one is written
+	errs := make([]error, len(dumps))
+	runAll(func(id int, s *Storage) {
+		if failed == nil {
+			errs[id] = s.swapAOF(tmps[id], aofSegmentPath(id))
+		}
+		if (failed != nil || errs[id] != nil) && tmps[id] != nil {
+			tmps[id].Close()
+			os.Remove(tmps[id].Name())
+		}
+		if s.aof != nil {
+			s.aof.rewriteBuf = nil
+		}
+	})
+	if failed != nil {
+		return failed
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// segments of partitions that no longer exist are now in the others
+	ids, err := aofSegmentIDs()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id >= len(dumps) {
+			os.Remove(aofSegmentPath(id))
+		}
+	}
+	return nil
+}
+
+// BackgroundRewriteAOF compacts every segment into the dump of its partition
+func BackgroundRewriteAOF() error {
+	return rewriteAOF(true)
+}
+
+// replayAOFSegment loads the RDB preamble of the segment at path, then
+// executes its commands as client c. A command cut by a crash at the end of
+// the file is dropped and the file truncated before it.
+func replayAOFSegment(path string, c *Client) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	size := len(data)
+	if bytes.HasPrefix(data, []byte(rdbMagic)) {
+		var entries []*rdbEntry
+		data, err = decodeRDB(data, func(e *rdbEntry) {
+			entries = append(entries, e)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		restoreAll(entries)
+	}
+
+	reader := NewRespReader()
+	reader.Feed(data)
+	cmds, err := reader.ReadCommands()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, cmd := range cmds {
+		partitions.Execute(cmd, c)
+	}
+	if n := reader.Buffered(); n > 0 {
+		log.Printf("AOF %s ends with an incomplete command, truncating its last %d bytes", path, n)
+		return os.Truncate(path, int64(size-n))
+	}
+	return nil
+}
+
+// loadAOF replays every segment, whatever the number of partitions that
+// wrote them, then opens one segment per partition for appending. Without
+// any segment the AOF is created from the RDB snapshot.
+func loadAOF() error {
+	ids, err := aofSegmentIDs()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		if err := LoadRDB(); err != nil {
+			return err
+		}
+	}
+	c := NewClient()
+	for _, id := range ids {
+		if err := replayAOFSegment(aofSegmentPath(id), c); err != nil {
+			return err
+		}
+	}
+	log.Printf("DB loaded from append only file: %d segments", len(ids))
+
+	// keys are only in the segment of their partition when the layout did
+	// not change
+	n := partitions.Count()
+	if len(ids) != n || ids[n-1] != n-1 {
+		if err := rewriteAOF(false); err != nil {
+			return err
+		}
+	}
+
+	errs := make([]error, n)
+	runAll(func(id int, s *Storage) {
+		s.aof, errs[id] = openAOFSegment(aofSegmentPath(id))
+	})
+	return errors.Join(errs...)
+}
+
+// LoadDataFromDisk loads the append only file when appendonly is enabled,
+// the RDB snapshot otherwise
+func LoadDataFromDisk() error {
+	var err error
+	if config.AppendOnly {
+		err = loadAOF()
+	} else {
+		err = LoadRDB()
+	}
+	atomic.StoreInt64(&rdb.dirty, 0)
+	return err
+}
+
+// PrepareForShutdown waits for the running background writes, fsyncs the
+// append only file and, when save rules are configured, writes a last
+// snapshot before the server exits
+func PrepareForShutdown() error {
+	rdb.bgsave.Wait()
+	aofRewrite.wg.Wait()
+	runAll(func(id int, s *Storage) {
+		if s.aof != nil {
+			s.aof.file.Sync()
+		}
+	})
+	if len(config.SaveRules) == 0 {
+		return nil
+	}
+	return Save()
+}
@@ -0,0 +1,158 @@
+package core_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/config"
+	"goredis-lite/internal/core"
+)
+
+func TestAOFReplay(t *testing.T) {
+	config.Dir = t.TempDir()
+	config.AppendOnly = true
+	defer func() { config.AppendOnly = false }()
+	c := core.NewClient()
+
+	src := core.NewStorage()
+	core.UsePartitions(partitions{src})
+	assert.NoError(t, core.LoadDataFromDisk())
+	run(src, c, "SET", "str", "value")
+	run(src, c, "SET", "ttl", "value", "EX", "100")
+	run(src, c, "SADD", "set", "a", "b")
+	run(src, c, "SREM", "set", "a")
+	run(src, c, "ZADD", "zset", "1", "a")
+	run(src, c, "EXPIRE", "unknown", "100")
+	run(src, c, "SADD", "popped", "a", "b", "c", "d")
+	popped := run(src, c, "SPOP", "popped") // "$1\r\n<member>\r\n"
+	run(src, c, "SET", "float", "1", "EX", "100")
+	run(src, c, "INCRBYFLOAT", "float", "0.1")
+
+	// the segment of partition 0 is replayed into two partitions
+	dst := partitions{core.NewStorage(), core.NewStorage()}
+	core.UsePartitions(dst)
+	assert.NoError(t, core.LoadDataFromDisk())
+
+	at := func(key string) *core.Storage { return dst[dst.PartitionOf(key)] }
+	assert.Equal(t, "$5\r\nvalue\r\n", run(at("str"), c, "GET", "str"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(at("ttl"), c, "TTL", "ttl"))
+	assert.Equal(t, ":0\r\n", run(at("set"), c, "SISMEMBER", "set", "a"))
+	assert.Equal(t, ":1\r\n", run(at("set"), c, "SISMEMBER", "set", "b"))
+	assert.Equal(t, "$1\r\n1\r\n", run(at("zset"), c, "ZSCORE", "zset", "a"))
+	// SPOP is logged as the SREM of the member it popped
+	assert.Equal(t, ":3\r\n", run(at("popped"), c, "SCARD", "popped"))
+	assert.Equal(t, ":0\r\n", run(at("popped"), c, "SISMEMBER", "popped", popped[4:5]))
+	// INCRBYFLOAT is logged as a SET of its result, keeping the TTL
+	assert.Equal(t, "$3\r\n1.1\r\n", run(at("float"), c, "GET", "float"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(at("float"), c, "TTL", "float"))
+
+	// the layout changed, so the AOF was rewritten with one segment per partition
+	for id := range dst {
+		_, err := os.Stat(filepath.Join(config.Dir, config.AppendDirName, fmt.Sprintf("%s.%d", config.AppendFileName, id)))
+		assert.NoError(t, err)
+	}
+}
+
+func TestAOFSkipsNoOps(t *testing.T) {
+	config.Dir = t.TempDir()
+	config.AppendOnly = true
+	defer func() { config.AppendOnly = false }()
+	c := core.NewClient()
+
+	s := core.NewStorage()
+	core.UsePartitions(partitions{s})
+	assert.NoError(t, core.LoadDataFromDisk())
+	run(s, c, "SET", "k", "v")
+	// none of these change the keyspace
+	run(s, c, "SETNX", "k", "other")
+	run(s, c, "DEL", "missing")
+	run(s, c, "ZADD", "zset", "XX", "1", "a")
+	run(s, c, "SREM", "k2", "a")
+	run(s, c, "LPOP", "list")
+	run(s, c, "INCR", "k")
+	run(s, c, "HINCRBYFLOAT", "hash", "f", "0.5")
+
+	data, err := os.ReadFile(filepath.Join(config.Dir, config.AppendDirName, config.AppendFileName+".0"))
+	assert.NoError(t, err)
+	// the segment starts with an RDB preamble, then HINCRBYFLOAT is logged
+	// as a HSET of its result
+	assert.True(t, strings.HasSuffix(string(data), "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"+
+		"*4\r\n$4\r\nHSET\r\n$4\r\nhash\r\n$1\r\nf\r\n$3\r\n0.5\r\n"), string(data))
+}
+
+func TestAOFSpop(t *testing.T) {
+	config.Dir = t.TempDir()
+	config.AppendOnly = true
+	defer func() { config.AppendOnly = false }()
+	c := core.NewClient()
+
+	s := core.NewStorage()
+	core.UsePartitions(partitions{s})
+	assert.NoError(t, core.LoadDataFromDisk())
+	run(s, c, "SADD", "set", "a")
+	// popping nothing logs nothing, not a SREM without members
+	assert.Equal(t, "*0\r\n", run(s, c, "SPOP", "set", "0"))
+	assert.Equal(t, "$1\r\na\r\n", run(s, c, "SPOP", "set"))
+
+	data, err := os.ReadFile(filepath.Join(config.Dir, config.AppendDirName, config.AppendFileName+".0"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "*3\r\n$4\r\nSADD\r\n$3\r\nset\r\n$1\r\na\r\n"+
+		"*3\r\n$4\r\nSREM\r\n$3\r\nset\r\n$1\r\na\r\n"), string(data))
+
+	// the log replays
+	restarted := core.NewStorage()
+	core.UsePartitions(partitions{restarted})
+	assert.NoError(t, core.LoadDataFromDisk())
+	assert.Equal(t, ":0\r\n", run(restarted, c, "EXISTS", "set"))
+}
+
+func TestAOFBlockingPops(t *testing.T) {
+	config.Dir = t.TempDir()
+	config.AppendOnly = true
+	defer func() { config.AppendOnly = false }()
+	c := core.NewClient()
+
+	s := core.NewStorage()
+	core.UsePartitions(partitions{s})
+	assert.NoError(t, core.LoadDataFromDisk())
+	exec := func(cmd *core.Command, c *core.Client) []byte { return core.ExecuteCommand(s, cmd, c) }
+	blocked := core.NewBlockedClients(exec, nil)
+	blocked.Run(1, core.NewClient(), cmds([]string{"BLPOP", "q", "0"}))
+	blocked.Run(2, core.NewClient(), cmds([]string{"BLMOVE", "q", "d", "RIGHT", "LEFT", "0"}))
+	blocked.Run(3, c, cmds([]string{"RPUSH", "q", "a", "b", "c"}, []string{"BRPOP", "q", "0"}))
+
+	// pops are logged as the commands they amount to, after the push
+	data, err := os.ReadFile(filepath.Join(config.Dir, config.AppendDirName, config.AppendFileName+".0"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "*5\r\n$5\r\nRPUSH\r\n$1\r\nq\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"+
+		"*2\r\n$4\r\nLPOP\r\n$1\r\nq\r\n"+
+		"*5\r\n$5\r\nLMOVE\r\n$1\r\nq\r\n$1\r\nd\r\n$5\r\nRIGHT\r\n$4\r\nLEFT\r\n"+
+		"*2\r\n$4\r\nRPOP\r\n$1\r\nq\r\n"), string(data))
+}
+
+func TestAOFTruncatedTail(t *testing.T) {
+	config.Dir = t.TempDir()
+	config.AppendOnly = true
+	defer func() { config.AppendOnly = false }()
+	c := core.NewClient()
+
+	dir := filepath.Join(config.Dir, config.AppendDirName)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	path := filepath.Join(dir, config.AppendFileName+".0")
+	complete := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"
+	assert.NoError(t, os.WriteFile(path, []byte(complete+"*3\r\n$3\r\nSET\r\n$1\r\nx"), 0644))
+
+	s := core.NewStorage()
+	core.UsePartitions(partitions{s})
+	assert.NoError(t, core.LoadDataFromDisk())
+	assert.Equal(t, "$1\r\nv\r\n", run(s, c, "GET", "k"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "GET", "x"))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, complete, string(data))
+}
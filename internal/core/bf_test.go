@@ -0,0 +1,156 @@
+package core_test
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/config"
+	"goredis-lite/internal/core"
+)
+
+func TestBloomReserve(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, "+OK\r\n", run(s, c, "BF.RESERVE", "bf", "0.01", "10", "EXPANSION", "4"))
+	assert.Equal(t, "-Bloom filter with key 'bf' already exist\r\n", run(s, c, "BF.RESERVE", "bf", "0.01", "10"))
+	assert.Equal(t, "+OK\r\n", run(s, c, "BF.RESERVE", "fixed", "0.01", "2", "NONSCALING"))
+	assert.Equal(t, "-ERR Non scaling filters cannot expand\r\n", run(s, c, "BF.RESERVE", "k", "0.01", "2", "NONSCALING", "EXPANSION", "2"))
+	assert.Equal(t, "-ERR bad expansion\r\n", run(s, c, "BF.RESERVE", "k", "0.01", "2", "EXPANSION", "0"))
+	assert.Equal(t, "-ERR (0 < error rate range < 1)\r\n", run(s, c, "BF.RESERVE", "k", "1", "2"))
+	assert.Equal(t, "-ERR (capacity should be larger than 0)\r\n", run(s, c, "BF.RESERVE", "k", "0.01", "0"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "BF.RESERVE", "k", "0.01", "2", "SCALING"))
+
+	// a non scaling filter refuses new items once full
+	assert.Equal(t, "*3\r\n:1\r\n:1\r\n:0\r\n", run(s, c, "BF.MADD", "fixed", "a", "b", "a"))
+	assert.Equal(t, "*2\r\n-ERR non scaling filter is full\r\n:0\r\n", run(s, c, "BF.MADD", "fixed", "c", "b"))
+	assert.Equal(t, ":0\r\n", run(s, c, "BF.EXISTS", "fixed", "c"))
+}
+
+func TestBloomScaling(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	// BF.MADD creates a filter of 100 items that keeps growing
+	args := []string{"BF.MADD", "bf"}
+	for i := 0; i < 1000; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	run(s, c, args...)
+	for i := 0; i < 1000; i++ {
+		assert.Equal(t, ":1\r\n", run(s, c, "BF.EXISTS", "bf", strconv.Itoa(i)))
+	}
+	falsePositives := 0
+	for i := 1000; i < 6000; i++ {
+		if run(s, c, "BF.EXISTS", "bf", strconv.Itoa(i)) == ":1\r\n" {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 5000/100)
+	assert.Equal(t, "*2\r\n:0\r\n:1\r\n", run(s, c, "BF.MADD", "bf", "999", "new"))
+}
+
+func TestBloomCommands(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":1\r\n", run(s, c, "BF.ADD", "bf", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "BF.ADD", "bf", "a"))
+	assert.Equal(t, "*3\r\n:1\r\n:0\r\n:0\r\n", run(s, c, "BF.MEXISTS", "bf", "a", "b", "c"))
+	assert.Equal(t, "*2\r\n:0\r\n:0\r\n", run(s, c, "BF.MEXISTS", "missing", "a", "b"))
+	assert.Equal(t, ":1\r\n", run(s, c, "BF.CARD", "bf"))
+	assert.Equal(t, ":0\r\n", run(s, c, "BF.CARD", "missing"))
+
+	assert.Equal(t, "*2\r\n:1\r\n:1\r\n", run(s, c, "BF.INSERT", "ins", "CAPACITY", "1000", "ERROR", "0.001", "EXPANSION", "4", "ITEMS", "a", "b"))
+	assert.Equal(t, "*1\r\n:1000\r\n", run(s, c, "BF.INFO", "ins", "CAPACITY"))
+	assert.Equal(t, "*1\r\n:4\r\n", run(s, c, "BF.INFO", "ins", "EXPANSION"))
+	assert.Equal(t, "*1\r\n:2\r\n", run(s, c, "BF.INFO", "ins", "ITEMS"))
+	assert.Equal(t, "*1\r\n:0\r\n", run(s, c, "BF.INSERT", "ins", "NOCREATE", "ITEMS", "a"))
+	assert.Equal(t, "-ERR not found\r\n", run(s, c, "BF.INSERT", "missing", "NOCREATE", "ITEMS", "a"))
+	assert.Equal(t, "-ERR NOCREATE cannot be used together with CAPACITY or ERROR\r\n", run(s, c, "BF.INSERT", "ins", "NOCREATE", "CAPACITY", "5", "ITEMS", "a"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'bf.insert' command\r\n", run(s, c, "BF.INSERT", "ins", "CAPACITY", "5", "ITEMS"))
+	assert.Equal(t, "-ERR Bad capacity\r\n", run(s, c, "BF.INSERT", "ins", "CAPACITY", "x", "ITEMS", "a"))
+	assert.Equal(t, "*2\r\n:1\r\n-ERR non scaling filter is full\r\n", run(s, c, "BF.INSERT", "fixed", "CAPACITY", "1", "NONSCALING", "ITEMS", "a", "b"))
+	assert.Equal(t, "*1\r\n$-1\r\n", run(s, c, "BF.INFO", "fixed", "EXPANSION"))
+
+	run(s, c, "BF.RESERVE", "info", "0.01", "100")
+	assert.Equal(t, "*14\r\n$8\r\nCapacity\r\n:100\r\n$4\r\nSize\r\n:144\r\n"+
+		"$17\r\nNumber of filters\r\n:1\r\n$24\r\nNumber of items inserted\r\n:0\r\n"+
+		"$14\r\nExpansion rate\r\n:2\r\n$6\r\nHashes\r\n:8\r\n$4\r\nBits\r\n:1152\r\n", run(s, c, "BF.INFO", "info"))
+	assert.Equal(t, "-ERR not found\r\n", run(s, c, "BF.INFO", "missing"))
+	assert.Equal(t, "-ERR Invalid information value\r\n", run(s, c, "BF.INFO", "info", "NAME"))
+
+	run(s, c, "RPUSH", "list", "a")
+	assert.Equal(t, wrongType, run(s, c, "BF.ADD", "list", "a"))
+	assert.Equal(t, wrongType, run(s, c, "BF.CARD", "list"))
+}
+
+func TestBloomScanDump(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	config.BfScanDumpChunkSize = 100
+	defer func() { config.BfScanDumpChunkSize = 16 * 1024 * 1024 }()
+
+	args := []string{"BF.INSERT", "bf", "CAPACITY", "50", "ITEMS"}
+	for i := 0; i < 200; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	run(s, c, args...)
+
+	// copy the filter chunk by chunk, like a migration to another server
+	dst := core.NewStorage()
+	iter := "0"
+	for chunks := 0; ; chunks++ {
+		reply, err := core.Decode([]byte(run(s, c, "BF.SCANDUMP", "bf", iter)))
+		assert.NoError(t, err)
+		res := reply.([]interface{})
+		iter = strconv.FormatInt(res[0].(int64), 10)
+		if iter == "0" {
+			assert.Greater(t, chunks, 2)
+			break
+		}
+		assert.Equal(t, "+OK\r\n", run(dst, c, "BF.LOADCHUNK", "bf", iter, res[1].(string)))
+	}
+	assert.Equal(t, run(s, c, "BF.INFO", "bf"), run(dst, c, "BF.INFO", "bf"))
+	for i := 0; i < 200; i++ {
+		assert.Equal(t, ":1\r\n", run(dst, c, "BF.EXISTS", "bf", strconv.Itoa(i)))
+	}
+
+	assert.Equal(t, "-ERR item exists\r\n", run(dst, c, "BF.LOADCHUNK", "bf", "1", "x"))
+	assert.Equal(t, "-ERR received bad data\r\n", run(dst, c, "BF.LOADCHUNK", "new", "1", "x"))
+	assert.Equal(t, "-ERR not found\r\n", run(dst, c, "BF.LOADCHUNK", "new", "5", "x"))
+	assert.Equal(t, "-ERR invalid offset - no link found\r\n", run(dst, c, "BF.LOADCHUNK", "bf", "100000", "x"))
+	assert.Equal(t, "-ERR not found\r\n", run(s, c, "BF.SCANDUMP", "missing", "0"))
+}
+
+func TestBloomBounds(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, "-ERR bad expansion\r\n", run(s, c, "BF.RESERVE", "k", "0.01", "2", "EXPANSION", "9223372036854775808"))
+	assert.Equal(t, "-ERR bad expansion\r\n", run(s, c, "BF.INSERT", "k", "EXPANSION", "32769", "ITEMS", "a"))
+	assert.Equal(t, "-ERR (0 < error rate range < 1)\r\n", run(s, c, "BF.RESERVE", "k", "nan", "2"))
+	assert.Equal(t, "-ERR filter would exceed the maximum size\r\n", run(s, c, "BF.RESERVE", "k", "0.01", "18446744073709551615"))
+	assert.Equal(t, "-ERR filter would exceed the maximum size\r\n", run(s, c, "BF.INSERT", "k", "CAPACITY", "1000000000000", "ITEMS", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "k"))
+
+	// the filter refuses the items needing a layer past the maximum size
+	assert.Equal(t, "+OK\r\n", run(s, c, "BF.RESERVE", "bf", "0.01", "1", "EXPANSION", "32768"))
+	added := ""
+	for i := 0; i < 40000 && added != "-ERR filter would exceed the maximum size\r\n"; i++ {
+		added = run(s, c, "BF.ADD", "bf", strconv.Itoa(i))
+	}
+	assert.Equal(t, "-ERR filter would exceed the maximum size\r\n", added)
+	assert.Equal(t, "*1\r\n:2\r\n", run(s, c, "BF.INFO", "bf", "FILTERS"))
+
+	// so does BF.LOADCHUNK, given a layer too large
+	reply, err := core.Decode([]byte(run(s, c, "BF.SCANDUMP", "bf", "0")))
+	assert.NoError(t, err)
+	header := []byte(reply.([]interface{})[1].(string))
+	binary.LittleEndian.PutUint64(header[32:], math.MaxUint64)
+	assert.Equal(t, "-ERR received bad data\r\n", run(s, c, "BF.LOADCHUNK", "copy", "1", string(header)))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "copy"))
+}
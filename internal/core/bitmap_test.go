@@ -0,0 +1,143 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func TestBits(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":0\r\n", run(s, c, "SETBIT", "k", "7", "1"))
+	assert.Equal(t, ":1\r\n", run(s, c, "SETBIT", "k", "7", "0"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SETBIT", "k", "9", "1"))
+	assert.Equal(t, "$2\r\n\x00\x40\r\n", run(s, c, "GET", "k"))
+	assert.Equal(t, ":1\r\n", run(s, c, "GETBIT", "k", "9"))
+	assert.Equal(t, ":0\r\n", run(s, c, "GETBIT", "k", "100"))
+	assert.Equal(t, ":0\r\n", run(s, c, "GETBIT", "missing", "0"))
+	assert.Equal(t, "-ERR bit is not an integer or out of range\r\n", run(s, c, "SETBIT", "k", "0", "2"))
+	assert.Equal(t, "-ERR bit offset is not an integer or out of range\r\n", run(s, c, "SETBIT", "k", "-1", "1"))
+	assert.Equal(t, "-ERR bit offset is not an integer or out of range\r\n", run(s, c, "SETBIT", "k", "4294967296", "1"))
+
+	run(s, c, "SET", "k", "foobar")
+	assert.Equal(t, ":26\r\n", run(s, c, "BITCOUNT", "k"))
+	assert.Equal(t, ":4\r\n", run(s, c, "BITCOUNT", "k", "0", "0"))
+	assert.Equal(t, ":6\r\n", run(s, c, "BITCOUNT", "k", "1", "1", "BYTE"))
+	assert.Equal(t, ":17\r\n", run(s, c, "BITCOUNT", "k", "5", "30", "BIT"))
+	assert.Equal(t, ":26\r\n", run(s, c, "BITCOUNT", "k", "-100", "100"))
+	assert.Equal(t, ":0\r\n", run(s, c, "BITCOUNT", "k", "3", "1"))
+	assert.Equal(t, ":0\r\n", run(s, c, "BITCOUNT", "missing"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "BITCOUNT", "k", "0"))
+
+	run(s, c, "RPUSH", "list", "a")
+	assert.Equal(t, wrongType, run(s, c, "SETBIT", "list", "0", "1"))
+	assert.Equal(t, wrongType, run(s, c, "BITCOUNT", "list"))
+}
+
+func TestBitsRawEncoding(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	// the string written by SETBIT is modified in place, even when it
+	// spells an integer
+	run(s, c, "SET", "k", "5")
+	assert.Equal(t, ":1\r\n", run(s, c, "SETBIT", "k", "7", "0"))
+	assert.Equal(t, "$3\r\nraw\r\n", run(s, c, "OBJECT", "ENCODING", "k"))
+	assert.Equal(t, "$1\r\n4\r\n", run(s, c, "GET", "k"))
+	assert.Equal(t, ":3\r\n", run(s, c, "BITCOUNT", "k"))
+	assert.Equal(t, ":5\r\n", run(s, c, "INCR", "k"))
+	assert.Equal(t, "$3\r\nint\r\n", run(s, c, "OBJECT", "ENCODING", "k"))
+
+	assert.Equal(t, "*1\r\n:0\r\n", run(s, c, "BITFIELD", "b", "SET", "u8", "#1", "97"))
+	assert.Equal(t, "$3\r\nraw\r\n", run(s, c, "OBJECT", "ENCODING", "b"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SETBIT", "b", "6", "1"))
+	assert.Equal(t, "$2\r\n\x02a\r\n", run(s, c, "GET", "b"))
+	assert.Equal(t, ":6\r\n", run(s, c, "BITPOS", "b", "1"))
+	assert.Equal(t, "*1\r\n:2\r\n", run(s, c, "BITFIELD_RO", "b", "GET", "u8", "0"))
+	assert.Equal(t, ":3\r\n", run(s, c, "APPEND", "b", "b"))
+	assert.Equal(t, "$3\r\n\x02ab\r\n", run(s, c, "GET", "b"))
+	assert.Equal(t, ":1\r\n", run(s, c, "EXPIRE", "b", "100"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SETBIT", "b", "100", "1"))
+	assert.Equal(t, ":13\r\n", run(s, c, "STRLEN", "b"))
+	assert.Equal(t, ":1\r\n", run(s, c, "GETBIT", "b", "100"))
+	assert.NotEqual(t, ":-1\r\n", run(s, c, "TTL", "b"))
+	assert.Equal(t, "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n", run(s, c, "PFCOUNT", "b"))
+}
+
+func TestBitpos(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	run(s, c, "SET", "k", "\xff\xf0\x00")
+	assert.Equal(t, ":12\r\n", run(s, c, "BITPOS", "k", "0"))
+	run(s, c, "SET", "k", "\x00\xff\xf0")
+	assert.Equal(t, ":8\r\n", run(s, c, "BITPOS", "k", "1", "0"))
+	assert.Equal(t, ":16\r\n", run(s, c, "BITPOS", "k", "1", "2"))
+	assert.Equal(t, ":16\r\n", run(s, c, "BITPOS", "k", "1", "2", "-1", "BYTE"))
+	assert.Equal(t, ":8\r\n", run(s, c, "BITPOS", "k", "1", "7", "15", "BIT"))
+	run(s, c, "SET", "k", "\x00\x00\x00")
+	assert.Equal(t, ":-1\r\n", run(s, c, "BITPOS", "k", "1"))
+	assert.Equal(t, ":-1\r\n", run(s, c, "BITPOS", "k", "1", "7", "-3", "BIT"))
+
+	// without end, a string of ones is followed by zeros
+	run(s, c, "SET", "k", "\xff\xff")
+	assert.Equal(t, ":16\r\n", run(s, c, "BITPOS", "k", "0"))
+	assert.Equal(t, ":-1\r\n", run(s, c, "BITPOS", "k", "0", "0", "-1"))
+	assert.Equal(t, ":0\r\n", run(s, c, "BITPOS", "missing", "0"))
+	assert.Equal(t, ":-1\r\n", run(s, c, "BITPOS", "missing", "1"))
+	assert.Equal(t, "-ERR The bit argument must be 1 or 0.\r\n", run(s, c, "BITPOS", "k", "2"))
+}
+
+func TestBitop(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "MSET", "a", "foobar", "b", "abcdef", "short", "\xff")
+
+	assert.Equal(t, ":6\r\n", run(s, c, "BITOP", "AND", "dest", "a", "b"))
+	assert.Equal(t, "$6\r\n`bc`ab\r\n", run(s, c, "GET", "dest"))
+	assert.Equal(t, ":6\r\n", run(s, c, "BITOP", "XOR", "dest", "a", "a", "missing"))
+	assert.Equal(t, "$6\r\n\x00\x00\x00\x00\x00\x00\r\n", run(s, c, "GET", "dest"))
+	assert.Equal(t, ":6\r\n", run(s, c, "BITOP", "OR", "dest", "short", "missing", "b"))
+	assert.Equal(t, "$6\r\n\xffbcdef\r\n", run(s, c, "GET", "dest"))
+	assert.Equal(t, ":1\r\n", run(s, c, "BITOP", "NOT", "dest", "short"))
+	assert.Equal(t, "$1\r\n\x00\r\n", run(s, c, "GET", "dest"))
+
+	// an empty result deletes the destination
+	assert.Equal(t, ":0\r\n", run(s, c, "BITOP", "AND", "dest", "missing"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "dest"))
+	assert.Equal(t, "-ERR BITOP NOT must be called with a single source key.\r\n", run(s, c, "BITOP", "NOT", "dest", "a", "b"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "BITOP", "NAND", "dest", "a", "b"))
+}
+
+func TestBitfield(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, "*2\r\n:1\r\n:0\r\n", run(s, c, "BITFIELD", "k", "INCRBY", "i5", "100", "1", "GET", "u4", "0"))
+	assert.Equal(t, "*2\r\n:0\r\n:0\r\n", run(s, c, "BITFIELD", "f", "SET", "i8", "#0", "100", "SET", "i8", "#1", "200"))
+	assert.Equal(t, "*2\r\n:100\r\n:-56\r\n", run(s, c, "BITFIELD_RO", "f", "GET", "i8", "0", "GET", "i8", "#1"))
+	assert.Equal(t, "*1\r\n:100\r\n", run(s, c, "BITFIELD", "f", "SET", "u8", "0", "-1"))
+	assert.Equal(t, "*1\r\n:255\r\n", run(s, c, "BITFIELD", "f", "GET", "u8", "0"))
+
+	// the counter of the Redis documentation, wrapping on the left and
+	// saturating on the right
+	ops := []string{"BITFIELD", "o", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"}
+	for _, want := range []string{"*2\r\n:1\r\n:1\r\n", "*2\r\n:2\r\n:2\r\n", "*2\r\n:3\r\n:3\r\n", "*2\r\n:0\r\n:3\r\n"} {
+		assert.Equal(t, want, run(s, c, ops...))
+	}
+	assert.Equal(t, "*1\r\n$-1\r\n", run(s, c, "BITFIELD", "o", "OVERFLOW", "FAIL", "INCRBY", "u2", "102", "1"))
+	assert.Equal(t, "*2\r\n:-128\r\n:0\r\n", run(s, c, "BITFIELD", "o", "OVERFLOW", "SAT", "INCRBY", "i8", "0", "-1000", "SET", "i8", "8", "1000"))
+	assert.Equal(t, "*1\r\n:127\r\n", run(s, c, "BITFIELD_RO", "o", "GET", "i8", "8"))
+	assert.Equal(t, "*2\r\n:0\r\n:-9223372036854775808\r\n", run(s, c, "BITFIELD", "i64", "SET", "i64", "0", "9223372036854775807", "INCRBY", "i64", "0", "1"))
+
+	// reads don't create the key
+	assert.Equal(t, "*1\r\n:0\r\n", run(s, c, "BITFIELD", "missing", "GET", "u8", "0"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "missing"))
+	assert.Equal(t, "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n", run(s, c, "BITFIELD", "k", "GET", "u64", "0"))
+	assert.Equal(t, "-ERR Invalid OVERFLOW type specified\r\n", run(s, c, "BITFIELD", "k", "OVERFLOW", "NONE"))
+	assert.Equal(t, "-ERR BITFIELD_RO only supports the GET subcommand\r\n", run(s, c, "BITFIELD_RO", "k", "SET", "u8", "0", "1"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "BITFIELD", "k", "GET", "u8"))
+}
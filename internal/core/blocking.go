@@ -0,0 +1,288 @@
+package core
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// states of a blockedPop, changed by whichever of the storage serving it and
+// the event loop timing it out gets there first
+const (
+	popWaiting int32 = iota
+	popServed
+	popCancelled
+)
+
+// blockedPop is a blocking command waiting, in the storage owning its keys,
+// for one of them to be pushed to
+type blockedPop struct {
+	bc      *blockedClient
+	storage *Storage
+	state   int32
+	keys    []string
+	// pop serves the command from key, a non empty list, and returns its
+	// reply and the command logged in the AOF instead of the blocking one
+	pop   func(s *Storage, key string) ([]byte, []string)
+	reply []byte
+}
+
+// blockPop makes the blocking command c is running wait for one of keys to
+// be pushed to, behind the commands already waiting for them. A client run
+// outside of BlockedClients, e.g. replaying the AOF, can't wait.
+func (s *Storage) blockPop(c *Client, keys []string, pop func(s *Storage, key string) ([]byte, []string)) {
+	if c.blocked == nil {
+		return
+	}
+	bp := &blockedPop{bc: c.blocked, storage: s, keys: keys, pop: pop}
+	c.blocked.pop = bp
+	if s.blockedPops == nil {
+		s.blockedPops = make(map[string][]*blockedPop)
+	}
+	for _, key := range keys {
+		s.blockedPops[key] = append(s.blockedPops[key], bp)
+	}
+	s.numBlockedPops += int64(len(keys))
+	// the pops served or cancelled are only dropped from the queues of their
+	// other keys once they make up half of the queued pops
+	if 2*atomic.LoadInt64(&s.staleBlockedPops) > s.numBlockedPops {
+		s.dropStaleBlockedPops()
+	}
+}
+
+func (s *Storage) dropStaleBlockedPops() {
+	var dropped int64
+	for key, queue := range s.blockedPops {
+		kept := queue[:0]
+		for _, bp := range queue {
+			if atomic.LoadInt32(&bp.state) == popWaiting {
+				kept = append(kept, bp)
+			}
+		}
+		dropped += int64(len(queue) - len(kept))
+		clear(queue[len(kept):])
+		if len(kept) == 0 {
+			delete(s.blockedPops, key)
+		} else {
+			s.blockedPops[key] = kept
+		}
+	}
+	s.numBlockedPops -= dropped
+	atomic.AddInt64(&s.staleBlockedPops, -dropped)
+}
+
+// signalListReady marks key, just pushed to, to be served to the commands
+// waiting for it once the running command returns
+func (s *Storage) signalListReady(key string) {
+	if len(s.blockedPops[key]) > 0 {
+		s.readyKeys = append(s.readyKeys, key)
+	}
+}
+
+// serveBlockedPops serves the commands waiting for the keys pushed to, in
+// the order they blocked, as long as the lists have elements. Each pop is
+// logged as the non blocking command it amounts to, and its reply handed
+// to the event loop of the client.
+func (s *Storage) serveBlockedPops() {
+	for len(s.readyKeys) > 0 {
+		key := s.readyKeys[0]
+		s.readyKeys = s.readyKeys[1:]
+		for queue := s.blockedPops[key]; len(queue) > 0; queue = s.blockedPops[key] {
+			if list, _ := s.getList(key); list == nil {
+				break
+			}
+			bp := queue[0]
+			queue[0] = nil
+			s.blockedPops[key] = queue[1:]
+			s.numBlockedPops--
+			if !atomic.CompareAndSwapInt32(&bp.state, popWaiting, popServed) {
+				atomic.AddInt64(&s.staleBlockedPops, -1)
+				continue
+			}
+			atomic.AddInt64(&s.staleBlockedPops, int64(len(bp.keys)-1))
+			dirty := s.dirty
+			var argv []string
+			bp.reply, argv = bp.pop(s, key)
+			s.propagate(&Command{Cmd: argv[0], Args: argv[1:]}, "", 0, dirty)
+			bp.bc.owner.deliver(bp)
+		}
+		if len(s.blockedPops[key]) == 0 {
+			delete(s.blockedPops, key)
+		}
+	}
+	s.readyKeys = nil
+}
+
+// blockedClient is a connection waiting for a blocking command, pending[0],
+// to be served. The commands it sent since wait behind it.
+type blockedClient struct {
+	fd       int
+	client   *Client
+	owner    *BlockedClients
+	pending  []*Command
+	pop      *blockedPop // set by the storage pending[0] waits in
+	deadline time.Time   // zero when the command waits forever
+}
+
+// BlockedClients parks the connections of an event loop whose blocking
+// command, e.g. BLPOP, found nothing to pop. Instead of holding the loop,
+// the command waits in the storage owning its keys, which serves it when
+// one of them is pushed to, and hands the reply back to Unblock. Commands
+// waiting for the same key are served in the order they blocked. It must
+// only be used from the goroutine running the event loop.
+type BlockedClients struct {
+	exec    func(cmd *Command, c *Client) []byte
+	notify  func()
+	clients map[int]*blockedClient // by fd
+
+	mu     sync.Mutex
+	served []*blockedPop // by the storages, not yet replied to
+}
+
+// NewBlockedClients returns a BlockedClients running commands with exec,
+// which must return a nil reply when a blocking command has to wait. notify,
+// when not nil, is called by the storage serving a blocked client, e.g. to
+// interrupt the wait of the event loop.
+func NewBlockedClients(exec func(cmd *Command, c *Client) []byte, notify func()) *BlockedClients {
+	return &BlockedClients{
+		exec:    exec,
+		notify:  notify,
+		clients: make(map[int]*blockedClient),
+	}
+}
+
+// Len returns the number of blocked clients
+func (b *BlockedClients) Len() int {
+	return len(b.clients)
+}
+
+// Run executes the commands c sent on fd in order and returns their replies.
+// When one of them blocks, it and the commands after it wait for Unblock.
+// The commands of a client already blocked are queued right away.
+func (b *BlockedClients) Run(fd int, c *Client, cmds []*Command) []byte {
+	if bc, ok := b.clients[fd]; ok {
+		bc.pending = append(bc.pending, cmds...)
+		return nil
+	}
+	bc := &blockedClient{fd: fd, client: c, owner: b, pending: cmds}
+	c.blocked = bc
+	out := b.serve(bc)
+	if len(bc.pending) > 0 {
+		b.clients[fd] = bc
+	}
+	return out
+}
+
+// deliver is called by the storage that served bp, from its goroutine
+func (b *BlockedClients) deliver(bp *blockedPop) {
+	b.mu.Lock()
+	b.served = append(b.served, bp)
+	b.mu.Unlock()
+	if b.notify != nil {
+		b.notify()
+	}
+}
+
+// Unblock hands to write the replies of the clients served since the last
+// call and of those whose timeout elapsed, followed by the replies of the
+// commands they sent after
+func (b *BlockedClients) Unblock(write func(fd int, out []byte)) {
+	b.mu.Lock()
+	served := b.served
+	b.served = nil
+	b.mu.Unlock()
+	for _, bp := range served {
+		// the connection may have been closed meanwhile
+		if b.clients[bp.bc.fd] == bp.bc {
+			b.resume(bp.bc, bp.reply, write)
+		}
+	}
+
+	now := time.Now()
+	for _, bc := range b.clients {
+		if bc.deadline.IsZero() || now.Before(bc.deadline) || !bc.cancel() {
+			continue
+		}
+		b.resume(bc, blockTimeoutReply(bc.pending[0], bc.client), write)
+	}
+}
+
+// resume replies to the blocking command of bc, then runs the commands
+// queued after it
+func (b *BlockedClients) resume(bc *blockedClient, reply []byte, write func(fd int, out []byte)) {
+	bc.pending = bc.pending[1:]
+	out := append(append([]byte{}, reply...), b.serve(bc)...)
+	if len(bc.pending) == 0 {
+		delete(b.clients, bc.fd)
+	}
+	write(bc.fd, out)
+}
+
+// cancel stops the command of bc from waiting, and reports false when a
+// storage served it first: its reply is then on its way to Unblock
+func (bc *blockedClient) cancel() bool {
+	bp := bc.pop
+	if bp == nil {
+		return true
+	}
+	if !atomic.CompareAndSwapInt32(&bp.state, popWaiting, popCancelled) {
+		return false
+	}
+	atomic.AddInt64(&bp.storage.staleBlockedPops, int64(len(bp.keys)))
+	return true
+}
+
+// Remove forgets the client of fd, once its connection is closed
+func (b *BlockedClients) Remove(fd int) {
+	bc, ok := b.clients[fd]
+	if !ok {
+		return
+	}
+	delete(b.clients, fd)
+	bc.cancel()
+}
+
+// serve executes the pending commands of bc until one of them blocks, and
+// returns their replies
+func (b *BlockedClients) serve(bc *blockedClient) []byte {
+	var out []byte
+	for len(bc.pending) > 0 {
+		cmd := bc.pending[0]
+		bc.pop = nil
+		res := b.exec(cmd, bc.client)
+		if res == nil {
+			bc.deadline = blockDeadline(cmd)
+			return out
+		}
+		out = append(out, res...)
+		bc.pending = bc.pending[1:]
+	}
+	return out
+}
+
+// blockDeadline returns when cmd, whose last argument is its timeout in
+// seconds, stops waiting. The timeout was validated by the command.
+func blockDeadline(cmd *Command) time.Time {
+	timeout, _ := strconv.ParseFloat(cmd.Args[len(cmd.Args)-1], 64)
+	if timeout == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(timeout * float64(time.Second)))
+}
+
+// blockTimeoutReply is the reply of a blocking command whose timeout elapsed
+func blockTimeoutReply(cmd *Command, c *Client) []byte {
+	if cmd.Cmd == "BLMOVE" {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	return encodeNilArray(c.Proto)
+}
+
+// encodeNilArray returns the null array of protocol proto
+func encodeNilArray(proto int) []byte {
+	if proto == RESP3 {
+		return RespNull
+	}
+	return RespNilArray
+}
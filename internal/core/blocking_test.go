@@ -0,0 +1,106 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func cmds(args ...[]string) []*core.Command {
+	res := make([]*core.Command, len(args))
+	for i, a := range args {
+		res[i] = &core.Command{Cmd: a[0], Args: a[1:]}
+	}
+	return res
+}
+
+func TestBlockedClientsServedOnPush(t *testing.T) {
+	s := core.NewStorage()
+	exec := func(cmd *core.Command, c *core.Client) []byte { return core.ExecuteCommand(s, cmd, c) }
+	notified := 0
+	blocked := core.NewBlockedClients(exec, func() { notified++ })
+	replies := make(map[int]string)
+	write := func(fd int, out []byte) { replies[fd] += string(out) }
+
+	// the PING sent after BLPOP waits with it
+	first, second := core.NewClient(), core.NewClient()
+	assert.Empty(t, blocked.Run(1, first, cmds([]string{"BLPOP", "q", "0"}, []string{"PING"})))
+	assert.Empty(t, blocked.Run(2, second, cmds([]string{"BLPOP", "q", "0"})))
+	assert.Equal(t, 2, blocked.Len())
+	blocked.Unblock(write)
+	assert.Empty(t, replies)
+
+	// clients are served by the push, in the order they blocked
+	other := core.NewClient()
+	assert.Equal(t, ":1\r\n", string(blocked.Run(3, other, cmds([]string{"RPUSH", "q", "a"}))))
+	assert.Equal(t, 1, notified)
+	blocked.Unblock(write)
+	assert.Equal(t, "*2\r\n$1\r\nq\r\n$1\r\na\r\n+PONG\r\n", replies[1])
+	assert.Equal(t, 1, blocked.Len())
+
+	// a pop coming after the push finds the element taken by the client
+	// that blocked first
+	assert.Equal(t, ":1\r\n", string(blocked.Run(3, other, cmds([]string{"RPUSH", "q", "b"}))))
+	assert.Equal(t, "$-1\r\n", string(blocked.Run(3, other, cmds([]string{"LPOP", "q"}))))
+	blocked.Unblock(write)
+	assert.Equal(t, "*2\r\n$1\r\nq\r\n$1\r\nb\r\n", replies[2])
+	assert.Equal(t, 0, blocked.Len())
+	assert.Equal(t, ":0\r\n", run(s, first, "LLEN", "q"))
+}
+
+func TestBlockedClientsServedOnce(t *testing.T) {
+	s := core.NewStorage()
+	exec := func(cmd *core.Command, c *core.Client) []byte { return core.ExecuteCommand(s, cmd, c) }
+	blocked := core.NewBlockedClients(exec, nil)
+	replies := make(map[int]string)
+	write := func(fd int, out []byte) { replies[fd] += string(out) }
+	c := core.NewClient()
+
+	// a client waiting for several lists is served by the first pushed to
+	blocked.Run(1, core.NewClient(), cmds([]string{"BRPOP", "a", "b", "0"}))
+	blocked.Run(2, c, cmds([]string{"RPUSH", "b", "x", "y"}, []string{"RPUSH", "a", "z"}))
+	blocked.Unblock(write)
+	assert.Equal(t, "*2\r\n$1\r\nb\r\n$1\r\ny\r\n", replies[1])
+	assert.Equal(t, ":1\r\n", run(s, c, "LLEN", "a"))
+
+	// the element moved by BLMOVE serves the client waiting for its
+	// destination
+	blocked.Run(3, core.NewClient(), cmds([]string{"BLMOVE", "src", "dst", "LEFT", "RIGHT", "0"}))
+	blocked.Run(4, core.NewClient(), cmds([]string{"BLPOP", "dst", "0"}))
+	blocked.Run(2, c, cmds([]string{"LPUSH", "src", "m"}))
+	blocked.Unblock(write)
+	assert.Equal(t, "$1\r\nm\r\n", replies[3])
+	assert.Equal(t, "*2\r\n$3\r\ndst\r\n$1\r\nm\r\n", replies[4])
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "src", "dst"))
+	assert.Equal(t, 0, blocked.Len())
+}
+
+func TestBlockedClientsTimeout(t *testing.T) {
+	s := core.NewStorage()
+	exec := func(cmd *core.Command, c *core.Client) []byte { return core.ExecuteCommand(s, cmd, c) }
+	blocked := core.NewBlockedClients(exec, nil)
+	replies := make(map[int]string)
+	write := func(fd int, out []byte) { replies[fd] += string(out) }
+
+	c := core.NewClient()
+	assert.Empty(t, blocked.Run(1, c, cmds([]string{"BRPOP", "q", "0.01"}, []string{"BLMOVE", "q", "d", "LEFT", "RIGHT", "0.01"})))
+	time.Sleep(20 * time.Millisecond)
+	blocked.Unblock(write)
+	assert.Equal(t, "*-1\r\n", replies[1])
+	time.Sleep(20 * time.Millisecond)
+	blocked.Unblock(write)
+	assert.Equal(t, "*-1\r\n$-1\r\n", replies[1])
+	assert.Equal(t, 0, blocked.Len())
+
+	// a closed connection stops waiting, the next push is left in the list
+	blocked.Run(2, c, cmds([]string{"BLPOP", "q", "0"}))
+	blocked.Remove(2)
+	assert.Equal(t, 0, blocked.Len())
+	assert.Equal(t, ":1\r\n", run(s, c, "RPUSH", "q", "a"))
+	assert.Equal(t, ":1\r\n", run(s, c, "LLEN", "q"))
+
+	assert.Equal(t, "-ERR timeout is negative\r\n", run(s, c, "BLPOP", "q", "-1"))
+	assert.Equal(t, "-ERR timeout is not a float or out of range\r\n", run(s, c, "BLPOP", "q", "x"))
+}
@@ -0,0 +1,108 @@
+package core_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func TestCuckooReserve(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, "+OK\r\n", run(s, c, "CF.RESERVE", "cf", "100", "BUCKETSIZE", "4", "MAXITERATIONS", "10", "EXPANSION", "2"))
+	assert.Equal(t, "-ERR item exists\r\n", run(s, c, "CF.RESERVE", "cf", "100"))
+	assert.Equal(t, "-ERR Bad capacity\r\n", run(s, c, "CF.RESERVE", "k", "many"))
+	assert.Equal(t, "-ERR Capacity must be at least (BucketSize * 2)\r\n", run(s, c, "CF.RESERVE", "k", "7", "BUCKETSIZE", "4"))
+	assert.Equal(t, "-ERR BUCKETSIZE: value must be an integer between 1 and 255, inclusive.\r\n", run(s, c, "CF.RESERVE", "k", "100", "BUCKETSIZE", "0"))
+	assert.Equal(t, "-ERR MAXITERATIONS: value must be an integer between 1 and 65535, inclusive.\r\n", run(s, c, "CF.RESERVE", "k", "100", "MAXITERATIONS", "65536"))
+	assert.Equal(t, "-ERR EXPANSION: value must be an integer between 0 and 32768, inclusive.\r\n", run(s, c, "CF.RESERVE", "k", "100", "EXPANSION", "-1"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "CF.RESERVE", "k", "100", "BUCKETSIZE"))
+
+	assert.Equal(t, "*16\r\n"+
+		"$4\r\nSize\r\n:128\r\n"+
+		"$17\r\nNumber of buckets\r\n:32\r\n"+
+		"$17\r\nNumber of filters\r\n:1\r\n"+
+		"$24\r\nNumber of items inserted\r\n:0\r\n"+
+		"$23\r\nNumber of items deleted\r\n:0\r\n"+
+		"$11\r\nBucket size\r\n:4\r\n"+
+		"$14\r\nExpansion rate\r\n:2\r\n"+
+		"$14\r\nMax iterations\r\n:10\r\n", run(s, c, "CF.INFO", "cf"))
+	assert.Equal(t, "-ERR not found\r\n", run(s, c, "CF.INFO", "missing"))
+}
+
+func TestCuckooCommands(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":1\r\n", run(s, c, "CF.ADD", "cf", "a"))
+	assert.Equal(t, ":1\r\n", run(s, c, "CF.ADD", "cf", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "CF.ADDNX", "cf", "a"))
+	assert.Equal(t, ":1\r\n", run(s, c, "CF.ADDNX", "cf", "b"))
+	assert.Equal(t, ":2\r\n", run(s, c, "CF.COUNT", "cf", "a"))
+	assert.Equal(t, ":1\r\n", run(s, c, "CF.EXISTS", "cf", "b"))
+	assert.Equal(t, "*3\r\n:1\r\n:1\r\n:0\r\n", run(s, c, "CF.MEXISTS", "cf", "a", "b", "c"))
+
+	assert.Equal(t, ":1\r\n", run(s, c, "CF.DEL", "cf", "a"))
+	assert.Equal(t, ":1\r\n", run(s, c, "CF.COUNT", "cf", "a"))
+	assert.Equal(t, ":1\r\n", run(s, c, "CF.DEL", "cf", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "CF.DEL", "cf", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "CF.EXISTS", "cf", "a"))
+	assert.Equal(t, "-ERR not found\r\n", run(s, c, "CF.DEL", "missing", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "CF.COUNT", "missing", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "CF.EXISTS", "missing", "a"))
+
+	// the default filter holds 1024 items in 512 buckets
+	info := run(s, c, "CF.INFO", "cf")
+	assert.Contains(t, info, "$17\r\nNumber of buckets\r\n:512\r\n")
+	assert.Contains(t, info, "$24\r\nNumber of items inserted\r\n:1\r\n")
+	assert.Contains(t, info, "$23\r\nNumber of items deleted\r\n:2\r\n")
+
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, wrongType, run(s, c, "CF.ADD", "str", "a"))
+	assert.Equal(t, wrongType, run(s, c, "CF.EXISTS", "str", "a"))
+	assert.Equal(t, "+MBbloomCF\r\n", run(s, c, "TYPE", "cf"))
+}
+
+func TestCuckooInsert(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, "-ERR not found\r\n", run(s, c, "CF.INSERT", "cf", "NOCREATE", "ITEMS", "a"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'cf.insert' command\r\n", run(s, c, "CF.INSERT", "cf", "CAPACITY", "10", "ITEMS"))
+	assert.Equal(t, "-ERR Bad capacity\r\n", run(s, c, "CF.INSERT", "cf", "CAPACITY", "0", "ITEMS", "a"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "CF.INSERT", "cf", "ERROR", "0.1", "ITEMS", "a"))
+	assert.Equal(t, "*2\r\n:1\r\n:1\r\n", run(s, c, "CF.INSERT", "cf", "CAPACITY", "100", "ITEMS", "a", "a"))
+	assert.Equal(t, ":2\r\n", run(s, c, "CF.COUNT", "cf", "a"))
+	assert.Contains(t, run(s, c, "CF.INFO", "cf"), "$17\r\nNumber of buckets\r\n:64\r\n")
+
+	// a filter that can't expand reports the items it is too full for
+	assert.Equal(t, "+OK\r\n", run(s, c, "CF.RESERVE", "fixed", "4", "EXPANSION", "0"))
+	args := []string{"CF.INSERT", "fixed", "ITEMS"}
+	for i := 0; i < 20; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	assert.Contains(t, run(s, c, args...), ":-1\r\n")
+	assert.Equal(t, "-ERR Filter is full\r\n", run(s, c, "CF.ADD", "fixed", "new"))
+}
+
+func TestCuckooBounds(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, "-ERR filter would exceed the maximum size\r\n", run(s, c, "CF.RESERVE", "k", "9223372036854775808"))
+	assert.Equal(t, "-ERR filter would exceed the maximum size\r\n", run(s, c, "CF.INSERT", "k", "CAPACITY", "1099511627776", "ITEMS", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "k"))
+
+	// the filter refuses the items needing a sub-filter past the maximum
+	assert.Equal(t, "+OK\r\n", run(s, c, "CF.RESERVE", "cf", "2", "BUCKETSIZE", "1", "MAXITERATIONS", "1"))
+	added := ""
+	for i := 0; i < 10000 && added != "-ERR filter would exceed the maximum size\r\n"; i++ {
+		added = run(s, c, "CF.ADD", "cf", strconv.Itoa(i))
+	}
+	assert.Equal(t, "-ERR filter would exceed the maximum size\r\n", added)
+	assert.Contains(t, run(s, c, "CF.INFO", "cf"), "$17\r\nNumber of filters\r\n:256\r\n")
+	assert.Contains(t, run(s, c, "CF.INSERT", "cf", "ITEMS", "x", "y", "z"), "-ERR filter would exceed the maximum size\r\n")
+}
@@ -0,0 +1,25 @@
+package core
+
+import "sync/atomic"
+
+var nextClientID int64
+
+// Client is the state a connection keeps between two commands
+type Client struct {
+	ID     int64
+	Reader *RespReader
+	Proto  int    // RESP version negotiated with HELLO
+	Name   string // set with HELLO ... SETNAME
+
+	// blocked is where a blocking command of the client waits, nil when
+	// it can't, see BlockedClients
+	blocked *blockedClient
+}
+
+func NewClient() *Client {
+	return &Client{
+		ID:     atomic.AddInt64(&nextClientID, 1),
+		Reader: NewRespReader(),
+		Proto:  RESP2,
+	}
+}
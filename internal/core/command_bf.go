@@ -1,65 +1,379 @@
 package core
 
 import (
-	"goredis-lite/internal/constant"
-	"goredis-lite/internal/data_structure"
 	"errors"
 	"fmt"
+	"goredis-lite/internal/config"
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
+	"slices"
 	"strconv"
+	"strings"
+)
+
+var (
+	errBloomNotFound = errors.New("ERR not found")
+	errBloomTooLarge = errors.New("ERR " + data_structure.ErrBloomTooLarge.Error())
 )
 
-func cmdBFRESERVE(args []string) []byte {
-	if !(len(args) == 3 || len(args) == 5) {
+// bloomParams are the parameters of a new bloom filter
+type bloomParams struct {
+	errRate    float64
+	capacity   uint64
+	expansion  uint64 // 0 when not given
+	nonScaling bool
+}
+
+// parseOption parses the EXPANSION or NONSCALING option at args[i] and
+// returns the index of the next argument, or i when args[i] is neither
+func (p *bloomParams) parseOption(args []string, i int) (int, error) {
+	switch strings.ToUpper(args[i]) {
+	case "NONSCALING":
+		p.nonScaling = true
+		return i + 1, nil
+	case "EXPANSION":
+		if i+1 == len(args) {
+			return 0, errSyntax
+		}
+		expansion, err := strconv.ParseUint(args[i+1], 10, 64)
+		if err != nil || expansion == 0 || expansion > data_structure.BloomMaxExpansion {
+			return 0, errors.New("ERR bad expansion")
+		}
+		p.expansion = expansion
+		return i + 2, nil
+	}
+	return i, nil
+}
+
+// check checks p and returns the expansion of the filter, 0 when non scaling
+func (p *bloomParams) check() (uint64, error) {
+	if !(p.errRate > 0 && p.errRate < 1) {
+		return 0, errors.New("ERR (0 < error rate range < 1)")
+	}
+	if p.capacity == 0 {
+		return 0, errors.New("ERR (capacity should be larger than 0)")
+	}
+	expansion := uint64(constant.BfDefaultExpansion)
+	switch {
+	case p.nonScaling && p.expansion != 0:
+		return 0, errors.New("ERR Non scaling filters cannot expand")
+	case p.nonScaling:
+		expansion = 0
+	case p.expansion != 0:
+		expansion = p.expansion
+	}
+	return expansion, nil
+}
+
+// bloomAdd adds items to bloom and returns the reply of each: 1 when added,
+// 0 when probably added before
+func (s *Storage) bloomAdd(bloom *data_structure.ScalableBloom, items []string) []interface{} {
+	res := make([]interface{}, len(items))
+	for i, item := range items {
+		added, err := bloom.Add(item)
+		switch {
+		case err != nil:
+			res[i] = errors.New("ERR " + err.Error())
+		case added:
+			res[i] = 1
+			s.dirty++
+		default:
+			res[i] = 0
+		}
+	}
+	return res
+}
+
+// getOrCreateBloom returns the filter at key, created with the default
+// parameters when missing
+func (s *Storage) getOrCreateBloom(key string) (*data_structure.ScalableBloom, error) {
+	bloom, err := s.getBloom(key)
+	if err != nil || bloom != nil {
+		return bloom, err
+	}
+	bloom, _ = data_structure.NewScalableBloom(constant.BfDefaultInitCapacity,
+		constant.BfDefaultErrRate, constant.BfDefaultExpansion)
+	s.add(key, data_structure.ObjBloom, bloom)
+	s.dirty++
+	return bloom, nil
+}
+
+// BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]
+func cmdBFRESERVE(s *Storage, c *Client, args []string) []byte {
+	if len(args) < 3 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.RESERVE' command"), false)
 	}
 	key := args[0]
-	errRate, err := strconv.ParseFloat(args[1], 64)
+	var p bloomParams
+	var err error
+	p.errRate, err = strconv.ParseFloat(args[1], 64)
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("error rate must be a floating point number %s", args[1])), false)
 	}
-	capacity, err := strconv.ParseUint(args[2], 10, 64)
+	p.capacity, err = strconv.ParseUint(args[2], 10, 64)
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("capacity must be an integer number %s", args[2])), false)
 	}
-	_, exist := bloomStore[key]
-	if exist {
+	for i := 3; i < len(args); {
+		next, err := p.parseOption(args, i)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if next == i {
+			return Encode(errSyntax, false)
+		}
+		i = next
+	}
+	expansion, err := p.check()
+	if err != nil {
+		return Encode(err, false)
+	}
+	if s.dictStore.Get(key) != nil {
 		return Encode(errors.New(fmt.Sprintf("Bloom filter with key '%s' already exist", key)), false)
 	}
-	bloomStore[key] = data_structure.CreateBloomFilter(capacity, errRate)
+	bloom, err := data_structure.NewScalableBloom(p.capacity, p.errRate, expansion)
+	if err != nil {
+		return Encode(errBloomTooLarge, false)
+	}
+	s.add(key, data_structure.ObjBloom, bloom)
+	s.dirty++
 	return constant.RespOk
 }
 
-func cmdBFMADD(args []string) []byte {
+// BF.ADD key item
+func cmdBFADD(s *Storage, c *Client, args []string) []byte {
+	bloom, err := s.getOrCreateBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return EncodeWithProto(s.bloomAdd(bloom, args[1:])[0], false, c.Proto)
+}
+
+// BF.MADD key item [item ...]: 1 for each item added, 0 for the ones that
+// were probably added before
+func cmdBFMADD(s *Storage, c *Client, args []string) []byte {
 	if len(args) < 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.MADD' command"), false)
 	}
-	key := args[0]
-	bloom, exist := bloomStore[key]
-	if !exist {
-		bloom = data_structure.CreateBloomFilter(constant.BfDefaultInitCapacity,
-			constant.BfDefaultErrRate)
-		bloomStore[key] = bloom
-	}
-	var res []string
-	for i := 1; i < len(args); i++ {
-		item := args[i]
-		bloom.Add(item)
-		res = append(res, "1")
-	}
-	return Encode(res, false)
+	bloom, err := s.getOrCreateBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return EncodeWithProto(s.bloomAdd(bloom, args[1:]), false, c.Proto)
+}
+
+// BF.INSERT key [CAPACITY capacity] [ERROR error] [EXPANSION expansion]
+// [NOCREATE] [NONSCALING] ITEMS item [item ...]: the parameters are used
+// only when the filter is created
+func cmdBFINSERT(s *Storage, c *Client, args []string) []byte {
+	p := bloomParams{errRate: constant.BfDefaultErrRate, capacity: constant.BfDefaultInitCapacity}
+	noCreate, hasParams := false, false
+	var items []string
+	for i := 1; i < len(args) && items == nil; {
+		next, err := p.parseOption(args, i)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if next > i {
+			i = next
+			continue
+		}
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "NOCREATE":
+			noCreate = true
+			i++
+			continue
+		case option == "ITEMS":
+			items = args[i+1:]
+			continue
+		case i+1 == len(args):
+			return Encode(errSyntax, false)
+		case option == "CAPACITY":
+			if p.capacity, err = strconv.ParseUint(args[i+1], 10, 64); err != nil {
+				return Encode(errors.New("ERR Bad capacity"), false)
+			}
+		case option == "ERROR":
+			if p.errRate, err = strconv.ParseFloat(args[i+1], 64); err != nil {
+				return Encode(errors.New("ERR Bad error rate"), false)
+			}
+		default:
+			return Encode(errSyntax, false)
+		}
+		hasParams = true
+		i += 2
+	}
+	if len(items) == 0 {
+		return Encode(errors.New("ERR wrong number of arguments for 'bf.insert' command"), false)
+	}
+	if noCreate && hasParams {
+		return Encode(errors.New("ERR NOCREATE cannot be used together with CAPACITY or ERROR"), false)
+	}
+	bloom, err := s.getBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
+		if noCreate {
+			return Encode(errBloomNotFound, false)
+		}
+		expansion, err := p.check()
+		if err != nil {
+			return Encode(err, false)
+		}
+		if bloom, err = data_structure.NewScalableBloom(p.capacity, p.errRate, expansion); err != nil {
+			return Encode(errBloomTooLarge, false)
+		}
+		s.add(args[0], data_structure.ObjBloom, bloom)
+		s.dirty++
+	}
+	return EncodeWithProto(s.bloomAdd(bloom, items), false, c.Proto)
 }
 
-func cmdBFEXISTS(args []string) []byte {
+func cmdBFEXISTS(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.EXISTS' command"), false)
 	}
 	key, item := args[0], args[1]
-	bloom, exist := bloomStore[key]
-	if !exist {
+	bloom, err := s.getBloom(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
 		return constant.RespZero
 	}
 	if !bloom.Exist(item) {
 		return constant.RespZero
 	}
 	return constant.RespOne
-}
\ No newline at end of file
+}
+
+// BF.MEXISTS key item [item ...]
+func cmdBFMEXISTS(s *Storage, c *Client, args []string) []byte {
+	bloom, err := s.getBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	for i, item := range args[1:] {
+		res[i] = 0
+		if bloom != nil && bloom.Exist(item) {
+			res[i] = 1
+		}
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// BF.CARD key: the number of items added, 0 when the key is missing
+func cmdBFCARD(s *Storage, c *Client, args []string) []byte {
+	bloom, err := s.getBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
+		return constant.RespZero
+	}
+	return Encode(int64(bloom.Count()), false)
+}
+
+// BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION | HASHES | BITS]
+func cmdBFINFO(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 2 {
+		return Encode(errors.New("ERR wrong number of arguments for 'bf.info' command"), false)
+	}
+	bloom, err := s.getBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
+		return Encode(errBloomNotFound, false)
+	}
+	var expansion interface{} // nil for a non scaling filter
+	if bloom.Expansion != 0 {
+		expansion = int64(bloom.Expansion)
+	}
+	info := Map{
+		"Capacity", int64(bloom.Capacity()),
+		"Size", int64(bloom.Bits() / 8),
+		"Number of filters", bloom.Layers(),
+		"Number of items inserted", int64(bloom.Count()),
+		"Expansion rate", expansion,
+		"Hashes", bloom.Hashes(),
+		"Bits", int64(bloom.Bits()),
+	}
+	if len(args) == 1 {
+		return EncodeWithProto(info, false, c.Proto)
+	}
+	// the options follow the order of the fields of info
+	options := []string{"CAPACITY", "SIZE", "FILTERS", "ITEMS", "EXPANSION", "HASHES", "BITS"}
+	i := slices.Index(options, strings.ToUpper(args[1]))
+	if i < 0 {
+		return Encode(errors.New("ERR Invalid information value"), false)
+	}
+	return EncodeWithProto([]interface{}{info[2*i+1]}, false, c.Proto)
+}
+
+// BF.SCANDUMP key iterator: the first call, with iterator 0, returns the
+// parameters of the filter, the next ones its bit arrays in chunks. The
+// iterator returned is 0 once done.
+func cmdBFSCANDUMP(s *Storage, c *Client, args []string) []byte {
+	iter, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || iter < 0 {
+		return Encode(errors.New("ERR invalid iterator"), false)
+	}
+	bloom, err := s.getBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
+		return Encode(errBloomNotFound, false)
+	}
+	if iter == 0 {
+		return EncodeWithProto([]interface{}{int64(1), string(bloom.MarshalHeader())}, false, c.Proto)
+	}
+	// iterators past the header are 1 + the offset of the next chunk
+	chunk := bloom.Chunk(uint64(iter-1), config.BfScanDumpChunkSize)
+	if len(chunk) == 0 {
+		return EncodeWithProto([]interface{}{int64(0), ""}, false, c.Proto)
+	}
+	return EncodeWithProto([]interface{}{iter + int64(len(chunk)), string(chunk)}, false, c.Proto)
+}
+
+// BF.LOADCHUNK key iterator data: restores a filter from the replies of
+// BF.SCANDUMP, the first one creating key
+func cmdBFLOADCHUNK(s *Storage, c *Client, args []string) []byte {
+	iter, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || iter <= 0 {
+		return Encode(errors.New("ERR invalid iterator"), false)
+	}
+	bloom, err := s.getBloom(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	data := args[2]
+	if iter == 1 {
+		if bloom != nil {
+			return Encode(errors.New("ERR item exists"), false)
+		}
+		bloom = &data_structure.ScalableBloom{}
+		if err := bloom.UnmarshalHeader([]byte(data)); err != nil {
+			return Encode(errors.New("ERR received bad data"), false)
+		}
+		s.add(args[0], data_structure.ObjBloom, bloom)
+		s.dirty++
+		return constant.RespOk
+	}
+	if bloom == nil {
+		return Encode(errBloomNotFound, false)
+	}
+	// iter is the iterator returned with data, 1 + the offset following it
+	offset := iter - 1 - int64(len(data))
+	if offset < 0 {
+		return Encode(errors.New("ERR invalid iterator"), false)
+	}
+	if err := bloom.LoadChunk(uint64(offset), []byte(data)); err != nil {
+		return Encode(errors.New("ERR invalid offset - no link found"), false)
+	}
+	s.dirty++
+	return constant.RespOk
+}
@@ -0,0 +1,446 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"goredis-lite/internal/config"
+)
+
+// Bitmaps are strings whose bits are numbered from the most significant bit
+// of the first byte, like in Redis. Writes grow the string with zero bytes,
+// and keep it as a []byte they modify in place, see getBitmap.
+
+var errBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+
+// parseBitOffset parses the offset of a field of width bits. When hash is
+// set, "#N" stands for the Nth field of that width.
+func parseBitOffset(arg string, hash bool, width int) (int64, error) {
+	multiplier := int64(1)
+	if hash && strings.HasPrefix(arg, "#") {
+		arg, multiplier = arg[1:], int64(width)
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/multiplier {
+		return 0, errBitOffset
+	}
+	offset := n * multiplier
+	if (offset+int64(width)-1)>>3 >= int64(config.ProtoMaxBulkLen) {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+
+// getField returns the field of width bits at offset in buf, sign extended
+// when signed. Bits past the end of buf read as 0.
+func getField(buf []byte, offset int64, width int, signed bool) int64 {
+	var v uint64
+	for i := int64(0); i < int64(width); i++ {
+		pos := offset + i
+		var bit uint64
+		if pos>>3 < int64(len(buf)) {
+			bit = uint64(buf[pos>>3]>>(7-pos&7)) & 1
+		}
+		v = v<<1 | bit
+	}
+	if signed && width < 64 && v&(1<<(width-1)) != 0 {
+		v |= math.MaxUint64 << width
+	}
+	return int64(v)
+}
+
+// setField writes the width lowest bits of value at offset in buf, which
+// must be large enough
+func setField(buf []byte, offset int64, width int, value int64) {
+	for i := 0; i < width; i++ {
+		pos := offset + int64(i)
+		mask := byte(1) << (7 - pos&7)
+		if uint64(value)>>(width-1-i)&1 == 1 {
+			buf[pos>>3] |= mask
+		} else {
+			buf[pos>>3] &^= mask
+		}
+	}
+}
+
+// growBits returns buf grown with zero bytes to hold size bits
+func growBits(buf []byte, size int64) []byte {
+	if n := int((size + 7) / 8); n > len(buf) {
+		buf = append(buf, make([]byte, n-len(buf))...)
+	}
+	return buf
+}
+
+// SETBIT key offset value
+func cmdSETBIT(s *Storage, c *Client, args []string) []byte {
+	offset, err := parseBitOffset(args[1], false, 1)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if args[2] != "0" && args[2] != "1" {
+		return Encode(errors.New("ERR bit is not an integer or out of range"), false)
+	}
+	obj, buf, err := s.getBitmap(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	buf = growBits(buf, offset+1)
+	old := getField(buf, offset, 1, false)
+	setField(buf, offset, 1, int64(args[2][0]-'0'))
+	s.updateString(args[0], obj, buf)
+	return Encode(old, false)
+}
+
+// GETBIT key offset
+func cmdGETBIT(s *Storage, c *Client, args []string) []byte {
+	offset, err := parseBitOffset(args[1], false, 1)
+	if err != nil {
+		return Encode(err, false)
+	}
+	_, buf, err := s.getBitmap(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(getField(buf, offset, 1, false), false)
+}
+
+// parseBitRange parses the [start [end [BYTE | BIT]]] arguments of BITCOUNT
+// and BITPOS, negative indexes counting from the end of a string of size
+// bytes, into the first and last bits of the range. The range is empty when
+// first > last.
+func parseBitRange(args []string, size int64) (first, last int64, err error) {
+	unit := int64(8) // bits per index
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BYTE":
+		case "BIT":
+			unit = 1
+		default:
+			return 0, 0, errSyntax
+		}
+	}
+	total := size * 8 / unit
+	start, end := int64(0), total-1
+	if len(args) > 0 {
+		if start, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+			return 0, 0, errNotInteger
+		}
+	}
+	if len(args) > 1 {
+		if end, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return 0, 0, errNotInteger
+		}
+	}
+	if start < 0 {
+		start = max(total+start, 0)
+	}
+	if end < 0 {
+		end = max(total+end, 0)
+	}
+	end = min(end, total-1)
+	if start > end {
+		return 1, 0, nil
+	}
+	return start * unit, end*unit + unit - 1, nil
+}
+
+// BITCOUNT key [start end [BYTE | BIT]]
+func cmdBITCOUNT(s *Storage, c *Client, args []string) []byte {
+	if len(args) == 2 || len(args) > 4 {
+		return Encode(errSyntax, false)
+	}
+	_, value, err := s.getBitmap(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	first, last, err := parseBitRange(args[1:], int64(len(value)))
+	if err != nil {
+		return Encode(err, false)
+	}
+	count := 0
+	for pos := first; pos <= last; {
+		if pos&7 == 0 && pos+7 <= last {
+			count += bits.OnesCount8(value[pos>>3])
+			pos += 8
+			continue
+		}
+		count += int(value[pos>>3]>>(7-pos&7)) & 1
+		pos++
+	}
+	return Encode(count, false)
+}
+
+// BITPOS key bit [start [end [BYTE | BIT]]]: without end, the string is
+// seen as padded with zero bits when looking for a 0
+func cmdBITPOS(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 5 {
+		return Encode(errSyntax, false)
+	}
+	if args[1] != "0" && args[1] != "1" {
+		return Encode(errors.New("ERR The bit argument must be 1 or 0."), false)
+	}
+	bit := args[1][0] - '0'
+	obj, value, err := s.getBitmap(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	first, last, err := parseBitRange(args[2:], int64(len(value)))
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		return Encode(-int(bit), false) // a 0 at 0, no 1
+	}
+	skip := byte(0) // the bytes holding no such bit
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := first; pos <= last; {
+		if pos&7 == 0 && pos+7 <= last && value[pos>>3] == skip {
+			pos += 8
+			continue
+		}
+		if (value[pos>>3]>>(7-pos&7))&1 == bit {
+			return Encode(pos, false)
+		}
+		pos++
+	}
+	if bit == 0 && len(args) < 4 && first <= last {
+		return Encode(last+1, false)
+	}
+	return Encode(-1, false)
+}
+
+// BITOP AND | OR | XOR | NOT destkey key [key ...]: missing keys and the
+// end of shorter strings read as zero bytes
+func cmdBITOP(s *Storage, c *Client, args []string) []byte {
+	op, dest, keys := strings.ToUpper(args[0]), args[1], args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(keys) != 1 {
+			return Encode(errors.New("ERR BITOP NOT must be called with a single source key."), false)
+		}
+	default:
+		return Encode(errSyntax, false)
+	}
+	values := make([]string, len(keys))
+	size := 0
+	for i, key := range keys {
+		value, _, err := s.getString(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		values[i] = value
+		size = max(size, len(value))
+	}
+	res := make([]byte, size)
+	for i := range res {
+		at := func(value string) byte {
+			if i < len(value) {
+				return value[i]
+			}
+			return 0
+		}
+		b := at(values[0])
+		for _, value := range values[1:] {
+			switch op {
+			case "AND":
+				b &= at(value)
+			case "OR":
+				b |= at(value)
+			case "XOR":
+				b ^= at(value)
+			}
+		}
+		if op == "NOT" {
+			b = ^b
+		}
+		res[i] = b
+	}
+	if size == 0 {
+		if s.dictStore.Del(dest) {
+			s.dirty++
+		}
+	} else {
+		s.setString(dest, string(res), 0, false)
+	}
+	return Encode(size, false)
+}
+
+// bitfieldOp is one subcommand of BITFIELD
+type bitfieldOp struct {
+	name     string // "GET", "SET" or "INCRBY"
+	signed   bool
+	width    int
+	offset   int64
+	value    int64  // the value of SET, the increment of INCRBY
+	overflow string // "WRAP", "SAT" or "FAIL", set by the last OVERFLOW
+}
+
+// parseBitfieldType parses a field type like i16 or u8: signed fields hold
+// up to 64 bits, unsigned ones up to 63
+func parseBitfieldType(arg string) (signed bool, width int, err error) {
+	errType := errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(arg) < 2 || (arg[0] != 'i' && arg[0] != 'u') {
+		return false, 0, errType
+	}
+	signed = arg[0] == 'i'
+	width, err = strconv.Atoi(arg[1:])
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, errType
+	}
+	return signed, width, nil
+}
+
+// parseBitfield parses the subcommands of BITFIELD, only GET ones when readOnly:
+// [GET encoding offset | [OVERFLOW WRAP | SAT | FAIL] SET encoding offset value | INCRBY encoding offset increment ...]
+func parseBitfield(args []string, readOnly bool) ([]bitfieldOp, error) {
+	var ops []bitfieldOp
+	overflow := "WRAP"
+	for i := 0; i < len(args); {
+		name := strings.ToUpper(args[i])
+		if readOnly && name != "GET" {
+			return nil, errors.New("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+		if name == "OVERFLOW" && i+1 < len(args) {
+			overflow = strings.ToUpper(args[i+1])
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				return nil, errors.New("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		}
+		argc := 3
+		if name == "SET" || name == "INCRBY" {
+			argc = 4
+		} else if name != "GET" {
+			return nil, errSyntax
+		}
+		if i+argc > len(args) {
+			return nil, errSyntax
+		}
+		op := bitfieldOp{name: name, overflow: overflow}
+		var err error
+		if op.signed, op.width, err = parseBitfieldType(args[i+1]); err != nil {
+			return nil, err
+		}
+		if op.offset, err = parseBitOffset(args[i+2], true, op.width); err != nil {
+			return nil, err
+		}
+		if argc == 4 {
+			if op.value, err = strconv.ParseInt(args[i+3], 10, 64); err != nil {
+				return nil, errNotInteger
+			}
+		}
+		ops = append(ops, op)
+		i += argc
+	}
+	return ops, nil
+}
+
+// add returns value+incr for the field type of op, wrapped or saturated by
+// its overflow mode when out of range, and whether it overflowed
+func (op *bitfieldOp) add(value, incr int64) (int64, bool) {
+	if op.signed {
+		hi := int64(math.MaxInt64)
+		if op.width < 64 {
+			hi = int64(1)<<(op.width-1) - 1
+		}
+		lo := -hi - 1
+		up := incr >= 0 && value > hi-incr
+		down := incr <= 0 && value < lo-incr
+		if !up && !down {
+			return value + incr, false
+		}
+		if op.overflow == "SAT" {
+			if up {
+				return hi, true
+			}
+			return lo, true
+		}
+		res := uint64(value) + uint64(incr)
+		if op.width < 64 {
+			mask := uint64(math.MaxUint64) << op.width
+			if res&(1<<(op.width-1)) != 0 {
+				res |= mask
+			} else {
+				res &^= mask
+			}
+		}
+		return int64(res), true
+	}
+	hi := uint64(1)<<op.width - 1
+	v := uint64(value)
+	up := v > hi || (incr > 0 && uint64(incr) > hi-v)
+	down := !up && incr < 0 && -uint64(incr) > v
+	if !up && !down {
+		return int64(v + uint64(incr)), false
+	}
+	if op.overflow == "SAT" {
+		if up {
+			return int64(hi), true
+		}
+		return 0, true
+	}
+	return int64((v + uint64(incr)) & hi), true
+}
+
+// bitfield runs BITFIELD, or BITFIELD_RO when readOnly
+func bitfield(s *Storage, c *Client, args []string, readOnly bool) []byte {
+	ops, err := parseBitfield(args[1:], readOnly)
+	if err != nil {
+		return Encode(err, false)
+	}
+	obj, buf, err := s.getBitmap(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	write := false
+	for _, op := range ops {
+		if op.name != "GET" {
+			buf = growBits(buf, op.offset+int64(op.width))
+			write = true
+		}
+	}
+	res := make([]interface{}, len(ops))
+	for i, op := range ops {
+		old := getField(buf, op.offset, op.width, op.signed)
+		if op.name == "GET" {
+			res[i] = old
+			continue
+		}
+		// SET replies the old value, INCRBY the new one
+		base, incr := old, op.value
+		if op.name == "SET" {
+			base, incr = op.value, 0
+		}
+		n, overflowed := op.add(base, incr)
+		if overflowed && op.overflow == "FAIL" {
+			continue // replies nil
+		}
+		setField(buf, op.offset, op.width, n)
+		if op.name == "SET" {
+			res[i] = old
+		} else {
+			res[i] = n
+		}
+	}
+	if write {
+		s.updateString(args[0], obj, buf)
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// BITFIELD key [GET encoding offset | [OVERFLOW WRAP | SAT | FAIL] SET encoding offset value | INCRBY encoding offset increment ...]
+func cmdBITFIELD(s *Storage, c *Client, args []string) []byte {
+	return bitfield(s, c, args, false)
+}
+
+// BITFIELD_RO key [GET encoding offset ...]
+func cmdBITFIELD_RO(s *Storage, c *Client, args []string) []byte {
+	return bitfield(s, c, args, true)
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+var errCuckooTooLarge = errors.New("ERR " + data_structure.ErrCuckooTooLarge.Error())
+
+// getOrCreateCuckoo returns the filter at key, created with capacity and
+// the default parameters when missing
+func (s *Storage) getOrCreateCuckoo(key string, capacity uint64) (*data_structure.CuckooFilter, error) {
+	cuckoo, err := s.getCuckoo(key)
+	if err != nil || cuckoo != nil {
+		return cuckoo, err
+	}
+	cuckoo, err = data_structure.NewCuckooFilter(capacity, constant.CfDefaultBucketSize,
+		constant.CfDefaultMaxIterations, constant.CfDefaultExpansion)
+	if err != nil {
+		return nil, errCuckooTooLarge
+	}
+	s.add(key, data_structure.ObjCuckoo, cuckoo)
+	s.dirty++
+	return cuckoo, nil
+}
+
+// CF.RESERVE key capacity [BUCKETSIZE bucketsize] [MAXITERATIONS maxiterations]
+// [EXPANSION expansion]
+func cmdCFRESERVE(s *Storage, c *Client, args []string) []byte {
+	capacity, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return Encode(errors.New("ERR Bad capacity"), false)
+	}
+	bucketSize := uint64(constant.CfDefaultBucketSize)
+	maxIterations := uint64(constant.CfDefaultMaxIterations)
+	expansion := uint64(constant.CfDefaultExpansion)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return Encode(errSyntax, false)
+		}
+		option := strings.ToUpper(args[i])
+		var value *uint64
+		var lo, hi uint64
+		switch option {
+		case "BUCKETSIZE":
+			value, lo, hi = &bucketSize, 1, 255
+		case "MAXITERATIONS":
+			value, lo, hi = &maxIterations, 1, 65535
+		case "EXPANSION":
+			value, lo, hi = &expansion, 0, data_structure.CuckooMaxExpansion
+		default:
+			return Encode(errSyntax, false)
+		}
+		n, err := strconv.ParseUint(args[i+1], 10, 64)
+		if err != nil || n < lo || n > hi {
+			return Encode(fmt.Errorf("ERR %s: value must be an integer between %d and %d, inclusive.", option, lo, hi), false)
+		}
+		*value = n
+	}
+	if capacity < 2*bucketSize {
+		return Encode(errors.New("ERR Capacity must be at least (BucketSize * 2)"), false)
+	}
+	if s.dictStore.Get(args[0]) != nil {
+		return Encode(errors.New("ERR item exists"), false)
+	}
+	cuckoo, err := data_structure.NewCuckooFilter(capacity, int(bucketSize), int(maxIterations), expansion)
+	if err != nil {
+		return Encode(errCuckooTooLarge, false)
+	}
+	s.add(args[0], data_structure.ObjCuckoo, cuckoo)
+	s.dirty++
+	return constant.RespOk
+}
+
+// CF.ADD key item: the filter is created when missing. An item can be
+// added more than once.
+func cmdCFADD(s *Storage, c *Client, args []string) []byte {
+	cuckoo, err := s.getOrCreateCuckoo(args[0], constant.CfDefaultCapacity)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if err := cuckoo.Add(args[1]); err != nil {
+		return Encode(errors.New("ERR "+err.Error()), false)
+	}
+	s.dirty++
+	return constant.RespOne
+}
+
+// CF.ADDNX key item: 1 when item is added, 0 when it was probably added
+// before
+func cmdCFADDNX(s *Storage, c *Client, args []string) []byte {
+	cuckoo, err := s.getOrCreateCuckoo(args[0], constant.CfDefaultCapacity)
+	if err != nil {
+		return Encode(err, false)
+	}
+	added, err := cuckoo.AddNX(args[1])
+	switch {
+	case err != nil:
+		return Encode(errors.New("ERR "+err.Error()), false)
+	case added:
+		s.dirty++
+		return constant.RespOne
+	}
+	return constant.RespZero
+}
+
+// CF.INSERT key [CAPACITY capacity] [NOCREATE] ITEMS item [item ...]: 1 for
+// each item added, -1 for the ones the filter is too full for, an error for
+// the ones needing a sub-filter past the maximum size. The capacity is used
+// only when the filter is created.
+func cmdCFINSERT(s *Storage, c *Client, args []string) []byte {
+	capacity := uint64(constant.CfDefaultCapacity)
+	noCreate := false
+	var items []string
+	for i := 1; i < len(args) && items == nil; {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "NOCREATE":
+			noCreate = true
+			i++
+		case option == "ITEMS":
+			items = args[i+1:]
+		case option == "CAPACITY" && i+1 < len(args):
+			var err error
+			if capacity, err = strconv.ParseUint(args[i+1], 10, 64); err != nil || capacity == 0 {
+				return Encode(errors.New("ERR Bad capacity"), false)
+			}
+			i += 2
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	if len(items) == 0 {
+		return Encode(errors.New("ERR wrong number of arguments for 'cf.insert' command"), false)
+	}
+	cuckoo, err := s.getCuckoo(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cuckoo == nil {
+		if noCreate {
+			return Encode(errBloomNotFound, false)
+		}
+		if cuckoo, err = s.getOrCreateCuckoo(args[0], capacity); err != nil {
+			return Encode(err, false)
+		}
+	}
+	res := make([]interface{}, len(items))
+	for i, item := range items {
+		switch err := cuckoo.Add(item); err {
+		case nil:
+			res[i] = 1
+			s.dirty++
+		case data_structure.ErrCuckooFull:
+			res[i] = -1
+		default:
+			res[i] = errCuckooTooLarge
+		}
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// CF.EXISTS key item
+func cmdCFEXISTS(s *Storage, c *Client, args []string) []byte {
+	cuckoo, err := s.getCuckoo(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cuckoo == nil || !cuckoo.Exist(args[1]) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+// CF.MEXISTS key item [item ...]
+func cmdCFMEXISTS(s *Storage, c *Client, args []string) []byte {
+	cuckoo, err := s.getCuckoo(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	for i, item := range args[1:] {
+		res[i] = 0
+		if cuckoo != nil && cuckoo.Exist(item) {
+			res[i] = 1
+		}
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// CF.DEL key item: 1 when one occurrence of item was deleted, 0 when it
+// was not found
+func cmdCFDEL(s *Storage, c *Client, args []string) []byte {
+	cuckoo, err := s.getCuckoo(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cuckoo == nil {
+		return Encode(errBloomNotFound, false)
+	}
+	if !cuckoo.Delete(args[1]) {
+		return constant.RespZero
+	}
+	s.dirty++
+	return constant.RespOne
+}
+
+// CF.COUNT key item: how many times item was probably added, 0 when the
+// key is missing
+func cmdCFCOUNT(s *Storage, c *Client, args []string) []byte {
+	cuckoo, err := s.getCuckoo(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cuckoo == nil {
+		return constant.RespZero
+	}
+	return Encode(int64(cuckoo.Count(args[1])), false)
+}
+
+// CF.INFO key
+func cmdCFINFO(s *Storage, c *Client, args []string) []byte {
+	cuckoo, err := s.getCuckoo(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cuckoo == nil {
+		return Encode(errBloomNotFound, false)
+	}
+	return EncodeWithProto(Map{
+		"Size", int64(cuckoo.Size()),
+		"Number of buckets", int64(cuckoo.Buckets()),
+		"Number of filters", cuckoo.Layers(),
+		"Number of items inserted", int64(cuckoo.Items),
+		"Number of items deleted", int64(cuckoo.Deleted),
+		"Bucket size", cuckoo.BucketSize,
+		"Expansion rate", int64(cuckoo.Expansion),
+		"Max iterations", cuckoo.MaxIterations,
+	}, false, c.Proto)
+}
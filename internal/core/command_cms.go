@@ -10,7 +10,7 @@ import (
 	"goredis-lite/internal/data_structure"
 )
 
-func cmdCMSINITBYDIM(args []string) []byte {
+func cmdCMSINITBYDIM(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 3 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INITBYDIM' command"), false)
 	}
@@ -23,15 +23,15 @@ func cmdCMSINITBYDIM(args []string) []byte {
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("height must be a integer number %s", args[1])), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	if s.dictStore.Get(key) != nil {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
-	cmsStore[key] = data_structure.CreateCMS(uint32(width), uint32(height))
+	s.add(key, data_structure.ObjCMS, data_structure.CreateCMS(uint32(width), uint32(height)))
+	s.dirty++
 	return constant.RespOk
 }
 
-func cmdCMSINITBYPROB(args []string) []byte {
+func cmdCMSINITBYPROB(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 3 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INITBYPROB' command"), false)
 	}
@@ -50,22 +50,25 @@ func cmdCMSINITBYPROB(args []string) []byte {
 	if probability >= 1 || probability <= 0 {
 		return Encode(errors.New("CMS: invalid prob value"), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	if s.dictStore.Get(key) != nil {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
 	w, h := data_structure.CalcCMSDim(errRate, probability)
-	cmsStore[key] = data_structure.CreateCMS(w, h)
+	s.add(key, data_structure.ObjCMS, data_structure.CreateCMS(w, h))
+	s.dirty++
 	return constant.RespOk
 }
 
-func cmdCMSINCRBY(args []string) []byte {
+func cmdCMSINCRBY(s *Storage, c *Client, args []string) []byte {
 	if len(args) < 3 || len(args)%2 == 0 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INCBY' command"), false)
 	}
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := s.getCMS(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -76,6 +79,7 @@ func cmdCMSINCRBY(args []string) []byte {
 			return Encode(errors.New(fmt.Sprintf("increment must be a non negative integer number %s", args[1])), false)
 		}
 		count := cms.IncrBy(item, uint32(value))
+		s.dirty++
 		if count == math.MaxUint32 {
 			res = append(res, "CMS: INCRBY overflow")
 			continue
@@ -85,13 +89,16 @@ func cmdCMSINCRBY(args []string) []byte {
 	return Encode(res, false)
 }
 
-func cmdCMSQUERY(args []string) []byte {
+func cmdCMSQUERY(s *Storage, c *Client, args []string) []byte {
 	if len(args) < 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.QUERY' command"), false)
 	}
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := s.getCMS(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"goredis-lite/internal/constant"
+)
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func cmdHELLO(s *Storage, c *Client, args []string) []byte {
+	proto := c.Proto
+	if len(args) > 0 {
+		ver, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return Encode(errors.New("ERR Protocol version is not an integer or out of range"), false)
+		}
+		if ver != RESP2 && ver != RESP3 {
+			return Encode(errors.New("NOPROTO unsupported protocol version"), false)
+		}
+		proto = int(ver)
+	}
+
+	name := c.Name
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "AUTH":
+			// there is no ACL, any credentials are accepted
+			if i+2 >= len(args) {
+				return Encode(errors.New("ERR Syntax error in HELLO option 'AUTH'"), false)
+			}
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return Encode(errors.New("ERR Syntax error in HELLO option 'SETNAME'"), false)
+			}
+			name = args[i+1]
+			i++
+		default:
+			return Encode(fmt.Errorf("ERR Syntax error in HELLO option '%s'", args[i]), false)
+		}
+	}
+
+	c.Proto = proto
+	c.Name = name
+	return EncodeWithProto(Map{
+		"server", "redis",
+		"version", constant.ServerVersion,
+		"proto", c.Proto,
+		"id", c.ID,
+		"mode", "standalone",
+		"role", "master",
+		"modules", []interface{}{},
+	}, false, c.Proto)
+}
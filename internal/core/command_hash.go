@@ -0,0 +1,296 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
+)
+
+// getOrCreateHash returns the hash at key, created empty if missing
+func (s *Storage) getOrCreateHash(key string) (*data_structure.Hash, error) {
+	hash, err := s.getHash(key)
+	if hash == nil && err == nil {
+		hash = data_structure.NewHash()
+		s.add(key, data_structure.ObjHash, hash)
+	}
+	return hash, err
+}
+
+// HSET key field value [field value ...]
+func cmdHSET(s *Storage, c *Client, args []string) []byte {
+	if len(args)%2 == 0 {
+		return Encode(errors.New("ERR wrong number of arguments for 'hset' command"), false)
+	}
+	hash, err := s.getOrCreateHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		if hash.Set(args[i], args[i+1]) {
+			added++
+		}
+	}
+	s.dirty += int64(len(args) / 2)
+	return Encode(added, false)
+}
+
+// HSETNX key field value
+func cmdHSETNX(s *Storage, c *Client, args []string) []byte {
+	hash, err := s.getOrCreateHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if _, exist := hash.Get(args[1]); exist {
+		return constant.RespZero
+	}
+	hash.Set(args[1], args[2])
+	s.dirty++
+	return constant.RespOne
+}
+
+// HGET key field
+func cmdHGET(s *Storage, c *Client, args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	value, exist := hash.Get(args[1])
+	if !exist {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	return Encode(value, false)
+}
+
+// HMGET key field [field ...]
+func cmdHMGET(s *Storage, c *Client, args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	for i, field := range args[1:] {
+		if hash == nil {
+			continue
+		}
+		if value, exist := hash.Get(field); exist {
+			res[i] = value
+		}
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// HDEL key field [field ...]
+func cmdHDEL(s *Storage, c *Client, args []string) []byte {
+	key := args[0]
+	hash, err := s.getHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	deleted := 0
+	for _, field := range args[1:] {
+		if hash.Del(field) {
+			deleted++
+		}
+	}
+	if hash.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+	s.dirty += int64(deleted)
+	return Encode(deleted, false)
+}
+
+// HEXISTS key field
+func cmdHEXISTS(s *Storage, c *Client, args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	if _, exist := hash.Get(args[1]); !exist {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+// HLEN key
+func cmdHLEN(s *Storage, c *Client, args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	return Encode(hash.Len(), false)
+}
+
+// HSTRLEN key field
+func cmdHSTRLEN(s *Storage, c *Client, args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	value, _ := hash.Get(args[1])
+	return Encode(len(value), false)
+}
+
+// hashPairs returns the field, value pairs of the hash at key, nil if missing
+func (s *Storage) hashPairs(key string) ([]string, error) {
+	hash, err := s.getHash(key)
+	if hash == nil {
+		return nil, err
+	}
+	return hash.Pairs(), nil
+}
+
+// HKEYS key
+func cmdHKEYS(s *Storage, c *Client, args []string) []byte {
+	pairs, err := s.hashPairs(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	fields := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		fields = append(fields, pairs[i])
+	}
+	return Encode(fields, false)
+}
+
+// HVALS key
+func cmdHVALS(s *Storage, c *Client, args []string) []byte {
+	pairs, err := s.hashPairs(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	values := make([]string, 0, len(pairs)/2)
+	for i := 1; i < len(pairs); i += 2 {
+		values = append(values, pairs[i])
+	}
+	return Encode(values, false)
+}
+
+// HGETALL key
+func cmdHGETALL(s *Storage, c *Client, args []string) []byte {
+	pairs, err := s.hashPairs(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make(Map, len(pairs))
+	for i, p := range pairs {
+		res[i] = p
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// HINCRBY key field increment
+func cmdHINCRBY(s *Storage, c *Client, args []string) []byte {
+	incr, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return Encode(errors.New("ERR value is not an integer or out of range"), false)
+	}
+	hash, err := s.getOrCreateHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current int64
+	if value, exist := hash.Get(args[1]); exist {
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Encode(errors.New("ERR hash value is not an integer"), false)
+		}
+	}
+	if (incr > 0 && current > math.MaxInt64-incr) || (incr < 0 && current < math.MinInt64-incr) {
+		return Encode(errors.New("ERR increment or decrement would overflow"), false)
+	}
+	current += incr
+	hash.Set(args[1], strconv.FormatInt(current, 10))
+	s.dirty++
+	return Encode(current, false)
+}
+
+// HINCRBYFLOAT key field increment
+func cmdHINCRBYFLOAT(s *Storage, c *Client, args []string) []byte {
+	incr, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(incr) || math.IsInf(incr, 0) {
+		return Encode(errors.New("ERR value is not a valid float"), false)
+	}
+	hash, err := s.getOrCreateHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current float64
+	if value, exist := hash.Get(args[1]); exist {
+		current, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return Encode(errors.New("ERR hash value is not a float"), false)
+		}
+	}
+	current += incr
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return Encode(errors.New("ERR increment would produce NaN or Infinity"), false)
+	}
+	value := strconv.FormatFloat(current, 'f', -1, 64)
+	hash.Set(args[1], value)
+	s.dirty++
+	// replaying the increment could round differently
+	s.rewriteCommand("HSET", args[0], args[1], value)
+	return Encode(value, false)
+}
+
+// HRANDFIELD key [count [WITHVALUES]]
+func cmdHRANDFIELD(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 3 || (len(args) == 3 && strings.ToUpper(args[2]) != "WITHVALUES") {
+		return Encode(errors.New("ERR syntax error"), false)
+	}
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if len(args) == 1 {
+		if hash == nil {
+			return EncodeWithProto(nil, false, c.Proto)
+		}
+		return Encode(hash.RandomPairs(1, false)[0], false)
+	}
+
+	count, err := parseRandomCount(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	withValues := len(args) == 3
+
+	// a positive count returns distinct fields, a negative one may repeat them
+	var pairs []string
+	if hash != nil {
+		pairs = hash.RandomPairs(max(count, -count), count < 0)
+	}
+
+	res := make([]interface{}, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		switch {
+		case !withValues:
+			res = append(res, pairs[i])
+		case c.Proto == RESP3:
+			res = append(res, []interface{}{pairs[i], pairs[i+1]})
+		default:
+			res = append(res, pairs[i], pairs[i+1])
+		}
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"goredis-lite/internal/data_structure"
+)
+
+// PFADD key [element [element ...]]
+func cmdPFADD(s *Storage, c *Client, args []string) []byte {
+	hll, err := s.getHLL(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	created := hll == nil
+	if created {
+		hll = data_structure.NewHyperLogLog()
+		s.add(args[0], data_structure.ObjString, hll)
+	}
+	if hll.Add(args[1:]...) || created {
+		s.dirty++
+		return Encode(1, false)
+	}
+	return Encode(0, false)
+}
+
+// PFCOUNT key [key ...]: the cardinality of the union of several keys
+func cmdPFCOUNT(s *Storage, c *Client, args []string) []byte {
+	if len(args) == 1 {
+		hll, err := s.getHLL(args[0])
+		if err != nil {
+			return Encode(err, false)
+		}
+		if hll == nil {
+			return Encode(0, false)
+		}
+		return Encode(hll.Count(), false)
+	}
+	union := data_structure.NewHyperLogLog()
+	for _, key := range args {
+		hll, err := s.getHLL(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if hll != nil {
+			union.Merge(hll)
+		}
+	}
+	return Encode(union.Count(), false)
+}
+
+// PFMERGE destkey [sourcekey [sourcekey ...]]: destkey keeps its TTL
+func cmdPFMERGE(s *Storage, c *Client, args []string) []byte {
+	// check every key before writing any
+	hlls := make([]*data_structure.HyperLogLog, len(args))
+	for i, key := range args {
+		hll, err := s.getHLL(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		hlls[i] = hll
+	}
+	dest := hlls[0]
+	if dest == nil {
+		dest = data_structure.NewHyperLogLog()
+		s.add(args[0], data_structure.ObjString, dest)
+	}
+	for _, hll := range hlls[1:] {
+		if hll != nil {
+			dest.Merge(hll)
+		}
+	}
+	s.dirty++
+	return Encode("OK", true)
+}
+
+// PFDEBUG GETREG | DECODE | ENCODING | TODENSE key
+func cmdPFDEBUG(s *Storage, c *Client, args []string) []byte {
+	hll, err := s.getHLL(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hll == nil {
+		return Encode(errors.New("ERR The specified key does not exist"), false)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "GETREG":
+		// like Redis, reading the registers makes the key dense
+		if hll.ToDense() {
+			s.dirty++
+		}
+		registers := hll.Registers()
+		res := make([]interface{}, len(registers))
+		for i, rank := range registers {
+			res[i] = int(rank)
+		}
+		return EncodeWithProto(res, false, c.Proto)
+	case "DECODE":
+		if !hll.IsSparse() {
+			return Encode(errors.New("ERR HLL encoding is not sparse"), false)
+		}
+		return Encode(hll.Decode(), true)
+	case "ENCODING":
+		if hll.IsSparse() {
+			return Encode("sparse", true)
+		}
+		return Encode("dense", true)
+	case "TODENSE":
+		if hll.ToDense() {
+			s.dirty++
+			return Encode(1, false)
+		}
+		return Encode(0, false)
+	}
+	return Encode(fmt.Errorf("ERR Unknown PFDEBUG subcommand '%s'", args[0]), false)
+}
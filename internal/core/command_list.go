@@ -0,0 +1,438 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
+)
+
+var (
+	errNotInteger      = errors.New("ERR value is not an integer or out of range")
+	errNotPositive     = errors.New("ERR value is out of range, must be positive")
+	errSyntax          = errors.New("ERR syntax error")
+	errTimeoutNotFloat = errors.New("ERR timeout is not a float or out of range")
+	errTimeoutNegative = errors.New("ERR timeout is negative")
+	errTimeoutRange    = errors.New("ERR timeout is out of range")
+)
+
+// getOrCreateList returns the list at key, created empty if missing
+func (s *Storage) getOrCreateList(key string) (*data_structure.List, error) {
+	list, err := s.getList(key)
+	if list == nil && err == nil {
+		list = data_structure.NewList()
+		s.add(key, data_structure.ObjList, list)
+	}
+	return list, err
+}
+
+// deleteListIfEmpty removes key once its last element is gone
+func (s *Storage) deleteListIfEmpty(key string, list *data_structure.List) {
+	if list.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+}
+
+// popList pops an element from the head, or the tail, of the list at key.
+// exist is false when the key is missing.
+func (s *Storage) popList(key string, fromHead bool) (value string, exist bool, err error) {
+	list, err := s.getList(key)
+	if list == nil {
+		return "", false, err
+	}
+	if fromHead {
+		value, _ = list.PopHead()
+	} else {
+		value, _ = list.PopTail()
+	}
+	s.deleteListIfEmpty(key, list)
+	s.dirty++
+	return value, true, nil
+}
+
+// pushList pushes values to the head, or the tail, of the list at key and
+// marks it ready for the commands waiting for it
+func (s *Storage) pushList(key string, fromHead bool, values ...string) (int, error) {
+	list, err := s.getOrCreateList(key)
+	if err != nil {
+		return 0, err
+	}
+	if fromHead {
+		list.PushHead(values...)
+	} else {
+		list.PushTail(values...)
+	}
+	s.dirty += int64(len(values))
+	s.signalListReady(key)
+	return list.Len(), nil
+}
+
+// parseDirection parses the LEFT | RIGHT argument of LMOVE and BLMOVE
+func parseDirection(arg string) (fromHead bool, ok bool) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// parseTimeout parses the timeout of a blocking command, in seconds
+func parseTimeout(arg string) (float64, error) {
+	timeout, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+		return 0, errTimeoutNotFloat
+	}
+	if timeout < 0 {
+		return 0, errTimeoutNegative
+	}
+	if timeout > math.MaxInt64/float64(time.Second) {
+		return 0, errTimeoutRange
+	}
+	return timeout, nil
+}
+
+// LPUSH key element [element ...]
+func cmdLPUSH(s *Storage, c *Client, args []string) []byte {
+	n, err := s.pushList(args[0], true, args[1:]...)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(n, false)
+}
+
+// RPUSH key element [element ...]
+func cmdRPUSH(s *Storage, c *Client, args []string) []byte {
+	n, err := s.pushList(args[0], false, args[1:]...)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(n, false)
+}
+
+// pop implements LPOP and RPOP: key [count]
+func pop(s *Storage, c *Client, args []string, fromHead bool) []byte {
+	if len(args) == 1 {
+		value, exist, err := s.popList(args[0], fromHead)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if !exist {
+			return EncodeWithProto(nil, false, c.Proto)
+		}
+		return Encode(value, false)
+	}
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || count < 0 {
+		return Encode(errNotPositive, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return encodeNilArray(c.Proto)
+	}
+	values := make([]string, 0, min(count, int64(list.Len())))
+	for len(values) < cap(values) {
+		value, _, _ := s.popList(args[0], fromHead)
+		values = append(values, value)
+	}
+	return Encode(values, false)
+}
+
+// LPOP key [count]
+func cmdLPOP(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 2 {
+		return Encode(errors.New("ERR wrong number of arguments for 'lpop' command"), false)
+	}
+	return pop(s, c, args, true)
+}
+
+// RPOP key [count]
+func cmdRPOP(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 2 {
+		return Encode(errors.New("ERR wrong number of arguments for 'rpop' command"), false)
+	}
+	return pop(s, c, args, false)
+}
+
+// LLEN key
+func cmdLLEN(s *Storage, c *Client, args []string) []byte {
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	return Encode(list.Len(), false)
+}
+
+// LRANGE key start stop
+func cmdLRANGE(s *Storage, c *Client, args []string) []byte {
+	start, errStart := strconv.Atoi(args[1])
+	stop, errStop := strconv.Atoi(args[2])
+	if errStart != nil || errStop != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return Encode([]string{}, false)
+	}
+	return Encode(list.Range(start, stop), false)
+}
+
+// LINDEX key index
+func cmdLINDEX(s *Storage, c *Client, args []string) []byte {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	value, ok := list.Index(index)
+	if !ok {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	return Encode(value, false)
+}
+
+// LSET key index element
+func cmdLSET(s *Storage, c *Client, args []string) []byte {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return Encode(errors.New("ERR no such key"), false)
+	}
+	if !list.Set(index, args[2]) {
+		return Encode(errors.New("ERR index out of range"), false)
+	}
+	s.dirty++
+	return constant.RespOk
+}
+
+// LREM key count element
+func cmdLREM(s *Storage, c *Client, args []string) []byte {
+	count, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	removed := list.Remove(count, args[2])
+	s.deleteListIfEmpty(args[0], list)
+	s.dirty += int64(removed)
+	return Encode(removed, false)
+}
+
+// LTRIM key start stop
+func cmdLTRIM(s *Storage, c *Client, args []string) []byte {
+	start, errStart := strconv.Atoi(args[1])
+	stop, errStop := strconv.Atoi(args[2])
+	if errStart != nil || errStop != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list != nil {
+		n := list.Len()
+		list.Trim(start, stop)
+		s.deleteListIfEmpty(args[0], list)
+		s.dirty += int64(n - list.Len())
+	}
+	return constant.RespOk
+}
+
+// LINSERT key BEFORE | AFTER pivot element
+func cmdLINSERT(s *Storage, c *Client, args []string) []byte {
+	var after bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+	case "AFTER":
+		after = true
+	default:
+		return Encode(errSyntax, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	if !list.Insert(args[2], args[3], after) {
+		return Encode(-1, false)
+	}
+	s.dirty++
+	return Encode(list.Len(), false)
+}
+
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func cmdLPOS(s *Storage, c *Client, args []string) []byte {
+	rank, count, maxLen := 1, 1, 0
+	withCount := false
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return Encode(errSyntax, false)
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				return Encode(errors.New("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the last match"), false)
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return Encode(errors.New("ERR COUNT can't be negative"), false)
+			}
+			count, withCount = n, true
+		case "MAXLEN":
+			if n < 0 {
+				return Encode(errors.New("ERR MAXLEN can't be negative"), false)
+			}
+			maxLen = n
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var positions []int
+	if list != nil {
+		positions = list.Pos(args[1], rank, count, maxLen)
+	}
+	if withCount {
+		res := make([]interface{}, len(positions))
+		for i, pos := range positions {
+			res[i] = pos
+		}
+		return EncodeWithProto(res, false, c.Proto)
+	}
+	if len(positions) == 0 {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	return Encode(positions[0], false)
+}
+
+// move pops an element from source and pushes it to destination, for LMOVE
+// and BLMOVE. The reply is nil when source is missing.
+func move(s *Storage, c *Client, args []string) []byte {
+	fromHead, okFrom := parseDirection(args[2])
+	toHead, okTo := parseDirection(args[3])
+	if !okFrom || !okTo {
+		return Encode(errSyntax, false)
+	}
+	// the destination must be a list before anything is popped
+	if _, err := s.getList(args[1]); err != nil {
+		return Encode(err, false)
+	}
+	value, exist, err := s.popList(args[0], fromHead)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exist {
+		return nil
+	}
+	s.pushList(args[1], toHead, value)
+	return Encode(value, false)
+}
+
+// LMOVE source destination LEFT | RIGHT LEFT | RIGHT
+func cmdLMOVE(s *Storage, c *Client, args []string) []byte {
+	if res := move(s, c, args); res != nil {
+		return res
+	}
+	return EncodeWithProto(nil, false, c.Proto)
+}
+
+// blockingPop implements BLPOP and BRPOP: key [key ...] timeout. It pops
+// from the first non empty list and, when every list is empty, waits for
+// one of them to be pushed to, replying nothing, see BlockedClients. The
+// pop is logged as the LPOP or RPOP it amounts to.
+func blockingPop(s *Storage, c *Client, args []string, fromHead bool) []byte {
+	if _, err := parseTimeout(args[len(args)-1]); err != nil {
+		return Encode(err, false)
+	}
+	pop := func(s *Storage, key string) ([]byte, []string) {
+		value, _, _ := s.popList(key, fromHead)
+		name := "RPOP"
+		if fromHead {
+			name = "LPOP"
+		}
+		return EncodeWithProto([]interface{}{key, value}, false, c.Proto), []string{name, key}
+	}
+	keys := args[:len(args)-1]
+	for _, key := range keys {
+		list, err := s.getList(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if list != nil {
+			res, argv := pop(s, key)
+			s.rewriteCommand(argv...)
+			return res
+		}
+	}
+	s.blockPop(c, keys, pop)
+	return nil
+}
+
+// BLPOP key [key ...] timeout
+func cmdBLPOP(s *Storage, c *Client, args []string) []byte {
+	return blockingPop(s, c, args, true)
+}
+
+// BRPOP key [key ...] timeout
+func cmdBRPOP(s *Storage, c *Client, args []string) []byte {
+	return blockingPop(s, c, args, false)
+}
+
+// BLMOVE source destination LEFT | RIGHT LEFT | RIGHT timeout
+func cmdBLMOVE(s *Storage, c *Client, args []string) []byte {
+	if _, err := parseTimeout(args[4]); err != nil {
+		return Encode(err, false)
+	}
+	lmove := append([]string{"LMOVE"}, args[:4]...)
+	if res := move(s, c, args[:4]); res != nil {
+		s.rewriteCommand(lmove...)
+		return res
+	}
+	s.blockPop(c, args[:1], func(s *Storage, key string) ([]byte, []string) {
+		return move(s, c, args[:4]), lmove
+	})
+	return nil
+}
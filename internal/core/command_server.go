@@ -0,0 +1,177 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"goredis-lite/internal/constant"
+)
+
+// aclCategoriesByGroup maps command groups to their ACL category
+var aclCategoriesByGroup = map[string]string{
+	"generic":     "@keyspace",
+	"string":      "@string",
+	"list":        "@list",
+	"set":         "@set",
+	"hash":        "@hash",
+	"sorted_set":  "@sortedset",
+	"bitmap":      "@bitmap",
+	"hyperloglog": "@hyperloglog",
+	"connection":  "@connection",
+}
+
+func (spec *CommandSpec) flagNames() Set {
+	flags := Set{}
+	for _, f := range commandFlagNames {
+		if spec.Flags&f.flag != 0 {
+			flags = append(flags, SimpleString(f.name))
+		}
+	}
+	return flags
+}
+
+func (spec *CommandSpec) aclCategories() Set {
+	categories := Set{}
+	if spec.Flags&FlagWrite != 0 {
+		categories = append(categories, SimpleString("@write"))
+	}
+	if spec.Flags&FlagReadonly != 0 {
+		categories = append(categories, SimpleString("@read"))
+	}
+	if spec.Flags&FlagAdmin != 0 {
+		categories = append(categories, SimpleString("@admin"), SimpleString("@dangerous"))
+	}
+	if category, ok := aclCategoriesByGroup[spec.Group]; ok {
+		categories = append(categories, SimpleString(category))
+	}
+	if spec.Flags&FlagFast != 0 {
+		categories = append(categories, SimpleString("@fast"))
+	} else {
+		categories = append(categories, SimpleString("@slow"))
+	}
+	if spec.Flags&FlagBlocking != 0 {
+		categories = append(categories, SimpleString("@blocking"))
+	}
+	return categories
+}
+
+// info is the reply of COMMAND INFO for one command
+func (spec *CommandSpec) info() []interface{} {
+	return []interface{}{
+		spec.Name,
+		spec.Arity,
+		spec.flagNames(),
+		spec.FirstKey,
+		spec.LastKey,
+		spec.Step,
+		spec.aclCategories(),
+		spec.Tips,
+		[]interface{}{}, // key specs
+		[]interface{}{}, // subcommands
+	}
+}
+
+// docs is the reply of COMMAND DOCS for one command
+func (spec *CommandSpec) docs() Map {
+	return Map{
+		"summary", spec.Summary,
+		"since", spec.Since,
+		"group", spec.Group,
+		"complexity", spec.Complexity,
+	}
+}
+
+// COMMAND [COUNT | INFO [name ...] | DOCS [name ...] | LIST]
+func cmdCOMMAND(s *Storage, c *Client, args []string) []byte {
+	if len(args) == 0 {
+		res := make([]interface{}, len(commandList))
+		for i, spec := range commandList {
+			res[i] = spec.info()
+		}
+		return EncodeWithProto(res, false, c.Proto)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "COUNT":
+		if len(args) != 1 {
+			return Encode(errors.New("ERR wrong number of arguments for 'command|count' command"), false)
+		}
+		return Encode(len(commandList), false)
+	case "LIST":
+		if len(args) != 1 {
+			return Encode(errors.New("ERR wrong number of arguments for 'command|list' command"), false)
+		}
+		names := make([]string, len(commandList))
+		for i, spec := range commandList {
+			names[i] = spec.Name
+		}
+		return Encode(names, false)
+	case "INFO":
+		if len(args) == 1 {
+			return cmdCOMMAND(s, c, nil)
+		}
+		res := make([]interface{}, len(args)-1)
+		for i, name := range args[1:] {
+			if spec, exist := commandTable[strings.ToUpper(name)]; exist {
+				res[i] = spec.info()
+			}
+		}
+		return EncodeWithProto(res, false, c.Proto)
+	case "DOCS":
+		specs := commandList
+		if len(args) > 1 {
+			specs = nil
+			for _, name := range args[1:] {
+				if spec, exist := commandTable[strings.ToUpper(name)]; exist {
+					specs = append(specs, spec)
+				}
+			}
+		}
+		res := Map{}
+		for _, spec := range specs {
+			res = append(res, spec.Name, spec.docs())
+		}
+		return EncodeWithProto(res, false, c.Proto)
+	}
+	return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try COMMAND HELP.", args[0]), false)
+}
+
+// SAVE
+func cmdSAVE(s *Storage, c *Client, args []string) []byte {
+	if err := Save(); err != nil {
+		if err == errSaveInProgress {
+			return Encode(err, false)
+		}
+		log.Println("saving error:", err)
+		return Encode(errors.New("ERR"), false)
+	}
+	return constant.RespOk
+}
+
+// BGSAVE [SCHEDULE]
+func cmdBGSAVE(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 0 && (len(args) > 1 || strings.ToUpper(args[0]) != "SCHEDULE") {
+		return Encode(errors.New("ERR syntax error"), false)
+	}
+	if err := BackgroundSave(); err != nil {
+		return Encode(err, false)
+	}
+	return Encode("Background saving started", true)
+}
+
+// BGREWRITEAOF
+func cmdBGREWRITEAOF(s *Storage, c *Client, args []string) []byte {
+	if err := BackgroundRewriteAOF(); err != nil {
+		return Encode(err, false)
+	}
+	return Encode("Background append only file rewriting started", true)
+}
+
+// LASTSAVE
+func cmdLASTSAVE(s *Storage, c *Client, args []string) []byte {
+	rdb.mu.Lock()
+	defer rdb.mu.Unlock()
+	return Encode(rdb.lastSave.Unix(), false)
+}
@@ -1,58 +1,506 @@
 package core
 
 import (
-	"goredis-lite/internal/data_structure"
 	"errors"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
 )
 
-func cmdSADD(args []string) []byte {
+// deleteSetIfEmpty removes key once its last member is gone
+func (s *Storage) deleteSetIfEmpty(key string, set *data_structure.SimpleSet) {
+	if set.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+}
+
+// getSetOrEmpty returns the set at key, an empty set when the key is missing
+func (s *Storage) getSetOrEmpty(key string) (*data_structure.SimpleSet, error) {
+	set, err := s.getSet(key)
+	if set == nil && err == nil {
+		set = data_structure.NewSimpleSet(key)
+	}
+	return set, err
+}
+
+// getSets returns the sets at keys, empty sets for the missing ones
+func (s *Storage) getSets(keys []string) ([]*data_structure.SimpleSet, error) {
+	sets := make([]*data_structure.SimpleSet, len(keys))
+	for i, key := range keys {
+		set, err := s.getSetOrEmpty(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// encodeMembers replies members as a set, an array in RESP2
+func encodeMembers(members []string, proto int) []byte {
+	res := make(Set, len(members))
+	for i, m := range members {
+		res[i] = m
+	}
+	return EncodeWithProto(res, false, proto)
+}
+
+func cmdSADD(s *Storage, c *Client, args []string) []byte {
 	if len(args) < 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
 	}
-	key := args[0] // TODO: check key is used by other types or not
-	set, exist := setStore[key]
-	if !exist {
+	key := args[0]
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		set = data_structure.NewSimpleSet(key)
-		setStore[key] = set
+		s.add(key, data_structure.ObjSet, set)
 	}
 	count := set.Add(args[1:]...)
+	s.dirty += int64(count)
 	return Encode(count, false)
 }
 
-func cmdSREM(args []string) []byte {
+func cmdSREM(s *Storage, c *Client, args []string) []byte {
 	if len(args) < 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
-		set = data_structure.NewSimpleSet(key)
-		setStore[key] = set
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		return constant.RespZero
 	}
 	count := set.Rem(args[1:]...)
+	s.deleteSetIfEmpty(key, set)
+	s.dirty += int64(count)
 	return Encode(count, false)
 }
 
-func cmdSMEMBERS(args []string) []byte {
+func cmdSMEMBERS(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 1 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMEMBERS' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
-		return Encode(make([]string, 0), false)
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		return EncodeWithProto(Set{}, false, c.Proto)
 	}
-	return Encode(set.Members(), false)
+	return encodeMembers(set.Members(), c.Proto)
 }
 
-func cmdSISMEMBER(args []string) []byte {
+func cmdSISMEMBER(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SISMEMBER' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		return Encode(0, false)
 	}
 	return Encode(set.IsMember(args[1]), false)
-}
\ No newline at end of file
+}
+
+// SCARD key
+func cmdSCARD(s *Storage, c *Client, args []string) []byte {
+	set, err := s.getSetOrEmpty(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(set.Len(), false)
+}
+
+// SMISMEMBER key member [member ...]
+func cmdSMISMEMBER(s *Storage, c *Client, args []string) []byte {
+	set, err := s.getSetOrEmpty(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	for i, member := range args[1:] {
+		res[i] = set.IsMember(member)
+	}
+	return Encode(res, false)
+}
+
+// Set operations
+const (
+	setUnion = iota
+	setInter
+	setDiff
+)
+
+// setOp returns the union, intersection or difference of the sets at keys
+func (s *Storage) setOp(op int, keys []string) ([]string, error) {
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	switch op {
+	case setUnion:
+		seen := make(map[string]struct{})
+		for _, set := range sets {
+			for _, member := range set.Members() {
+				if _, exist := seen[member]; !exist {
+					seen[member] = struct{}{}
+					res = append(res, member)
+				}
+			}
+		}
+	case setInter:
+		res = intersect(sets, 0)
+	case setDiff:
+		for _, member := range sets[0].Members() {
+			if !slices.ContainsFunc(sets[1:], func(set *data_structure.SimpleSet) bool {
+				return set.IsMember(member) == 1
+			}) {
+				res = append(res, member)
+			}
+		}
+	}
+	return res, nil
+}
+
+// intersect returns the members found in every set, at most limit of them
+// unless limit is 0. It walks the smallest set and looks its members up in
+// the others, from the smallest to the largest.
+func intersect(sets []*data_structure.SimpleSet, limit int) []string {
+	sets = slices.Clone(sets)
+	slices.SortFunc(sets, func(a, b *data_structure.SimpleSet) int { return a.Len() - b.Len() })
+	var res []string
+	for _, member := range sets[0].Members() {
+		if limit > 0 && len(res) == limit {
+			break
+		}
+		if !slices.ContainsFunc(sets[1:], func(set *data_structure.SimpleSet) bool {
+			return set.IsMember(member) == 0
+		}) {
+			res = append(res, member)
+		}
+	}
+	return res
+}
+
+// replySetOp replies the result of a set operation on keys
+func (s *Storage) replySetOp(c *Client, op int, keys []string) []byte {
+	members, err := s.setOp(op, keys)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return encodeMembers(members, c.Proto)
+}
+
+// storeSetOp replaces dest, whatever its type, by the result of a set
+// operation on keys. dest is deleted when the result is empty.
+func (s *Storage) storeSetOp(dest string, op int, keys []string) []byte {
+	members, err := s.setOp(op, keys)
+	if err != nil {
+		return Encode(err, false)
+	}
+	// an empty result changes the keyspace only when dest existed
+	if s.dictStore.Del(dest) || len(members) > 0 {
+		s.dirty++
+	}
+	if len(members) > 0 {
+		set := data_structure.NewSimpleSet(dest)
+		set.Add(members...)
+		s.add(dest, data_structure.ObjSet, set)
+	}
+	return Encode(len(members), false)
+}
+
+// SUNION key [key ...]
+func cmdSUNION(s *Storage, c *Client, args []string) []byte {
+	return s.replySetOp(c, setUnion, args)
+}
+
+// SINTER key [key ...]
+func cmdSINTER(s *Storage, c *Client, args []string) []byte {
+	return s.replySetOp(c, setInter, args)
+}
+
+// SDIFF key [key ...]
+func cmdSDIFF(s *Storage, c *Client, args []string) []byte {
+	return s.replySetOp(c, setDiff, args)
+}
+
+// SUNIONSTORE destination key [key ...]
+func cmdSUNIONSTORE(s *Storage, c *Client, args []string) []byte {
+	return s.storeSetOp(args[0], setUnion, args[1:])
+}
+
+// SINTERSTORE destination key [key ...]
+func cmdSINTERSTORE(s *Storage, c *Client, args []string) []byte {
+	return s.storeSetOp(args[0], setInter, args[1:])
+}
+
+// SDIFFSTORE destination key [key ...]
+func cmdSDIFFSTORE(s *Storage, c *Client, args []string) []byte {
+	return s.storeSetOp(args[0], setDiff, args[1:])
+}
+
+// SINTERCARD numkeys key [key ...] [LIMIT limit]
+func cmdSINTERCARD(s *Storage, c *Client, args []string) []byte {
+	keys, limit, err := parseInterCard(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(len(intersect(sets, limit)), false)
+}
+
+// SMOVE source destination member
+func cmdSMOVE(s *Storage, c *Client, args []string) []byte {
+	src, dest, member := args[0], args[1], args[2]
+	set, err := s.getSet(src)
+	if err != nil {
+		return Encode(err, false)
+	}
+	destSet, err := s.getSet(dest)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil || set.IsMember(member) == 0 {
+		return constant.RespZero
+	}
+	if src != dest {
+		set.Rem(member)
+		s.deleteSetIfEmpty(src, set)
+		if destSet == nil {
+			destSet = data_structure.NewSimpleSet(dest)
+			s.add(dest, data_structure.ObjSet, destSet)
+		}
+		destSet.Add(member)
+		s.dirty++
+	}
+	return Encode(1, false)
+}
+
+// randomMembers returns count distinct members of set in random order, all
+// of them when count exceeds its size. Like Redis, it copies the set only
+// when count is close to its size, and otherwise picks random members until
+// count distinct ones were found.
+func randomMembers(set *data_structure.SimpleSet, count int) []string {
+	count = min(count, set.Len())
+	if count > 1 && count*3 > set.Len() {
+		members := set.Members()
+		for i := 0; i < count; i++ {
+			j := i + rand.Intn(len(members)-i)
+			members[i], members[j] = members[j], members[i]
+		}
+		return members[:count]
+	}
+	members := make([]string, 0, count)
+	picked := make(map[string]struct{}, count)
+	for len(members) < count {
+		member := set.RandomMember()
+		if _, ok := picked[member]; !ok {
+			picked[member] = struct{}{}
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// SPOP key [count]
+func cmdSPOP(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 2 {
+		return Encode(errSyntax, false)
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return Encode(errNotPositive, false)
+		}
+		count = n
+	}
+	set, err := s.getSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		if len(args) == 1 {
+			return EncodeWithProto(nil, false, c.Proto)
+		}
+		return encodeMembers(nil, c.Proto)
+	}
+	members := randomMembers(set, count)
+	if len(members) > 0 {
+		set.Rem(members...)
+		s.deleteSetIfEmpty(args[0], set)
+		s.dirty += int64(len(members))
+		// replaying SPOP would pop other members
+		s.rewriteCommand(append([]string{"SREM", args[0]}, members...)...)
+	}
+	if len(args) == 1 {
+		return Encode(members[0], false)
+	}
+	return encodeMembers(members, c.Proto)
+}
+
+// SRANDMEMBER key [count]: a negative count allows the same member to be
+// returned several times
+func cmdSRANDMEMBER(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 2 {
+		return Encode(errSyntax, false)
+	}
+	set, err := s.getSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if len(args) == 1 {
+		if set == nil {
+			return EncodeWithProto(nil, false, c.Proto)
+		}
+		return Encode(set.RandomMember(), false)
+	}
+	count, err := parseRandomCount(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil || count == 0 {
+		return Encode([]string{}, false)
+	}
+	if count > 0 {
+		return Encode(randomMembers(set, count), false)
+	}
+	res := make([]string, -count)
+	for i := range res {
+		res[i] = set.RandomMember()
+	}
+	return Encode(res, false)
+}
+
+// SSCAN key cursor [MATCH pattern] [COUNT count]
+//
+// A small set, still in a compact encoding, is returned in one reply. A
+// hashtable is returned about count members at a time, the cursor being the
+// next bucket to visit: a member present during the whole iteration is
+// returned, whatever was added or removed between calls.
+func cmdSSCAN(s *Storage, c *Client, args []string) []byte {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return Encode(errors.New("ERR invalid cursor"), false)
+	}
+	pattern, count := "*", 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return Encode(errSyntax, false)
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			if count < 1 {
+				return Encode(errSyntax, false)
+			}
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+
+	set, err := s.getSetOrEmpty(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	page, next := set.Scan(cursor, count)
+	members := make([]string, 0, len(page))
+	for _, member := range page {
+		if matchPattern(pattern, member) {
+			members = append(members, member)
+		}
+	}
+	return Encode([]interface{}{strconv.FormatUint(next, 10), members}, false)
+}
+
+// matchPattern reports whether s matches the glob-style pattern of Redis:
+// * matches any characters, ? any one character, [abc], [^abc] and [a-z]
+// match classes and \ escapes the next character
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				switch {
+				case pattern[0] == '\\' && len(pattern) > 1:
+					pattern = pattern[1:]
+					match = match || pattern[0] == s[0]
+				case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+					lo, hi := min(pattern[0], pattern[2]), max(pattern[0], pattern[2])
+					match = match || (lo <= s[0] && s[0] <= hi)
+					pattern = pattern[2:]
+				default:
+					match = match || pattern[0] == s[0]
+				}
+				pattern = pattern[1:]
+			}
+			if match == not {
+				return false
+			}
+			if len(pattern) == 0 {
+				// unterminated class, like Redis ends the pattern there
+				return len(s) == 1
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
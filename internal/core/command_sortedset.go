@@ -1,72 +1,952 @@
 package core
 
 import (
-	"goredis-lite/internal/constant"
-	"goredis-lite/internal/data_structure"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"slices"
 	"strconv"
+	"strings"
+
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
 )
 
-func cmdZADD(args []string) []byte {
-	if len(args) < 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZADD' command"), false)
+// ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+func cmdZADD(s *Storage, c *Client, args []string) []byte {
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
 	}
-	key := args[0]
-	scoreIndex := 1
-
-	numScoreEleArgs := len(args) - scoreIndex
-	if numScoreEleArgs%2 == 1 || numScoreEleArgs == 0 {
-		return Encode(errors.New(fmt.Sprintf("(error) Wrong number of (score, member) arg: %d", numScoreEleArgs)), false)
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 == 1 {
+		return Encode(errSyntax, false)
 	}
-
-	zset, exist := zsetStore[key]
-	if !exist {
-		zset = data_structure.NewSortedSet(constant.DefaultBPlusTreeDegree)
-		zsetStore[key] = zset
+	if nx && xx {
+		return Encode(errors.New("ERR XX and NX options at the same time are not compatible"), false)
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		return Encode(errors.New("ERR GT, LT, and/or NX options at the same time are not compatible"), false)
+	}
+	if incr && len(pairs) > 2 {
+		return Encode(errors.New("ERR INCR option supports a single increment-element pair"), false)
+	}
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		score, ok := parseScore(pairs[2*j])
+		if !ok {
+			return Encode(errors.New("ERR value is not a valid float"), false)
+		}
+		scores[j] = score
 	}
 
-	count := 0
-	for i := scoreIndex; i < len(args); i += 2 {
-		member := args[i+1]
-		score, err := strconv.ParseFloat(args[i], 64)
-		if err != nil {
-			return Encode(errors.New("(error) Score must be floating point number"), false)
+	key := args[0]
+	zset, err := s.getOrCreateZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	defer s.deleteZSetIfEmpty(key, zset)
+	added, updated := 0, 0
+	for j, score := range scores {
+		member := pairs[2*j+1]
+		current, exist := zset.GetScore(member)
+		if (nx && exist) || (xx && !exist) {
+			continue
 		}
-		ret := zset.Add(score, member)
-		if ret != 1 {
-			return Encode(errors.New("error when adding element"), false)
+		if incr && exist {
+			score += current
+			if math.IsNaN(score) {
+				return Encode(errors.New("ERR resulting score is not a number (NaN)"), false)
+			}
+		}
+		if exist && ((gt && score <= current) || (lt && score >= current)) {
+			continue
+		}
+		switch zset.Add(score, member) {
+		case data_structure.AddNew:
+			added++
+			s.dirty++
+		case data_structure.AddUpdated:
+			updated++
+			s.dirty++
+		}
+		if incr {
+			return EncodeWithProto(score, false, c.Proto)
 		}
-		count++
 	}
-	return Encode(count, false)
+	if incr {
+		// the update was aborted by NX, XX, GT or LT
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	if ch {
+		return Encode(added+updated, false)
+	}
+	return Encode(added, false)
 }
 
-func cmdZSCORE(args []string) []byte {
+func cmdZSCORE(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZSCORE' command"), false)
 	}
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := s.getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
 	score, exist := zset.GetScore(member)
 	if !exist {
 		return constant.RespNil
 	}
-	return Encode(fmt.Sprintf("%f", score), false)
+	return EncodeWithProto(score, false, c.Proto)
 }
 
-func cmdZRANK(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANK' command"), false)
+// getOrCreateZSet returns the sorted set at key, created empty if missing
+func (s *Storage) getOrCreateZSet(key string) (*data_structure.SortedSet, error) {
+	zset, err := s.getZSet(key)
+	if zset == nil && err == nil {
+		zset = data_structure.NewSortedSet(constant.DefaultBPlusTreeDegree)
+		s.add(key, data_structure.ObjZSet, zset)
+	}
+	return zset, err
+}
+
+var (
+	errMinMaxNotFloat  = errors.New("ERR min or max is not a float")
+	errMinMaxNotString = errors.New("ERR min or max not valid string range item")
+)
+
+// parseScore parses a score, rejecting NaN; "inf", "+inf" and "-inf" are valid
+func parseScore(arg string) (float64, bool) {
+	score, err := strconv.ParseFloat(arg, 64)
+	return score, err == nil && !math.IsNaN(score)
+}
+
+// parseScoreBound parses "score" or "(score", the ends of a score range
+func parseScoreBound(arg string) (data_structure.ScoreBound, bool) {
+	var bound data_structure.ScoreBound
+	if strings.HasPrefix(arg, "(") {
+		bound.Exclusive, arg = true, arg[1:]
+	}
+	score, ok := parseScore(arg)
+	bound.Score = score
+	return bound, ok
+}
+
+// parseLexBound parses "-", "+", "[member" or "(member", the ends of a
+// lexicographical range
+func parseLexBound(arg string) (data_structure.LexBound, bool) {
+	switch {
+	case arg == "-":
+		return data_structure.LexBound{Inf: -1}, true
+	case arg == "+":
+		return data_structure.LexBound{Inf: 1}, true
+	case strings.HasPrefix(arg, "["):
+		return data_structure.LexBound{Member: arg[1:]}, true
+	case strings.HasPrefix(arg, "("):
+		return data_structure.LexBound{Member: arg[1:], Exclusive: true}, true
+	}
+	return data_structure.LexBound{}, false
+}
+
+// encodeItems replies members, followed by their score WITHSCORES. RESP3
+// clients get every member and score as a pair.
+func encodeItems(items []data_structure.Item, withScores bool, proto int) []byte {
+	res := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		switch {
+		case !withScores:
+			res = append(res, item.Member)
+		case proto == RESP3:
+			res = append(res, []interface{}{item.Member, item.Score})
+		default:
+			res = append(res, item.Member, item.Score)
+		}
+	}
+	return EncodeWithProto(res, false, proto)
+}
+
+// indexRange converts start and stop, inclusive indexes that are negative
+// from the end, to the ranks [from, to) of a sorted set of n elements
+func indexRange(start, stop, n int) (int, int) {
+	if start < 0 {
+		start = max(start+n, 0)
+	}
+	if stop < 0 {
+		stop += n
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+// deleteZSetIfEmpty removes key once its last member is gone
+func (s *Storage) deleteZSetIfEmpty(key string, zset *data_structure.SortedSet) {
+	if zset.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+}
+
+// zrangeQuery holds the options of ZRANGE and the commands derived from it
+type zrangeQuery struct {
+	by         string // "", "BYSCORE" or "BYLEX"
+	rev        bool
+	withScores bool
+	limited    bool
+	offset     int
+	count      int // negative returns every element from offset
+}
+
+// parseZRangeOptions parses the options following key start stop. BYSCORE,
+// BYLEX and REV are only accepted by ZRANGE itself, with allowBy.
+func parseZRangeOptions(args []string, q *zrangeQuery, allowBy bool) error {
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "WITHSCORES":
+			q.withScores = true
+		case option == "LIMIT" && i+2 < len(args):
+			offset, errOffset := strconv.Atoi(args[i+1])
+			count, errCount := strconv.Atoi(args[i+2])
+			if errOffset != nil || errCount != nil {
+				return errNotInteger
+			}
+			q.limited, q.offset, q.count = true, offset, count
+			i += 2
+		case allowBy && option == "REV":
+			q.rev = true
+		case allowBy && (option == "BYSCORE" || option == "BYLEX") && (q.by == "" || q.by == option):
+			q.by = option
+		default:
+			return errSyntax
+		}
+	}
+	if q.limited && q.by == "" {
+		return errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if q.withScores && q.by == "BYLEX" {
+		return errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	return nil
+}
+
+// zrange replies the elements of the sorted set at key between start and
+// stop: ranks, scores or members depending on q.by. With q.rev, elements are
+// in descending order and start is the highest end of the range.
+func (s *Storage) zrange(c *Client, key, start, stop string, q zrangeQuery) []byte {
+	lo, hi := start, stop
+	if q.rev {
+		lo, hi = stop, start
+	}
+	// the range of ascending ranks [from, to) to reply, computed once the
+	// arguments are known to be valid
+	var rankRange func(zset *data_structure.SortedSet) (int, int)
+	switch q.by {
+	case "BYSCORE":
+		min, okMin := parseScoreBound(lo)
+		max, okMax := parseScoreBound(hi)
+		if !okMin || !okMax {
+			return Encode(errMinMaxNotFloat, false)
+		}
+		rankRange = func(zset *data_structure.SortedSet) (int, int) {
+			return zset.ScoreRange(min, max)
+		}
+	case "BYLEX":
+		min, okMin := parseLexBound(lo)
+		max, okMax := parseLexBound(hi)
+		if !okMin || !okMax {
+			return Encode(errMinMaxNotString, false)
+		}
+		rankRange = func(zset *data_structure.SortedSet) (int, int) {
+			return zset.LexRange(min, max)
+		}
+	default:
+		startIdx, errStart := strconv.Atoi(start)
+		stopIdx, errStop := strconv.Atoi(stop)
+		if errStart != nil || errStop != nil {
+			return Encode(errNotInteger, false)
+		}
+		rankRange = func(zset *data_structure.SortedSet) (int, int) {
+			from, to := indexRange(startIdx, stopIdx, zset.Len())
+			if q.rev {
+				return zset.Len() - to, zset.Len() - from
+			}
+			return from, to
+		}
+	}
+
+	zset, err := s.getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil || (q.limited && q.offset < 0) {
+		return encodeItems(nil, q.withScores, c.Proto)
+	}
+	from, to := rankRange(zset)
+	if q.limited {
+		// LIMIT counts from the first element replied, the last rank with REV
+		if q.rev {
+			to = max(to-q.offset, from)
+			if q.count >= 0 {
+				from = max(from, to-q.count)
+			}
+		} else {
+			from = min(from+q.offset, to)
+			if q.count >= 0 {
+				to = min(to, from+q.count)
+			}
+		}
+	}
+	items := zset.Items(from, to)
+	if q.rev {
+		slices.Reverse(items)
+	}
+	return encodeItems(items, q.withScores, c.Proto)
+}
+
+// ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func cmdZRANGE(s *Storage, c *Client, args []string) []byte {
+	var q zrangeQuery
+	if err := parseZRangeOptions(args[3:], &q, true); err != nil {
+		return Encode(err, false)
+	}
+	return s.zrange(c, args[0], args[1], args[2], q)
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func cmdZRANGEBYSCORE(s *Storage, c *Client, args []string) []byte {
+	q := zrangeQuery{by: "BYSCORE"}
+	if err := parseZRangeOptions(args[3:], &q, false); err != nil {
+		return Encode(err, false)
+	}
+	return s.zrange(c, args[0], args[1], args[2], q)
+}
+
+// rank implements ZRANK and ZREVRANK: key member [WITHSCORE]
+func rank(s *Storage, c *Client, args []string, rev bool) []byte {
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHSCORE" {
+			return Encode(errSyntax, false)
+		}
+		withScore = true
+	} else if len(args) > 3 {
+		return Encode(errSyntax, false)
+	}
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var score float64
+	exist := zset != nil
+	if exist {
+		score, exist = zset.GetScore(args[1])
 	}
-	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
 	if !exist {
-		return constant.RespNil
+		if withScore {
+			return encodeNilArray(c.Proto)
+		}
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	r := zset.GetRank(args[1])
+	if rev {
+		r = zset.Len() - 1 - r
+	}
+	if withScore {
+		return EncodeWithProto([]interface{}{r, score}, false, c.Proto)
+	}
+	return Encode(r, false)
+}
+
+// ZRANK key member [WITHSCORE]
+func cmdZRANK(s *Storage, c *Client, args []string) []byte {
+	return rank(s, c, args, false)
+}
+
+// ZREVRANK key member [WITHSCORE]
+func cmdZREVRANK(s *Storage, c *Client, args []string) []byte {
+	return rank(s, c, args, true)
+}
+
+// ZCARD key
+func cmdZCARD(s *Storage, c *Client, args []string) []byte {
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constant.RespZero
+	}
+	return Encode(zset.Len(), false)
+}
+
+// ZCOUNT key min max
+func cmdZCOUNT(s *Storage, c *Client, args []string) []byte {
+	min, okMin := parseScoreBound(args[1])
+	max, okMax := parseScoreBound(args[2])
+	if !okMin || !okMax {
+		return Encode(errMinMaxNotFloat, false)
+	}
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constant.RespZero
+	}
+	start, end := zset.ScoreRange(min, max)
+	return Encode(end-start, false)
+}
+
+// ZLEXCOUNT key min max
+func cmdZLEXCOUNT(s *Storage, c *Client, args []string) []byte {
+	min, okMin := parseLexBound(args[1])
+	max, okMax := parseLexBound(args[2])
+	if !okMin || !okMax {
+		return Encode(errMinMaxNotString, false)
+	}
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constant.RespZero
+	}
+	start, end := zset.LexRange(min, max)
+	return Encode(end-start, false)
+}
+
+// ZINCRBY key increment member
+func cmdZINCRBY(s *Storage, c *Client, args []string) []byte {
+	incr, ok := parseScore(args[1])
+	if !ok {
+		return Encode(errors.New("ERR value is not a valid float"), false)
+	}
+	zset, err := s.getOrCreateZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if score, exist := zset.GetScore(args[2]); exist && math.IsNaN(score+incr) {
+		return Encode(errors.New("ERR resulting score is not a number (NaN)"), false)
+	}
+	s.dirty++
+	return EncodeWithProto(zset.IncrBy(incr, args[2]), false, c.Proto)
+}
+
+// zpop implements ZPOPMIN and ZPOPMAX: key [count]
+func zpop(s *Storage, c *Client, args []string, highest bool) []byte {
+	if len(args) > 2 {
+		return Encode(errSyntax, false)
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return Encode(errNotPositive, false)
+		}
+		count = n
+	}
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return EncodeWithProto([]interface{}{}, false, c.Proto)
+	}
+	n := zset.Len()
+	count = min(count, n)
+	var items []data_structure.Item
+	if highest {
+		items = zset.Items(n-count, n)
+		slices.Reverse(items)
+	} else {
+		items = zset.Items(0, count)
+	}
+	for _, item := range items {
+		zset.Remove(item.Member)
 	}
-	rank := zset.GetRank(member)
-	return Encode(rank, false)
-}
\ No newline at end of file
+	s.deleteZSetIfEmpty(args[0], zset)
+	s.dirty += int64(len(items))
+	// without count, RESP3 clients get a single member and score pair
+	if len(args) == 1 && len(items) == 1 {
+		return EncodeWithProto([]interface{}{items[0].Member, items[0].Score}, false, c.Proto)
+	}
+	return encodeItems(items, true, c.Proto)
+}
+
+// ZPOPMIN key [count]
+func cmdZPOPMIN(s *Storage, c *Client, args []string) []byte {
+	return zpop(s, c, args, false)
+}
+
+// ZPOPMAX key [count]
+func cmdZPOPMAX(s *Storage, c *Client, args []string) []byte {
+	return zpop(s, c, args, true)
+}
+
+// ZRANDMEMBER key [count [WITHSCORES]]
+func cmdZRANDMEMBER(s *Storage, c *Client, args []string) []byte {
+	if len(args) > 3 || (len(args) == 3 && strings.ToUpper(args[2]) != "WITHSCORES") {
+		return Encode(errSyntax, false)
+	}
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	n := 0
+	if zset != nil {
+		n = zset.Len()
+	}
+	if len(args) == 1 {
+		if n == 0 {
+			return EncodeWithProto(nil, false, c.Proto)
+		}
+		r := rand.Intn(n)
+		return Encode(zset.Items(r, r+1)[0].Member, false)
+	}
+
+	count, err := parseRandomCount(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	// a positive count returns distinct members, a negative one may repeat them
+	var ranks []int
+	switch {
+	case n == 0 || count == 0:
+	case count > 0:
+		ranks = rand.Perm(n)[:min(count, n)]
+	default:
+		ranks = make([]int, -count)
+		for i := range ranks {
+			ranks[i] = rand.Intn(n)
+		}
+	}
+	items := make([]data_structure.Item, len(ranks))
+	for i, r := range ranks {
+		items[i] = zset.Items(r, r+1)[0]
+	}
+	return encodeItems(items, len(args) == 3, c.Proto)
+}
+
+// parseRandomCount parses the count of ZRANDMEMBER, HRANDFIELD and
+// SRANDMEMBER. A positive count is bounded by the size of the collection, a
+// negative one, which may repeat members, by MaxRandomCount so that a
+// single request can't allocate gigabytes.
+func parseRandomCount(arg string) (int, error) {
+	count, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	if count < -int64(constant.MaxRandomCount) || count > math.MaxInt32 {
+		return 0, errors.New("ERR value is out of range")
+	}
+	return int(count), nil
+}
+
+// ZMSCORE key member [member ...]
+func cmdZMSCORE(s *Storage, c *Client, args []string) []byte {
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	for i, member := range args[1:] {
+		if zset == nil {
+			continue
+		}
+		if score, exist := zset.GetScore(member); exist {
+			res[i] = score
+		}
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// ZREM key member [member ...]
+func cmdZREM(s *Storage, c *Client, args []string) []byte {
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constant.RespZero
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		if zset.Remove(member) {
+			removed++
+		}
+	}
+	s.deleteZSetIfEmpty(args[0], zset)
+	s.dirty += int64(removed)
+	return Encode(removed, false)
+}
+
+// removeRange removes the members of the sorted set at key ranked by
+// rankRange, once its arguments were validated
+func (s *Storage) removeRange(key string, rankRange func(zset *data_structure.SortedSet) (int, int)) []byte {
+	zset, err := s.getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constant.RespZero
+	}
+	removed := zset.RemoveRange(rankRange(zset))
+	s.deleteZSetIfEmpty(key, zset)
+	s.dirty += int64(removed)
+	return Encode(removed, false)
+}
+
+// ZREMRANGEBYRANK key start stop
+func cmdZREMRANGEBYRANK(s *Storage, c *Client, args []string) []byte {
+	start, errStart := strconv.Atoi(args[1])
+	stop, errStop := strconv.Atoi(args[2])
+	if errStart != nil || errStop != nil {
+		return Encode(errNotInteger, false)
+	}
+	return s.removeRange(args[0], func(zset *data_structure.SortedSet) (int, int) {
+		return indexRange(start, stop, zset.Len())
+	})
+}
+
+// ZREMRANGEBYSCORE key min max
+func cmdZREMRANGEBYSCORE(s *Storage, c *Client, args []string) []byte {
+	min, okMin := parseScoreBound(args[1])
+	max, okMax := parseScoreBound(args[2])
+	if !okMin || !okMax {
+		return Encode(errMinMaxNotFloat, false)
+	}
+	return s.removeRange(args[0], func(zset *data_structure.SortedSet) (int, int) {
+		return zset.ScoreRange(min, max)
+	})
+}
+
+// ZREMRANGEBYLEX key min max
+func cmdZREMRANGEBYLEX(s *Storage, c *Client, args []string) []byte {
+	min, okMin := parseLexBound(args[1])
+	max, okMax := parseLexBound(args[2])
+	if !okMin || !okMax {
+		return Encode(errMinMaxNotString, false)
+	}
+	return s.removeRange(args[0], func(zset *data_structure.SortedSet) (int, int) {
+		return zset.LexRange(min, max)
+	})
+}
+
+// zsetOperand is an input of ZUNION, ZINTER and ZDIFF: a sorted set, or a
+// set whose members all score 1. A missing key is an empty operand.
+type zsetOperand struct {
+	zset *data_structure.SortedSet
+	set  *data_structure.SimpleSet
+}
+
+// zsetOperands returns the operands at keys, errWrongType when one holds
+// neither a sorted set nor a set
+func (s *Storage) zsetOperands(keys []string) ([]zsetOperand, error) {
+	operands := make([]zsetOperand, len(keys))
+	for i, key := range keys {
+		obj := s.dictStore.Get(key)
+		switch {
+		case obj == nil:
+		case obj.Type == data_structure.ObjZSet:
+			operands[i].zset = obj.Value.(*data_structure.SortedSet)
+		case obj.Type == data_structure.ObjSet:
+			operands[i].set = obj.Value.(*data_structure.SimpleSet)
+		default:
+			return nil, errWrongType
+		}
+	}
+	return operands, nil
+}
+
+func (o zsetOperand) len() int {
+	switch {
+	case o.zset != nil:
+		return o.zset.Len()
+	case o.set != nil:
+		return o.set.Len()
+	}
+	return 0
+}
+
+func (o zsetOperand) score(member string) (float64, bool) {
+	switch {
+	case o.zset != nil:
+		return o.zset.GetScore(member)
+	case o.set != nil:
+		return 1, o.set.IsMember(member) == 1
+	}
+	return 0, false
+}
+
+func (o zsetOperand) each(fn func(member string, score float64)) {
+	switch {
+	case o.zset != nil:
+		for _, item := range o.zset.Items(0, o.zset.Len()) {
+			fn(item.Member, item.Score)
+		}
+	case o.set != nil:
+		for _, member := range o.set.Members() {
+			fn(member, 1)
+		}
+	}
+}
+
+// Sorted set operations
+const (
+	zsetUnion = iota
+	zsetInter
+	zsetDiff
+)
+
+// aggregates combine the scores of a member found in several operands, a
+// NaN sum of infinities counts as 0 like in Redis
+var aggregates = map[string]func(a, b float64) float64{
+	"SUM": func(a, b float64) float64 {
+		if sum := a + b; !math.IsNaN(sum) {
+			return sum
+		}
+		return 0
+	},
+	"MIN": math.Min,
+	"MAX": math.Max,
+}
+
+// weighted multiplies score by weight, 0 * inf being 0
+func weighted(score, weight float64) float64 {
+	if v := score * weight; !math.IsNaN(v) {
+		return v
+	}
+	return 0
+}
+
+// parseNumKeys splits "numkeys key [key ...] ..." into the keys and the
+// arguments following them
+func parseNumKeys(name string, args []string) ([]string, []string, error) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, nil, errNotInteger
+	}
+	if n < 1 {
+		return nil, nil, fmt.Errorf("ERR at least 1 input key is needed for '%s' command", name)
+	}
+	if n > len(args)-1 {
+		return nil, nil, errSyntax
+	}
+	return args[1 : n+1], args[n+1:], nil
+}
+
+// parseInterCard parses the arguments of ZINTERCARD and SINTERCARD:
+// numkeys key [key ...] [LIMIT limit]. A limit of 0 means no limit.
+func parseInterCard(args []string) ([]string, int, error) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return nil, 0, errors.New("ERR numkeys should be greater than 0")
+	}
+	if n > len(args)-1 {
+		return nil, 0, errors.New("ERR Number of keys can't be greater than number of args")
+	}
+	keys, opts := args[1:n+1], args[n+1:]
+	limit := 0
+	for i := 0; i < len(opts); i += 2 {
+		if strings.ToUpper(opts[i]) != "LIMIT" || i+1 == len(opts) {
+			return nil, 0, errSyntax
+		}
+		limit, err = strconv.Atoi(opts[i+1])
+		if err != nil || limit < 0 {
+			return nil, 0, errors.New("ERR LIMIT can't be negative")
+		}
+	}
+	return keys, limit, nil
+}
+
+// zsetOp implements ZUNION, ZINTER, ZDIFF and, when store is set, their
+// STORE variants whose destination comes first:
+// [destination] numkeys key [key ...] [WEIGHTS weight [weight ...]]
+// [AGGREGATE SUM | MIN | MAX] [WITHSCORES]
+// ZDIFF takes neither WEIGHTS nor AGGREGATE, the STORE variants reply the
+// size of the stored sorted set instead of taking WITHSCORES.
+func zsetOp(s *Storage, c *Client, name string, op int, args []string, store bool) []byte {
+	var dest string
+	if store {
+		dest, args = args[0], args[1:]
+	}
+	keys, opts, err := parseNumKeys(name, args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	weights := make([]float64, len(keys))
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := aggregates["SUM"]
+	withScores := false
+	for i := 0; i < len(opts); i++ {
+		switch opt := strings.ToUpper(opts[i]); {
+		case opt == "WEIGHTS" && op != zsetDiff && i+len(keys) < len(opts):
+			for j := range weights {
+				weight, ok := parseScore(opts[i+1+j])
+				if !ok {
+					return Encode(errors.New("ERR weight value is not a float"), false)
+				}
+				weights[j] = weight
+			}
+			i += len(keys)
+		case opt == "AGGREGATE" && op != zsetDiff && i+1 < len(opts):
+			fn, ok := aggregates[strings.ToUpper(opts[i+1])]
+			if !ok {
+				return Encode(errSyntax, false)
+			}
+			aggregate = fn
+			i++
+		case opt == "WITHSCORES" && !store:
+			withScores = true
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+
+	operands, err := s.zsetOperands(keys)
+	if err != nil {
+		return Encode(err, false)
+	}
+	scores := make(map[string]float64)
+	switch op {
+	case zsetUnion:
+		for i, operand := range operands {
+			operand.each(func(member string, score float64) {
+				score = weighted(score, weights[i])
+				if current, exist := scores[member]; exist {
+					score = aggregate(current, score)
+				}
+				scores[member] = score
+			})
+		}
+	case zsetInter:
+		// walk the smallest operand and look its members up in the others
+		smallest := 0
+		for i, operand := range operands {
+			if operand.len() < operands[smallest].len() {
+				smallest = i
+			}
+		}
+		operands[smallest].each(func(member string, score float64) {
+			score = weighted(score, weights[smallest])
+			for i, operand := range operands {
+				if i == smallest {
+					continue
+				}
+				other, exist := operand.score(member)
+				if !exist {
+					return
+				}
+				score = aggregate(score, weighted(other, weights[i]))
+			}
+			scores[member] = score
+		})
+	case zsetDiff:
+		operands[0].each(func(member string, score float64) {
+			for _, operand := range operands[1:] {
+				if _, exist := operand.score(member); exist {
+					return
+				}
+			}
+			scores[member] = score
+		})
+	}
+
+	zset := data_structure.NewSortedSet(constant.DefaultBPlusTreeDegree)
+	for member, score := range scores {
+		zset.Add(score, member)
+	}
+	if store {
+		// an empty result changes the keyspace only when dest existed
+		if s.dictStore.Del(dest) || zset.Len() > 0 {
+			s.dirty++
+		}
+		if zset.Len() > 0 {
+			s.add(dest, data_structure.ObjZSet, zset)
+		}
+		return Encode(zset.Len(), false)
+	}
+	return encodeItems(zset.Items(0, zset.Len()), withScores, c.Proto)
+}
+
+// ZUNION numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX] [WITHSCORES]
+func cmdZUNION(s *Storage, c *Client, args []string) []byte {
+	return zsetOp(s, c, "zunion", zsetUnion, args, false)
+}
+
+// ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX]
+func cmdZUNIONSTORE(s *Storage, c *Client, args []string) []byte {
+	return zsetOp(s, c, "zunionstore", zsetUnion, args, true)
+}
+
+// ZINTER numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX] [WITHSCORES]
+func cmdZINTER(s *Storage, c *Client, args []string) []byte {
+	return zsetOp(s, c, "zinter", zsetInter, args, false)
+}
+
+// ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX]
+func cmdZINTERSTORE(s *Storage, c *Client, args []string) []byte {
+	return zsetOp(s, c, "zinterstore", zsetInter, args, true)
+}
+
+// ZDIFF numkeys key [key ...] [WITHSCORES]
+func cmdZDIFF(s *Storage, c *Client, args []string) []byte {
+	return zsetOp(s, c, "zdiff", zsetDiff, args, false)
+}
+
+// ZDIFFSTORE destination numkeys key [key ...]
+func cmdZDIFFSTORE(s *Storage, c *Client, args []string) []byte {
+	return zsetOp(s, c, "zdiffstore", zsetDiff, args, true)
+}
+
+// ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func cmdZINTERCARD(s *Storage, c *Client, args []string) []byte {
+	keys, limit, err := parseInterCard(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	operands, err := s.zsetOperands(keys)
+	if err != nil {
+		return Encode(err, false)
+	}
+	slices.SortFunc(operands, func(a, b zsetOperand) int { return a.len() - b.len() })
+	count := 0
+	operands[0].each(func(member string, _ float64) {
+		if limit > 0 && count == limit {
+			return
+		}
+		for _, operand := range operands[1:] {
+			if _, exist := operand.score(member); !exist {
+				return
+			}
+		}
+		count++
+	})
+	return Encode(count, false)
+}
@@ -0,0 +1,541 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"goredis-lite/internal/config"
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
+)
+
+// setString stores value at key, replacing a key of any type. The TTL of
+// the key is dropped unless keepTTL, expireAt sets a new one when not 0.
+func (s *Storage) setString(key, value string, expireAt int64, keepTTL bool) {
+	if !keepTTL {
+		s.dictStore.Persist(key)
+	}
+	s.dictStore.Set(key, s.dictStore.NewObj(key, data_structure.ObjString, data_structure.NewStringValue(value), -1))
+	if expireAt != 0 {
+		s.dictStore.SetExpireAt(key, expireAt)
+	}
+	s.dirty++
+}
+
+// parseExpireAt turns the argument of the EX, PX, EXAT or PXAT option of
+// the command name into a unix time in milliseconds
+func parseExpireAt(name, unit, arg string) (int64, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	now := time.Now().UnixMilli()
+	errInvalid := fmt.Errorf("ERR invalid expire time in '%s' command", name)
+	if n <= 0 {
+		return 0, errInvalid
+	}
+	switch unit {
+	case "EX":
+		if n > (math.MaxInt64-now)/1000 {
+			return 0, errInvalid
+		}
+		return now + n*1000, nil
+	case "PX":
+		if n > math.MaxInt64-now {
+			return 0, errInvalid
+		}
+		return now + n, nil
+	case "EXAT":
+		if n > math.MaxInt64/1000 {
+			return 0, errInvalid
+		}
+		return n * 1000, nil
+	}
+	return n, nil
+}
+
+// setOptions holds the options of SET and GETEX
+type setOptions struct {
+	nx, xx, get bool
+	keepTTL     bool
+	persist     bool
+	expireAt    int64 // unix ms, 0 without EX, PX, EXAT or PXAT
+}
+
+// parseSetOptions parses the options of SET:
+// [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+// or, when name is "getex", those of GETEX:
+// [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+func parseSetOptions(name string, args []string) (setOptions, error) {
+	var opts setOptions
+	isSet := name == "set"
+	expiry := "" // the option setting the TTL, when given
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "NX" && isSet && !opts.xx:
+			opts.nx = true
+		case opt == "XX" && isSet && !opts.nx:
+			opts.xx = true
+		case opt == "GET" && isSet:
+			opts.get = true
+		case opt == "KEEPTTL" && isSet && (expiry == "" || expiry == opt):
+			opts.keepTTL, expiry = true, opt
+		case opt == "PERSIST" && !isSet && (expiry == "" || expiry == opt):
+			opts.persist, expiry = true, opt
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && expiry == "" && i+1 < len(args):
+			expireAt, err := parseExpireAt(name, opt, args[i+1])
+			if err != nil {
+				return opts, err
+			}
+			opts.expireAt, expiry = expireAt, opt
+			i++
+		default:
+			return opts, errSyntax
+		}
+	}
+	return opts, nil
+}
+
+// SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func cmdSET(s *Storage, c *Client, args []string) []byte {
+	opts, err := parseSetOptions("set", args[2:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	key := args[0]
+	var old interface{}
+	if opts.get {
+		// GET fails on a key of another type, leaving it untouched
+		value, exist, err := s.getString(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if exist {
+			old = value
+		}
+	}
+	exist := s.dictStore.Get(key) != nil
+	if (opts.nx && exist) || (opts.xx && !exist) {
+		return EncodeWithProto(old, false, c.Proto)
+	}
+	s.setString(key, args[1], opts.expireAt, opts.keepTTL)
+	if opts.get {
+		return EncodeWithProto(old, false, c.Proto)
+	}
+	return constant.RespOk
+}
+
+func cmdGET(s *Storage, c *Client, args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'GET' command"), false)
+	}
+
+	value, exist, err := s.getString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exist {
+		return constant.RespNil
+	}
+
+	return Encode(value, false)
+}
+
+// SETNX key value
+func cmdSETNX(s *Storage, c *Client, args []string) []byte {
+	if s.dictStore.Get(args[0]) != nil {
+		return constant.RespZero
+	}
+	s.setString(args[0], args[1], 0, false)
+	return constant.RespOne
+}
+
+// setex implements SETEX and PSETEX: key ttl value, the TTL being in the
+// given unit, "EX" or "PX"
+func setex(s *Storage, name, unit string, args []string) []byte {
+	expireAt, err := parseExpireAt(name, unit, args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	s.setString(args[0], args[2], expireAt, false)
+	return constant.RespOk
+}
+
+// SETEX key seconds value
+func cmdSETEX(s *Storage, c *Client, args []string) []byte {
+	return setex(s, "setex", "EX", args)
+}
+
+// PSETEX key milliseconds value
+func cmdPSETEX(s *Storage, c *Client, args []string) []byte {
+	return setex(s, "psetex", "PX", args)
+}
+
+// GETSET key value
+func cmdGETSET(s *Storage, c *Client, args []string) []byte {
+	return cmdSET(s, c, []string{args[0], args[1], "GET"})
+}
+
+// GETDEL key
+func cmdGETDEL(s *Storage, c *Client, args []string) []byte {
+	value, exist, err := s.getString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exist {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	s.dictStore.Del(args[0])
+	s.dirty++
+	return Encode(value, false)
+}
+
+// GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+func cmdGETEX(s *Storage, c *Client, args []string) []byte {
+	opts, err := parseSetOptions("getex", args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	value, exist, err := s.getString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exist {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	switch {
+	case opts.persist:
+		if s.dictStore.Persist(args[0]) {
+			s.dirty++
+		}
+	case opts.expireAt != 0:
+		s.dictStore.SetExpireAt(args[0], opts.expireAt)
+		s.dirty++
+	}
+	return Encode(value, false)
+}
+
+// updateString stores value, a Value made by NewStringValue or the []byte
+// of a bitmap, in obj, the string at key, keeping its TTL. A nil obj
+// creates key.
+func (s *Storage) updateString(key string, obj *data_structure.Obj, value interface{}) {
+	s.dirty++
+	if obj == nil {
+		s.add(key, data_structure.ObjString, value)
+		return
+	}
+	obj.Value = value
+}
+
+var (
+	errNotFloat      = errors.New("ERR value is not a valid float")
+	errStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+)
+
+// incrBy adds incr to the integer at key, 0 when the key is missing
+func (s *Storage) incrBy(key string, incr int64) []byte {
+	obj, _, err := s.getStringObj(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current int64
+	if obj != nil {
+		// a string holding an integer is stored as an int64, unless it was
+		// written as a bitmap
+		value := obj.Value
+		if buf, ok := value.([]byte); ok {
+			value = data_structure.NewStringValue(string(buf))
+		}
+		n, isInt := value.(int64)
+		if !isInt {
+			return Encode(errNotInteger, false)
+		}
+		current = n
+	}
+	if (incr > 0 && current > math.MaxInt64-incr) || (incr < 0 && current < math.MinInt64-incr) {
+		return Encode(errors.New("ERR increment or decrement would overflow"), false)
+	}
+	current += incr
+	s.updateString(key, obj, current)
+	return Encode(current, false)
+}
+
+// INCR key
+func cmdINCR(s *Storage, c *Client, args []string) []byte {
+	return s.incrBy(args[0], 1)
+}
+
+// DECR key
+func cmdDECR(s *Storage, c *Client, args []string) []byte {
+	return s.incrBy(args[0], -1)
+}
+
+// INCRBY key increment
+func cmdINCRBY(s *Storage, c *Client, args []string) []byte {
+	incr, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	return s.incrBy(args[0], incr)
+}
+
+// DECRBY key decrement
+func cmdDECRBY(s *Storage, c *Client, args []string) []byte {
+	decr, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if decr == math.MinInt64 {
+		return Encode(errors.New("ERR decrement would overflow"), false)
+	}
+	return s.incrBy(args[0], -decr)
+}
+
+// INCRBYFLOAT key increment
+func cmdINCRBYFLOAT(s *Storage, c *Client, args []string) []byte {
+	incr, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || math.IsNaN(incr) || math.IsInf(incr, 0) {
+		return Encode(errNotFloat, false)
+	}
+	obj, value, err := s.getStringObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current float64
+	if obj != nil {
+		current, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return Encode(errNotFloat, false)
+		}
+	}
+	current += incr
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return Encode(errors.New("ERR increment would produce NaN or Infinity"), false)
+	}
+	value = strconv.FormatFloat(current, 'f', -1, 64)
+	s.updateString(args[0], obj, data_structure.NewStringValue(value))
+	// replaying the increment could round differently
+	s.rewriteCommand("SET", args[0], value, "KEEPTTL")
+	return Encode(value, false)
+}
+
+// APPEND key value
+func cmdAPPEND(s *Storage, c *Client, args []string) []byte {
+	obj, value, err := s.getStringObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if len(value)+len(args[1]) > config.ProtoMaxBulkLen {
+		return Encode(errStringTooLong, false)
+	}
+	value += args[1]
+	s.updateString(args[0], obj, data_structure.NewStringValue(value))
+	return Encode(len(value), false)
+}
+
+// STRLEN key
+func cmdSTRLEN(s *Storage, c *Client, args []string) []byte {
+	_, value, err := s.getStringObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(len(value), false)
+}
+
+// GETRANGE key start end: negative offsets count from the end of the string
+func cmdGETRANGE(s *Storage, c *Client, args []string) []byte {
+	start, errStart := strconv.ParseInt(args[1], 10, 64)
+	end, errEnd := strconv.ParseInt(args[2], 10, 64)
+	if errStart != nil || errEnd != nil {
+		return Encode(errNotInteger, false)
+	}
+	_, value, err := s.getStringObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	n := int64(len(value))
+	if start < 0 && end < 0 && start > end {
+		return Encode("", false)
+	}
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = max(n+end, 0)
+	}
+	end = min(end, n-1)
+	if start > end {
+		return Encode("", false)
+	}
+	return Encode(value[start:end+1], false)
+}
+
+// SETRANGE key offset value: the string is padded with zero bytes up to
+// offset when shorter
+func cmdSETRANGE(s *Storage, c *Client, args []string) []byte {
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if offset < 0 {
+		return Encode(errors.New("ERR offset is out of range"), false)
+	}
+	obj, value, err := s.getStringObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	patch := args[2]
+	if len(patch) == 0 {
+		// nothing to write, a missing key is not created
+		return Encode(len(value), false)
+	}
+	if offset+int64(len(patch)) > int64(config.ProtoMaxBulkLen) {
+		return Encode(errStringTooLong, false)
+	}
+	buf := []byte(value)
+	if end := int(offset) + len(patch); end > len(buf) {
+		buf = append(buf, make([]byte, end-len(buf))...)
+	}
+	copy(buf[offset:], patch)
+	s.updateString(args[0], obj, data_structure.NewStringValue(string(buf)))
+	return Encode(len(buf), false)
+}
+
+// MGET key [key ...]: keys missing or holding another type reply nil
+func cmdMGET(s *Storage, c *Client, args []string) []byte {
+	res := make([]interface{}, len(args))
+	for i, key := range args {
+		if value, exist, err := s.getString(key); exist && err == nil {
+			res[i] = value
+		}
+	}
+	return EncodeWithProto(res, false, c.Proto)
+}
+
+// MSET key value [key value ...]
+func cmdMSET(s *Storage, c *Client, args []string) []byte {
+	if len(args)%2 != 0 {
+		return Encode(errors.New("ERR wrong number of arguments for 'mset' command"), false)
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.setString(args[i], args[i+1], 0, false)
+	}
+	return constant.RespOk
+}
+
+// MSETNX key value [key value ...]: nothing is set when a key exists
+func cmdMSETNX(s *Storage, c *Client, args []string) []byte {
+	if len(args)%2 != 0 {
+		return Encode(errors.New("ERR wrong number of arguments for 'msetnx' command"), false)
+	}
+	for i := 0; i < len(args); i += 2 {
+		if s.dictStore.Get(args[i]) != nil {
+			return constant.RespZero
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.setString(args[i], args[i+1], 0, false)
+	}
+	return constant.RespOne
+}
+
+// LCS key1 key2 [LEN] [IDX] [MINMATCHLEN min-match-len] [WITHMATCHLEN]
+func cmdLCS(s *Storage, c *Client, args []string) []byte {
+	var getLen, getIdx, withMatchLen bool
+	minMatchLen := 0
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "LEN":
+			getLen = true
+		case opt == "IDX":
+			getIdx = true
+		case opt == "WITHMATCHLEN":
+			withMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			minMatchLen = int(max(n, 0))
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	if getLen && getIdx {
+		return Encode(errors.New("ERR If you want both the length and indexes, please just use IDX."), false)
+	}
+	_, a, errA := s.getStringObj(args[0])
+	_, b, errB := s.getStringObj(args[1])
+	if errA != nil || errB != nil {
+		return Encode(errors.New("ERR The specified keys must contain string values"), false)
+	}
+
+	// lcs[i*width+j] is the length of the LCS of a[:i] and b[:j]
+	width := len(b) + 1
+	if len(a)+1 > config.ProtoMaxBulkLen/4/width {
+		return Encode(errors.New("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len"), false)
+	}
+	lcs := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				lcs[i*width+j] = lcs[(i-1)*width+j-1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i-1)*width+j], lcs[i*width+j-1])
+			}
+		}
+	}
+	n := int(lcs[len(a)*width+len(b)])
+	if getLen {
+		return Encode(n, false)
+	}
+
+	// walk back from the end of both strings like Redis does, collecting the
+	// LCS and the ranges a[aStart:aEnd+1] == b[bStart:bEnd+1] it is made of
+	res := make([]byte, n)
+	matches := []interface{}{}
+	aStart, aEnd, bStart, bEnd := -1, 0, 0, 0 // no range while aStart < 0
+	emit := func() {
+		if aStart < 0 {
+			return
+		}
+		if matchLen := aEnd - aStart + 1; matchLen >= minMatchLen {
+			match := []interface{}{[]interface{}{aStart, aEnd}, []interface{}{bStart, bEnd}}
+			if withMatchLen {
+				match = append(match, matchLen)
+			}
+			matches = append(matches, match)
+		}
+		aStart = -1
+	}
+	for i, j, k := len(a), len(b), n; i > 0 && j > 0; {
+		if a[i-1] == b[j-1] {
+			k--
+			res[k] = a[i-1]
+			if aStart < 0 {
+				aEnd, bEnd = i-1, j-1
+			}
+			aStart, bStart = i-1, j-1
+			i, j = i-1, j-1
+			if i == 0 || j == 0 {
+				emit()
+			}
+			continue
+		}
+		if lcs[(i-1)*width+j] > lcs[i*width+j-1] {
+			i--
+		} else {
+			j--
+		}
+		emit()
+	}
+	if !getIdx {
+		return Encode(string(res), false)
+	}
+	return EncodeWithProto(Map{"matches", matches, "len", n}, false, c.Proto)
+}
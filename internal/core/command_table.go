@@ -0,0 +1,668 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// CommandFunc executes a command for client c against storage s.
+// args excludes the command name.
+type CommandFunc func(s *Storage, c *Client, args []string) []byte
+
+type CommandFlag uint32
+
+const (
+	FlagWrite       CommandFlag = 1 << iota // may modify the keyspace
+	FlagReadonly                            // only reads the keyspace
+	FlagAdmin                               // administrative command
+	FlagFast                                // O(1) or O(log N), never blocks for long
+	FlagBlocking                            // may park the client until a key is ready
+	FlagMovableKeys                         // key positions are found by GetKeys
+	// FlagGlobal marks commands acting on the whole server rather than on one
+	// partition. The share-nothing server runs them outside of the workers,
+	// with a nil Storage. It is not reported by COMMAND.
+	FlagGlobal
+)
+
+var commandFlagNames = []struct {
+	flag CommandFlag
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadonly, "readonly"},
+	{FlagAdmin, "admin"},
+	{FlagFast, "fast"},
+	{FlagBlocking, "blocking"},
+	{FlagMovableKeys, "movablekeys"},
+}
+
+// CommandSpec describes a command the way the Redis command table does.
+// Key positions are indexes in the full argv, where argv[0] is the command name.
+type CommandSpec struct {
+	Name       string // lower case, as reported by COMMAND
+	Arity      int    // argc including the name, -N means at least N
+	Flags      CommandFlag
+	FirstKey   int // 0 when the command takes no key
+	LastKey    int // negative values count from the end, -1 is the last argument
+	Step       int
+	Group      string
+	Since      string
+	Complexity string
+	Summary    string
+	// Tips tell a proxy how to route the command across partitions,
+	// e.g. "request_policy:multi_shard" and "response_policy:agg_sum"
+	Tips    []string
+	Handler CommandFunc
+	// GetKeys returns the positions in args of the keys of a command flagged
+	// FlagMovableKeys, e.g. ZUNION whose keys are counted by an argument
+	GetKeys func(args []string) []int
+}
+
+// Request and response policies, as documented for Redis command tips
+const (
+	RequestPolicyMultiShard     = "multi_shard"     // keys may be split between partitions
+	RequestPolicyAllShards      = "all_shards"      // runs on every partition
+	ResponsePolicyAggSum        = "agg_sum"         // replies are integers to add up
+	ResponsePolicyAllSucceeded  = "all_succeeded"   // reply OK when every partition did
+	ResponsePolicyOrderedByKeys = "ordered_by_keys" // array replies, one element per key
+	ResponsePolicySpecial       = "special"         // merged by a command specific rule
+)
+
+// policy returns the value of the "kind:value" tip, or an empty string
+func (spec *CommandSpec) policy(kind string) string {
+	for _, tip := range spec.Tips {
+		if strings.HasPrefix(tip, kind+":") {
+			return tip[len(kind)+1:]
+		}
+	}
+	return ""
+}
+
+func (spec *CommandSpec) RequestPolicy() string {
+	return spec.policy("request_policy")
+}
+
+// ResponsePolicy defaults to ordered_by_keys for multi shard commands, like
+// Redis does for MGET
+func (spec *CommandSpec) ResponsePolicy() string {
+	policy := spec.policy("response_policy")
+	if policy == "" && spec.RequestPolicy() == RequestPolicyMultiShard {
+		return ResponsePolicyOrderedByKeys
+	}
+	return policy
+}
+
+// KeyIndexes returns the positions in args (the arguments after the command
+// name) of the keys the command operates on
+func (spec *CommandSpec) KeyIndexes(args []string) []int {
+	if spec.GetKeys != nil {
+		return spec.GetKeys(args)
+	}
+	if spec.FirstKey <= 0 {
+		return nil
+	}
+	last := spec.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	var indexes []int
+	for i := spec.FirstKey; i <= last && i <= len(args); i += spec.Step {
+		indexes = append(indexes, i-1)
+	}
+	return indexes
+}
+
+// numKeys returns the GetKeys of commands taking "numkeys key [key ...]" at
+// position pos of args, the arguments before pos being keys too
+func numKeys(pos int) func(args []string) []int {
+	return func(args []string) []int {
+		indexes := make([]int, 0, pos)
+		for i := 0; i < pos && i < len(args); i++ {
+			indexes = append(indexes, i)
+		}
+		if pos >= len(args) {
+			return indexes
+		}
+		n, err := strconv.Atoi(args[pos])
+		if err != nil {
+			return indexes
+		}
+		for i := pos + 1; i <= pos+n && i < len(args); i++ {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	}
+}
+
+// commandTable maps upper case command names to their spec,
+// commandList keeps the registration order for COMMAND replies.
+var (
+	commandTable = make(map[string]*CommandSpec)
+	commandList  []*CommandSpec
+)
+
+func registerCommands(specs ...*CommandSpec) {
+	for _, spec := range specs {
+		commandTable[strings.ToUpper(spec.Name)] = spec
+		commandList = append(commandList, spec)
+	}
+}
+
+// LookupCommand finds the spec of cmd and validates its arity. When the
+// command cannot run, the returned reply holds the error to send back.
+func LookupCommand(cmd *Command) (*CommandSpec, []byte) {
+	spec, exist := commandTable[cmd.Cmd]
+	if !exist {
+		return nil, []byte("-CMD NOT FOUND\r\n")
+	}
+	argc := len(cmd.Args) + 1
+	if (spec.Arity > 0 && argc != spec.Arity) || argc < -spec.Arity {
+		return nil, Encode(fmt.Errorf("ERR wrong number of arguments for '%s' command", spec.Name), false)
+	}
+	return spec, nil
+}
+
+// ExecuteCommand runs cmd against s through the command table and returns the reply
+func ExecuteCommand(s *Storage, cmd *Command, c *Client) []byte {
+	spec, errRes := LookupCommand(cmd)
+	if errRes != nil {
+		return errRes
+	}
+	if spec.Flags&FlagWrite == 0 {
+		return spec.Handler(s, c, cmd.Args)
+	}
+	var key string
+	var expireAt int64
+	if s.aof != nil {
+		key, expireAt = s.expireAt(spec, cmd.Args)
+	}
+	dirty := s.dirty
+	res := spec.Handler(s, c, cmd.Args)
+	if s.rewritten != nil {
+		cmd, s.rewritten = s.rewritten, nil
+	}
+	s.propagate(cmd, key, expireAt, dirty)
+	if len(s.readyKeys) > 0 {
+		s.serveBlockedPops()
+	}
+	return res
+}
+
+// propagate counts the changes made to the keyspace since it was at dirty
+// and logs cmd in the AOF. A write that changed nothing, like SETNX on an
+// existing key or a blocking pop waiting for a push, is neither counted nor
+// logged.
+func (s *Storage) propagate(cmd *Command, key string, expireAt int64, dirty int64) {
+	if s.dirty == dirty {
+		return
+	}
+	atomic.AddInt64(&rdb.dirty, s.dirty-dirty)
+	if s.aof != nil {
+		s.feedAOF(cmd, key, expireAt)
+	}
+}
+
+func init() {
+	registerCommands(
+		// connection
+		&CommandSpec{Name: "ping", Arity: -1, Flags: FlagFast, Group: "connection", Since: "1.0.0",
+			Complexity: "O(1)", Summary: "Returns the server's liveliness response.", Handler: cmdPING},
+		&CommandSpec{Name: "hello", Arity: -1, Flags: FlagFast, Group: "connection", Since: "6.0.0",
+			Complexity: "O(1)", Summary: "Handshakes with the Redis server.", Handler: cmdHELLO},
+
+		// server
+		&CommandSpec{Name: "info", Arity: -1, Group: "server", Since: "1.0.0",
+			Complexity: "O(1)", Summary: "Returns information and statistics about the server.",
+			Tips: []string{"request_policy:all_shards", "response_policy:special"}, Handler: cmdINFO},
+		&CommandSpec{Name: "command", Arity: -1, Group: "server", Since: "2.8.13",
+			Complexity: "O(N) where N is the total number of Redis commands",
+			Summary:    "Returns detailed information about all commands.", Handler: cmdCOMMAND},
+		&CommandSpec{Name: "save", Arity: 1, Flags: FlagAdmin | FlagGlobal, Group: "server", Since: "1.0.0",
+			Complexity: "O(N) where N is the total number of keys in all databases",
+			Summary:    "Synchronously saves the database(s) to disk.", Handler: cmdSAVE},
+		&CommandSpec{Name: "bgsave", Arity: -1, Flags: FlagAdmin | FlagGlobal, Group: "server", Since: "1.0.0",
+			Complexity: "O(N) where N is the total number of keys in all databases",
+			Summary:    "Asynchronously saves the database(s) to disk.", Handler: cmdBGSAVE},
+		&CommandSpec{Name: "bgrewriteaof", Arity: 1, Flags: FlagAdmin | FlagGlobal, Group: "server", Since: "1.0.0",
+			Complexity: "O(1)", Summary: "Asynchronously rewrites the append-only file to disk.",
+			Handler: cmdBGREWRITEAOF},
+		&CommandSpec{Name: "lastsave", Arity: 1, Flags: FlagFast, Group: "server", Since: "1.0.0",
+			Complexity: "O(1)", Summary: "Returns the Unix timestamp of the last successful save to disk.",
+			Handler: cmdLASTSAVE},
+
+		// string
+		&CommandSpec{Name: "set", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Handler: cmdSET},
+		&CommandSpec{Name: "get", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Returns the string value of a key.", Handler: cmdGET},
+		&CommandSpec{Name: "setnx", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Set the string value of a key only when the key doesn't exist.", Handler: cmdSETNX},
+		&CommandSpec{Name: "setex", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist.", Handler: cmdSETEX},
+		&CommandSpec{Name: "psetex", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "2.6.0", Complexity: "O(1)",
+			Summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist.", Handler: cmdPSETEX},
+		&CommandSpec{Name: "getset", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Returns the previous string value of a key after setting it to a new value.", Handler: cmdGETSET},
+		&CommandSpec{Name: "getdel", Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "6.2.0", Complexity: "O(1)",
+			Summary: "Returns the string value of a key after deleting the key.", Handler: cmdGETDEL},
+		&CommandSpec{Name: "getex", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "6.2.0", Complexity: "O(1)",
+			Summary: "Returns the string value of a key after setting its expiration time.", Handler: cmdGETEX},
+		&CommandSpec{Name: "incr", Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Handler: cmdINCR},
+		&CommandSpec{Name: "decr", Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Handler: cmdDECR},
+		&CommandSpec{Name: "incrby", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Handler: cmdINCRBY},
+		&CommandSpec{Name: "decrby", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.", Handler: cmdDECRBY},
+		&CommandSpec{Name: "incrbyfloat", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "2.6.0", Complexity: "O(1)",
+			Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Handler: cmdINCRBYFLOAT},
+		&CommandSpec{Name: "append", Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "2.0.0", Complexity: "O(1). The amortized time complexity is O(1) assuming the appended value is small and the already present value is of any size, since the dynamic string library used by Redis will double the free space available on every reallocation.",
+			Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", Handler: cmdAPPEND},
+		&CommandSpec{Name: "strlen", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "2.2.0", Complexity: "O(1)",
+			Summary: "Returns the length of a string value.", Handler: cmdSTRLEN},
+		&CommandSpec{Name: "getrange", Arity: 4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "2.4.0", Complexity: "O(N) where N is the length of the returned string. The complexity is ultimately determined by the returned length, but because creating a substring from an existing string is very cheap, it can be considered O(1) for small strings.",
+			Summary: "Returns a substring of the string stored at a key.", Handler: cmdGETRANGE},
+		&CommandSpec{Name: "setrange", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Since: "2.2.0", Complexity: "O(1), not counting the time taken to copy the new string in place. Usually, this string is very small so the amortized complexity is O(1). Otherwise, complexity is O(M) with M being the length of the value argument.",
+			Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", Handler: cmdSETRANGE},
+		&CommandSpec{Name: "mget", Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "string", Since: "1.0.0", Complexity: "O(N) where N is the number of keys to retrieve.",
+			Summary: "Atomically returns the string values of one or more keys.",
+			Tips:    []string{"request_policy:multi_shard"}, Handler: cmdMGET},
+		&CommandSpec{Name: "mset", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 2,
+			Group: "string", Since: "1.0.1", Complexity: "O(N) where N is the number of keys to set.",
+			Summary: "Atomically creates or modifies the string values of one or more keys.",
+			Tips:    []string{"request_policy:multi_shard", "response_policy:all_succeeded"}, Handler: cmdMSET},
+		&CommandSpec{Name: "msetnx", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 2,
+			Group: "string", Since: "1.0.1", Complexity: "O(N) where N is the number of keys to set.",
+			Summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.", Handler: cmdMSETNX},
+		&CommandSpec{Name: "lcs", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "string", Since: "7.0.0", Complexity: "O(N*M) where N and M are the lengths of s1 and s2, respectively",
+			Summary: "Finds the longest common substring.", Handler: cmdLCS},
+
+		// bitmap
+		&CommandSpec{Name: "setbit", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Since: "2.2.0", Complexity: "O(1)",
+			Summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.", Handler: cmdSETBIT},
+		&CommandSpec{Name: "getbit", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Since: "2.2.0", Complexity: "O(1)",
+			Summary: "Returns a bit value by offset.", Handler: cmdGETBIT},
+		&CommandSpec{Name: "bitcount", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Since: "2.6.0", Complexity: "O(N)",
+			Summary: "Counts the number of set bits (population counting) in a string.", Handler: cmdBITCOUNT},
+		&CommandSpec{Name: "bitpos", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Since: "2.8.7", Complexity: "O(N)",
+			Summary: "Finds the first set (1) or clear (0) bit in a string.", Handler: cmdBITPOS},
+		&CommandSpec{Name: "bitop", Arity: -4, Flags: FlagWrite, FirstKey: 2, LastKey: -1, Step: 1,
+			Group: "bitmap", Since: "2.6.0", Complexity: "O(N)",
+			Summary: "Performs bitwise operations on multiple strings, and stores the result.", Handler: cmdBITOP},
+		&CommandSpec{Name: "bitfield", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Since: "3.2.0", Complexity: "O(1) for each subcommand specified",
+			Summary: "Performs arbitrary bitfield integer operations on strings.", Handler: cmdBITFIELD},
+		&CommandSpec{Name: "bitfield_ro", Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Since: "6.0.0", Complexity: "O(1) for each subcommand specified",
+			Summary: "Performs arbitrary read-only bitfield integer operations on strings.", Handler: cmdBITFIELD_RO},
+
+		// hyperloglog
+		&CommandSpec{Name: "pfadd", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hyperloglog", Since: "2.8.9", Complexity: "O(1) to add every element.",
+			Summary: "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist.", Handler: cmdPFADD},
+		&CommandSpec{Name: "pfcount", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "hyperloglog", Since: "2.8.9", Complexity: "O(1) with a very small average constant time when called with a single key. O(N) with N being the number of keys, and much bigger constant times, when called with multiple keys.",
+			Summary: "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s).", Handler: cmdPFCOUNT},
+		&CommandSpec{Name: "pfmerge", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "hyperloglog", Since: "2.8.9", Complexity: "O(N) to merge N HyperLogLogs, but with high constant times.",
+			Summary: "Merges one or more HyperLogLog values into a single key.", Handler: cmdPFMERGE},
+		&CommandSpec{Name: "pfdebug", Arity: 3, Flags: FlagWrite | FlagAdmin, FirstKey: 2, LastKey: 2, Step: 1,
+			Group: "hyperloglog", Since: "2.8.9", Complexity: "N/A",
+			Summary: "Internal commands for debugging HyperLogLog values.", Handler: cmdPFDEBUG},
+
+		// generic
+		&CommandSpec{Name: "ttl", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Returns the expiration time in seconds of a key.", Handler: cmdTTL},
+		&CommandSpec{Name: "expire", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Sets the expiration time of a key in seconds.", Handler: cmdEXPIRE},
+		&CommandSpec{Name: "pexpireat", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Since: "2.6.0", Complexity: "O(1)",
+			Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", Handler: cmdPEXPIREAT},
+		&CommandSpec{Name: "del", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "generic", Since: "1.0.0", Complexity: "O(N) where N is the number of keys that will be removed.",
+			Summary: "Deletes one or more keys.",
+			Tips:    []string{"request_policy:multi_shard", "response_policy:agg_sum"}, Handler: cmdDEL},
+		&CommandSpec{Name: "exists", Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "generic", Since: "1.0.0", Complexity: "O(N) where N is the number of keys to check.",
+			Summary: "Determines whether one or more keys exist.",
+			Tips:    []string{"request_policy:multi_shard", "response_policy:agg_sum"}, Handler: cmdEXISTS},
+		&CommandSpec{Name: "object", Arity: -2, Flags: FlagReadonly, FirstKey: 2, LastKey: 2, Step: 1,
+			Group: "generic", Since: "2.2.3", Complexity: "O(1)",
+			Summary: "Returns the internal encoding of a Redis object.", Handler: cmdOBJECT},
+		&CommandSpec{Name: "type", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Determines the type of value stored at a key.", Handler: cmdTYPE},
+
+		// list
+		&CommandSpec{Name: "lpush", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", Handler: cmdLPUSH},
+		&CommandSpec{Name: "rpush", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", Handler: cmdRPUSH},
+		&CommandSpec{Name: "lpop", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(N) where N is the number of elements returned",
+			Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", Handler: cmdLPOP},
+		&CommandSpec{Name: "rpop", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(N) where N is the number of elements returned",
+			Summary: "Returns and removes the last elements of the list. Deletes the list if the last element was popped.", Handler: cmdRPOP},
+		&CommandSpec{Name: "lrange", Arity: 4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(S+N) where S is the distance of start offset from HEAD for small lists, from nearest end (HEAD or TAIL) for large lists; and N is the number of elements in the specified range.",
+			Summary: "Returns a range of elements from a list.", Handler: cmdLRANGE},
+		&CommandSpec{Name: "llen", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Returns the length of a list.", Handler: cmdLLEN},
+		&CommandSpec{Name: "lindex", Arity: 3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(N) where N is the number of elements to traverse to get to the element at index. This makes asking for the first or the last element of the list O(1).",
+			Summary: "Returns an element from a list by its index.", Handler: cmdLINDEX},
+		&CommandSpec{Name: "lset", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(N) where N is the length of the list. Setting either the first or the last element of the list is O(1).",
+			Summary: "Sets the value of an element in a list by its index.", Handler: cmdLSET},
+		&CommandSpec{Name: "lrem", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(N+M) where N is the length of the list and M is the number of elements removed.",
+			Summary: "Removes elements from a list. Deletes the list if the last element was removed.", Handler: cmdLREM},
+		&CommandSpec{Name: "ltrim", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "1.0.0", Complexity: "O(N) where N is the number of elements to be removed by the operation.",
+			Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", Handler: cmdLTRIM},
+		&CommandSpec{Name: "linsert", Arity: 5, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "2.2.0", Complexity: "O(N) where N is the number of elements to traverse before seeing the value pivot. This means that inserting somewhere on the left end on the list (head) can be considered O(1) and inserting somewhere on the right end (tail) is O(N).",
+			Summary: "Inserts an element before or after another element in a list.", Handler: cmdLINSERT},
+		&CommandSpec{Name: "lpos", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Since: "6.0.6", Complexity: "O(N) where N is the number of elements in the list, for the average case. When searching for elements near the head or the tail of the list, or when the MAXLEN option is provided, the command may run in constant time.",
+			Summary: "Returns the index of matching elements in a list.", Handler: cmdLPOS},
+		&CommandSpec{Name: "lmove", Arity: 5, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "list", Since: "6.2.0", Complexity: "O(1)",
+			Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", Handler: cmdLMOVE},
+		&CommandSpec{Name: "blpop", Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1,
+			Group: "list", Since: "2.0.0", Complexity: "O(N) where N is the number of provided keys.",
+			Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Handler: cmdBLPOP},
+		&CommandSpec{Name: "brpop", Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1,
+			Group: "list", Since: "2.0.0", Complexity: "O(N) where N is the number of provided keys.",
+			Summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Handler: cmdBRPOP},
+		&CommandSpec{Name: "blmove", Arity: 6, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "list", Since: "6.2.0", Complexity: "O(1)",
+			Summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.", Handler: cmdBLMOVE},
+
+		// sorted set
+		&CommandSpec{Name: "zadd", Arity: -4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.2.0", Complexity: "O(log(N)) for each item added, where N is the number of elements in the sorted set.",
+			Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", Handler: cmdZADD},
+		&CommandSpec{Name: "zscore", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.2.0", Complexity: "O(1)",
+			Summary: "Returns the score of a member in a sorted set.", Handler: cmdZSCORE},
+		&CommandSpec{Name: "zrank", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.0.0", Complexity: "O(log(N))",
+			Summary: "Returns the index of a member in a sorted set ordered by ascending scores.", Handler: cmdZRANK},
+		&CommandSpec{Name: "zrevrank", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.0.0", Complexity: "O(log(N))",
+			Summary: "Returns the index of a member in a sorted set ordered by descending scores.", Handler: cmdZREVRANK},
+		&CommandSpec{Name: "zrange", Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.2.0", Complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements returned.",
+			Summary: "Returns members in a sorted set within a range of indexes.", Handler: cmdZRANGE},
+		&CommandSpec{Name: "zrangebyscore", Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.0.5", Complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements being returned. If M is constant (e.g. always asking for the first 10 elements with LIMIT), you can consider it O(log(N)).",
+			Summary: "Returns members in a sorted set within a range of scores.", Handler: cmdZRANGEBYSCORE},
+		&CommandSpec{Name: "zcard", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.2.0", Complexity: "O(1)",
+			Summary: "Returns the number of members in a sorted set.", Handler: cmdZCARD},
+		&CommandSpec{Name: "zcount", Arity: 4, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.0.0", Complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+			Summary: "Returns the count of members in a sorted set that have scores within a range.", Handler: cmdZCOUNT},
+		&CommandSpec{Name: "zlexcount", Arity: 4, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.8.9", Complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+			Summary: "Returns the number of members in a sorted set within a lexicographical range.", Handler: cmdZLEXCOUNT},
+		&CommandSpec{Name: "zincrby", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.2.0", Complexity: "O(log(N)) where N is the number of elements in the sorted set.",
+			Summary: "Increments the score of a member in a sorted set.", Handler: cmdZINCRBY},
+		&CommandSpec{Name: "zpopmin", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "5.0.0", Complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+			Summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", Handler: cmdZPOPMIN},
+		&CommandSpec{Name: "zpopmax", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "5.0.0", Complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+			Summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", Handler: cmdZPOPMAX},
+		&CommandSpec{Name: "zrem", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.2.0", Complexity: "O(M*log(N)) with N being the number of elements in the sorted set and M the number of elements to be removed.",
+			Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", Handler: cmdZREM},
+		&CommandSpec{Name: "zremrangebyrank", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.0.0", Complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements removed by the operation.",
+			Summary: "Removes members in a sorted set within a range of indexes. Deletes the sorted set if all members were removed.", Handler: cmdZREMRANGEBYRANK},
+		&CommandSpec{Name: "zremrangebyscore", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "1.2.0", Complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements removed by the operation.",
+			Summary: "Removes members in a sorted set within a range of scores. Deletes the sorted set if all members were removed.", Handler: cmdZREMRANGEBYSCORE},
+		&CommandSpec{Name: "zremrangebylex", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.8.9", Complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements removed by the operation.",
+			Summary: "Removes members in a sorted set within a lexicographical range. Deletes the sorted set if all members were removed.", Handler: cmdZREMRANGEBYLEX},
+		&CommandSpec{Name: "zrandmember", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "6.2.0", Complexity: "O(N) where N is the number of members returned",
+			Summary: "Returns one or more random members from a sorted set.",
+			Tips:    []string{"nondeterministic_output"}, Handler: cmdZRANDMEMBER},
+		&CommandSpec{Name: "zmscore", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "6.2.0", Complexity: "O(N) where N is the number of members being requested.",
+			Summary: "Returns the score of one or more members in a sorted set.", Handler: cmdZMSCORE},
+		&CommandSpec{Name: "zunion", Arity: -3, Flags: FlagReadonly | FlagMovableKeys,
+			Group: "sorted_set", Since: "6.2.0", Complexity: "O(N)+O(M*log(M)) with N being the sum of the sizes of the input sorted sets, and M being the number of elements in the resulting sorted set.",
+			Summary: "Returns the union of multiple sorted sets.", GetKeys: numKeys(0), Handler: cmdZUNION},
+		&CommandSpec{Name: "zunionstore", Arity: -4, Flags: FlagWrite | FlagMovableKeys, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.0.0", Complexity: "O(N)+O(M*log(M)) with N being the sum of the sizes of the input sorted sets, and M being the number of elements in the resulting sorted set.",
+			Summary: "Stores the union of multiple sorted sets in a key.", GetKeys: numKeys(1), Handler: cmdZUNIONSTORE},
+		&CommandSpec{Name: "zinter", Arity: -3, Flags: FlagReadonly | FlagMovableKeys,
+			Group: "sorted_set", Since: "6.2.0", Complexity: "O(N*K)+O(M*log(M)) worst case with N being the smallest input sorted set, K being the number of input sorted sets and M being the number of elements in the resulting sorted set.",
+			Summary: "Returns the intersect of multiple sorted sets.", GetKeys: numKeys(0), Handler: cmdZINTER},
+		&CommandSpec{Name: "zinterstore", Arity: -4, Flags: FlagWrite | FlagMovableKeys, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "2.0.0", Complexity: "O(N*K)+O(M*log(M)) worst case with N being the smallest input sorted set, K being the number of input sorted sets and M being the number of elements in the resulting sorted set.",
+			Summary: "Stores the intersect of multiple sorted sets in a key.", GetKeys: numKeys(1), Handler: cmdZINTERSTORE},
+		&CommandSpec{Name: "zintercard", Arity: -3, Flags: FlagReadonly | FlagMovableKeys,
+			Group: "sorted_set", Since: "7.0.0", Complexity: "O(N*K) worst case with N being the smallest input sorted set, K being the number of input sorted sets.",
+			Summary: "Returns the number of members of the intersect of multiple sorted sets.", GetKeys: numKeys(0), Handler: cmdZINTERCARD},
+		&CommandSpec{Name: "zdiff", Arity: -3, Flags: FlagReadonly | FlagMovableKeys,
+			Group: "sorted_set", Since: "6.2.0", Complexity: "O(L + (N-K)log(N)) worst case where L is the total number of elements in all the sets, N is the size of the first set, and K is the size of the result set.",
+			Summary: "Returns the difference between multiple sorted sets.", GetKeys: numKeys(0), Handler: cmdZDIFF},
+		&CommandSpec{Name: "zdiffstore", Arity: -4, Flags: FlagWrite | FlagMovableKeys, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted_set", Since: "6.2.0", Complexity: "O(L + (N-K)log(N)) worst case where L is the total number of elements in all the sets, N is the size of the first set, and K is the size of the result set.",
+			Summary: "Stores the difference of multiple sorted sets in a key.", GetKeys: numKeys(1), Handler: cmdZDIFFSTORE},
+
+		// set
+		&CommandSpec{Name: "sadd", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			Summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", Handler: cmdSADD},
+		&CommandSpec{Name: "srem", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N) where N is the number of members to be removed.",
+			Summary: "Removes one or more members from a set. Deletes the set if the last member was removed.", Handler: cmdSREM},
+		&CommandSpec{Name: "smembers", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N) where N is the set cardinality.",
+			Summary: "Returns all members of a set.", Handler: cmdSMEMBERS},
+		&CommandSpec{Name: "sismember", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Determines whether a member belongs to a set.", Handler: cmdSISMEMBER},
+		&CommandSpec{Name: "smismember", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "6.2.0", Complexity: "O(N) where N is the number of elements being checked for membership",
+			Summary: "Determines whether multiple members belong to a set.", Handler: cmdSMISMEMBER},
+		&CommandSpec{Name: "scard", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Returns the number of members in a set.", Handler: cmdSCARD},
+		&CommandSpec{Name: "sunion", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Summary: "Returns the union of multiple sets.", Handler: cmdSUNION},
+		&CommandSpec{Name: "sunionstore", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Summary: "Stores the union of multiple sets in a key.", Handler: cmdSUNIONSTORE},
+		&CommandSpec{Name: "sinter", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			Summary: "Returns the intersect of multiple sets.", Handler: cmdSINTER},
+		&CommandSpec{Name: "sinterstore", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			Summary: "Stores the intersect of multiple sets in a key.", Handler: cmdSINTERSTORE},
+		&CommandSpec{Name: "sintercard", Arity: -3, Flags: FlagReadonly | FlagMovableKeys,
+			Group: "set", Since: "7.0.0", Complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			Summary: "Returns the number of members of the intersect of multiple sets.", GetKeys: numKeys(0), Handler: cmdSINTERCARD},
+		&CommandSpec{Name: "sdiff", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Summary: "Returns the difference of multiple sets.", Handler: cmdSDIFF},
+		&CommandSpec{Name: "sdiffstore", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Summary: "Stores the difference of multiple sets in a key.", Handler: cmdSDIFFSTORE},
+		&CommandSpec{Name: "smove", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Moves a member from one set to another.", Handler: cmdSMOVE},
+		&CommandSpec{Name: "spop", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "Without the count argument O(1), otherwise O(N) where N is the value of the passed count.",
+			Summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.", Tips: []string{"nondeterministic_output"}, Handler: cmdSPOP},
+		&CommandSpec{Name: "srandmember", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "1.0.0", Complexity: "Without the count argument O(1), otherwise O(N) where N is the absolute value of the passed count.",
+			Summary: "Get one or multiple random members from a set", Tips: []string{"nondeterministic_output"}, Handler: cmdSRANDMEMBER},
+		&CommandSpec{Name: "sscan", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Since: "2.8.0", Complexity: "O(1) for every call. O(N) for a complete iteration, including enough command calls for the cursor to return back to 0. N is the number of elements inside the collection.",
+			Summary: "Iterates over members of a set.", Tips: []string{"nondeterministic_output"}, Handler: cmdSSCAN},
+
+		// hash
+		&CommandSpec{Name: "hset", Arity: -4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(1) for each field/value pair added, so O(N) to add N field/value pairs when the command is called with multiple field/value pairs.",
+			Summary: "Creates or modifies the value of a field in a hash.", Handler: cmdHSET},
+		&CommandSpec{Name: "hsetnx", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Sets the value of a field in a hash only when the field doesn't exist.", Handler: cmdHSETNX},
+		&CommandSpec{Name: "hget", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Returns the value of a field in a hash.", Handler: cmdHGET},
+		&CommandSpec{Name: "hmget", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(N) where N is the number of fields being requested.",
+			Summary: "Returns the values of all fields in a hash.", Handler: cmdHMGET},
+		&CommandSpec{Name: "hdel", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(N) where N is the number of fields to be removed.",
+			Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", Handler: cmdHDEL},
+		&CommandSpec{Name: "hexists", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Determines whether a field exists in a hash.", Handler: cmdHEXISTS},
+		&CommandSpec{Name: "hlen", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Returns the number of fields in a hash.", Handler: cmdHLEN},
+		&CommandSpec{Name: "hstrlen", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "3.2.0", Complexity: "O(1)",
+			Summary: "Returns the length of the value of a field.", Handler: cmdHSTRLEN},
+		&CommandSpec{Name: "hkeys", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(N) where N is the size of the hash.",
+			Summary: "Returns all fields in a hash.",
+			Tips:    []string{"nondeterministic_output_order"}, Handler: cmdHKEYS},
+		&CommandSpec{Name: "hvals", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(N) where N is the size of the hash.",
+			Summary: "Returns all values in a hash.",
+			Tips:    []string{"nondeterministic_output_order"}, Handler: cmdHVALS},
+		&CommandSpec{Name: "hgetall", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(N) where N is the size of the hash.",
+			Summary: "Returns all fields and values in a hash.",
+			Tips:    []string{"nondeterministic_output_order"}, Handler: cmdHGETALL},
+		&CommandSpec{Name: "hincrby", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", Handler: cmdHINCRBY},
+		&CommandSpec{Name: "hincrbyfloat", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "2.6.0", Complexity: "O(1)",
+			Summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.", Handler: cmdHINCRBYFLOAT},
+		&CommandSpec{Name: "hrandfield", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Since: "6.2.0", Complexity: "O(N) where N is the number of fields returned",
+			Summary: "Returns one or more random fields from a hash.",
+			Tips:    []string{"nondeterministic_output"}, Handler: cmdHRANDFIELD},
+
+		// count-min sketch
+		&CommandSpec{Name: "cms.initbydim", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Initializes a Count-Min Sketch to dimensions specified by user", Handler: cmdCMSINITBYDIM},
+		&CommandSpec{Name: "cms.initbyprob", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Since: "2.0.0", Complexity: "O(1)",
+			Summary: "Initializes a Count-Min Sketch to accommodate requested tolerances.", Handler: cmdCMSINITBYPROB},
+		&CommandSpec{Name: "cms.incrby", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Since: "2.0.0", Complexity: "O(n) where n is the number of items",
+			Summary: "Increases the count of one or more items by increment", Handler: cmdCMSINCRBY},
+		&CommandSpec{Name: "cms.query", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Since: "2.0.0", Complexity: "O(n) where n is the number of items",
+			Summary: "Returns the count for one or more items in a sketch", Handler: cmdCMSQUERY},
+
+		// bloom filter
+		&CommandSpec{Name: "bf.reserve", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Creates a new Bloom Filter", Handler: cmdBFRESERVE},
+		&CommandSpec{Name: "bf.madd", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(k * n), where k is the number of hash functions and n is the number of items",
+			Summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist", Handler: cmdBFMADD},
+		&CommandSpec{Name: "bf.exists", Arity: 3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(k), where k is the number of hash functions used by the last sub-filter",
+			Summary: "Checks whether an item exists in a Bloom Filter", Handler: cmdBFEXISTS},
+		&CommandSpec{Name: "bf.add", Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(k), where k is the number of hash functions used by the last sub-filter",
+			Summary: "Adds an item to a Bloom Filter", Handler: cmdBFADD},
+		&CommandSpec{Name: "bf.mexists", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(k * n), where k is the number of hash functions and n is the number of items",
+			Summary: "Checks whether one or more items exist in a Bloom Filter", Handler: cmdBFMEXISTS},
+		&CommandSpec{Name: "bf.insert", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(k * n), where k is the number of hash functions and n is the number of items",
+			Summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist", Handler: cmdBFINSERT},
+		&CommandSpec{Name: "bf.info", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Returns information about a Bloom Filter", Handler: cmdBFINFO},
+		&CommandSpec{Name: "bf.card", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "2.4.4", Complexity: "O(1)",
+			Summary: "Returns the cardinality of a Bloom filter", Handler: cmdBFCARD},
+		&CommandSpec{Name: "bf.scandump", Arity: 3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(n), where n is the capacity",
+			Summary: "Begins an incremental save of the bloom filter", Handler: cmdBFSCANDUMP},
+		&CommandSpec{Name: "bf.loadchunk", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Since: "1.0.0", Complexity: "O(n), where n is the capacity",
+			Summary: "Restores a filter previously saved using SCANDUMP", Handler: cmdBFLOADCHUNK},
+
+		// cuckoo filter
+		&CommandSpec{Name: "cf.reserve", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Creates a new Cuckoo Filter", Handler: cmdCFRESERVE},
+		&CommandSpec{Name: "cf.add", Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(k + i), where k is the number of sub-filters and i is maxIterations",
+			Summary: "Adds an item to a Cuckoo Filter", Handler: cmdCFADD},
+		&CommandSpec{Name: "cf.addnx", Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(k + i), where k is the number of sub-filters and i is maxIterations",
+			Summary: "Adds an item to a Cuckoo Filter if the item did not exist previously.", Handler: cmdCFADDNX},
+		&CommandSpec{Name: "cf.insert", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(n * (k + i)), where n is the number of items, k is the number of sub-filters and i is maxIterations",
+			Summary: "Adds one or more items to a Cuckoo Filter. A filter will be created if it does not exist", Handler: cmdCFINSERT},
+		&CommandSpec{Name: "cf.exists", Arity: 3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(k), where k is the number of sub-filters",
+			Summary: "Checks if the item exists in a Cuckoo Filter", Handler: cmdCFEXISTS},
+		&CommandSpec{Name: "cf.mexists", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(k * n), where k is the number of sub-filters and n is the number of items",
+			Summary: "Checks whether one or more items exist in a Cuckoo Filter", Handler: cmdCFMEXISTS},
+		&CommandSpec{Name: "cf.del", Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(k), where k is the number of sub-filters",
+			Summary: "Deletes an item from a Cuckoo Filter", Handler: cmdCFDEL},
+		&CommandSpec{Name: "cf.count", Arity: 3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(k), where k is the number of sub-filters",
+			Summary: "Return the number of times an item might be in a Cuckoo Filter", Handler: cmdCFCOUNT},
+		&CommandSpec{Name: "cf.info", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Since: "1.0.0", Complexity: "O(1)",
+			Summary: "Returns information about a Cuckoo Filter", Handler: cmdCFINFO},
+	)
+}
@@ -5,19 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
-	"syscall"
+	"strings"
 	"time"
 
 	"goredis-lite/internal/constant"
 	"goredis-lite/internal/data_structure"
 )
 
-func cmdPING(args []string) []byte {
+func cmdPING(s *Storage, c *Client, args []string) []byte {
 	var res []byte
 	if len(args) > 1 {
 		return Encode(errors.New("ERR wrong number of arguments for 'ping' command"), false)
 	}
-	
+
 	if len(args) == 0 {
 		res = Encode("PONG", true)
 	} else {
@@ -26,56 +26,17 @@ func cmdPING(args []string) []byte {
 	return res
 }
 
-func cmdSET(args []string) []byte {
-	if len(args) < 2 || len(args) == 3 || len(args) > 4 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SET' command"), false)
-	}
-
-	var key, value string
-	var ttlMs int64 = -1
-
-	key, value = args[0], args[1]
-	if len(args) > 2 {
-		ttlSec, err := strconv.ParseInt(args[3], 10, 64)
-		if err != nil {
-			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
-		}
-		ttlMs = ttlSec * 1000
-	}
-
-	dictStore.Set(key, dictStore.NewObj(key, value, ttlMs))
-	return constant.RespOk
-}
-
-func cmdGET(args []string) []byte {
-	if len(args) != 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'GET' command"), false)
-	}
-
-	key := args[0]
-	obj := dictStore.Get(key)
-	if obj == nil {
-		return constant.RespNil
-	}
-
-	if dictStore.HasExpired(key) {
-		return constant.RespNil
-	}
-
-	return Encode(obj.Value, false)
-}
-
-func cmdTTL(args []string) []byte {
+func cmdTTL(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 1 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'TTL' command"), false)
 	}
 	key := args[0]
-	obj := dictStore.Get(key)
+	obj := s.dictStore.Get(key)
 	if obj == nil {
 		return constant.TtlKeyNotExist
 	}
 
-	exp, isExpirySet := dictStore.GetExpiry(key)
+	exp, isExpirySet := s.dictStore.GetExpiry(key)
 	if !isExpirySet {
 		return constant.TtlKeyExistNoExpire
 	}
@@ -88,7 +49,7 @@ func cmdTTL(args []string) []byte {
 	return Encode(int64(remainMs/1000), false)
 }
 
-func cmdEXPIRE(args []string) []byte {
+func cmdEXPIRE(s *Storage, c *Client, args []string) []byte {
 	if len(args) != 2 {
 		return Encode(errors.New("ERR wrong number of arguments for 'expire' command"), false)
 	}
@@ -100,16 +61,40 @@ func cmdEXPIRE(args []string) []byte {
 		return Encode(errors.New("ERR value is not an integer or out of range"), false)
 	}
 
-	obj := dictStore.Get(key)
+	obj := s.dictStore.Get(key)
 	if obj == nil {
 		return constant.RespZero // Key doesn't exist, return 0
 	}
 
-	dictStore.SetExpiry(key, ttlSec*1000)
+	s.dictStore.SetExpiry(key, ttlSec*1000)
+	s.dirty++
 	return constant.RespOne // Successfully set expiration, return 1
 }
 
-func cmdDEL(args []string) []byte {
+// PEXPIREAT key unix-time-milliseconds
+func cmdPEXPIREAT(s *Storage, c *Client, args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("ERR wrong number of arguments for 'pexpireat' command"), false)
+	}
+
+	key := args[0]
+
+	expireAtMs, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errors.New("ERR value is not an integer or out of range"), false)
+	}
+
+	obj := s.dictStore.Get(key)
+	if obj == nil {
+		return constant.RespZero
+	}
+
+	s.dictStore.SetExpireAt(key, expireAtMs)
+	s.dirty++
+	return constant.RespOne
+}
+
+func cmdDEL(s *Storage, c *Client, args []string) []byte {
 	if len(args) == 0 {
 		return Encode(errors.New("ERR wrong number of arguments for 'del' command"), false)
 	}
@@ -118,18 +103,19 @@ func cmdDEL(args []string) []byte {
 
 	for _, key := range args {
 		// Check if key exists and is not expired before deleting
-		obj := dictStore.Get(key)
+		obj := s.dictStore.Get(key)
 		if obj != nil {
-			if dictStore.Del(key) {
+			if s.dictStore.Del(key) {
 				deletedCount++
 			}
 		}
 	}
+	s.dirty += deletedCount
 
 	return Encode(deletedCount, false)
 }
 
-func cmdEXISTS(args []string) []byte {
+func cmdEXISTS(s *Storage, c *Client, args []string) []byte {
 	if len(args) == 0 {
 		return Encode(errors.New("ERR wrong number of arguments for 'exists' command"), false)
 	}
@@ -139,7 +125,7 @@ func cmdEXISTS(args []string) []byte {
 	// EXISTS can check multiple keys at once
 	// Returns count of how many keys exist
 	for _, key := range args {
-		obj := dictStore.Get(key)
+		obj := s.dictStore.Get(key)
 		if obj != nil {
 			existsCount++
 		}
@@ -148,67 +134,72 @@ func cmdEXISTS(args []string) []byte {
 	return Encode(existsCount, false)
 }
 
-func cmdINFO(args []string) []byte {
-	buf := &bytes.Buffer{}
-	buf.WriteString("# Keyspace\r\n")
-	buf.WriteString(fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0\r\n", data_structure.HashKeySpaceStat.Key))
-	return Encode(buf.String(), false)
+// OBJECT ENCODING key
+func cmdOBJECT(s *Storage, c *Client, args []string) []byte {
+	if strings.ToUpper(args[0]) != "ENCODING" {
+		return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try OBJECT HELP.", args[0]), false)
+	}
+	if len(args) != 2 {
+		return Encode(errors.New("ERR wrong number of arguments for 'object|encoding' command"), false)
+	}
+	encoding, exist := s.encoding(args[1])
+	if !exist {
+		return EncodeWithProto(nil, false, c.Proto)
+	}
+	return Encode(encoding, false)
 }
 
-// ExecuteAndResponse given a Command, executes it and responses
-func ExecuteAndResponse(cmd *Command, connFd int) error {
-	var res []byte
+// encoding returns the representation of the value at key in memory, named
+// like Redis does
+func (s *Storage) encoding(key string) (string, bool) {
+	obj := s.dictStore.Get(key)
+	if obj == nil {
+		return "", false
+	}
+	switch obj.Type {
+	case data_structure.ObjString:
+		switch v := obj.Value.(type) {
+		case int64:
+			return "int", true
+		case string:
+			if len(v) <= 44 {
+				return "embstr", true
+			}
+		}
+	case data_structure.ObjList:
+		return obj.Value.(*data_structure.List).Encoding(), true
+	case data_structure.ObjSet:
+		return obj.Value.(*data_structure.SimpleSet).Encoding(), true
+	case data_structure.ObjZSet:
+		return obj.Value.(*data_structure.SortedSet).Encoding(), true
+	case data_structure.ObjHash:
+		return obj.Value.(*data_structure.Hash).Encoding(), true
+	}
+	return "raw", true
+}
+
+// TYPE key
+func cmdTYPE(s *Storage, c *Client, args []string) []byte {
+	obj := s.dictStore.Get(args[0])
+	if obj == nil {
+		return Encode("none", true)
+	}
+	return Encode(obj.Type.String(), true)
+}
+
+func cmdINFO(s *Storage, c *Client, args []string) []byte {
+	buf := &bytes.Buffer{}
+	stat := s.dictStore.Stat()
+	buf.WriteString("# Stats\r\n")
+	buf.WriteString(fmt.Sprintf("expired_keys:%d\r\n", stat.Expired))
+	buf.WriteString(fmt.Sprintf("expired_time_cap_reached_count:%d\r\n", s.expireTimeCapReached))
+	buf.WriteString("\r\n# Keyspace\r\n")
+	buf.WriteString(fmt.Sprintf("db0:keys=%d,expires=%d,avg_ttl=0\r\n", stat.Key, stat.Expire))
+	return EncodeWithProto(VerbatimString{Format: "txt", Text: buf.String()}, false, c.Proto)
+}
 
-	switch cmd.Cmd {
-	case "PING":
-		res = cmdPING(cmd.Args)
-	case "SET":
-		res = cmdSET(cmd.Args)
-	case "GET":
-		res = cmdGET(cmd.Args)
-	case "TTL":
-		res = cmdTTL(cmd.Args)
-	case "EXPIRE":
-		res = cmdEXPIRE(cmd.Args)
-	case "DEL":
-		res = cmdDEL(cmd.Args)
-	case "EXISTS":
-		res = cmdEXISTS(cmd.Args)
-	case "INFO":
-		res = cmdINFO(cmd.Args)
-	case "ZADD":
-		res = cmdZADD(cmd.Args)
-	case "ZSCORE":
-		res = cmdZSCORE(cmd.Args)
-	case "ZRANK":
-		res = cmdZRANK(cmd.Args)
-	case "SADD":
-		res = cmdSADD(cmd.Args)
-	case "SREM":
-		res = cmdSREM(cmd.Args)
-	case "SMEMBERS":
-		res = cmdSMEMBERS(cmd.Args)
-	case "SISMEMBER":
-		res = cmdSISMEMBER(cmd.Args)
-	// Count-Min Sketch
-	case "CMS.INITBYDIM":
-		res = cmdCMSINITBYDIM(cmd.Args)
-	case "CMS.INITBYPROB":
-		res = cmdCMSINITBYPROB(cmd.Args)
-	case "CMS.INCRBY":
-		res = cmdCMSINCRBY(cmd.Args)
-	case "CMS.QUERY":
-		res = cmdCMSQUERY(cmd.Args)
-	// Bloom filter
-	case "BF.RESERVE":
-		res = cmdBFRESERVE(cmd.Args)
-	case "BF.MADD":
-		res = cmdBFMADD(cmd.Args)
-	case "BF.EXISTS":
-		res = cmdBFEXISTS(cmd.Args)
-	default:
-		res = []byte(fmt.Sprintf("-CMD NOT FOUND\r\n"))
-	}
-	_, err := syscall.Write(connFd, res)
-	return err
+// Execute runs cmd sent by client c against defaultStorage and returns the
+// reply, nil when a blocking command has to wait
+func Execute(cmd *Command, c *Client) []byte {
+	return ExecuteCommand(defaultStorage, cmd, c)
 }
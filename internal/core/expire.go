@@ -5,30 +5,38 @@ import (
 	"time"
 )
 
+// ActiveDeleteExpiredKeys runs an expiration cycle on the storage of the
+// single-threaded server
 func ActiveDeleteExpiredKeys() {
+	defaultStorage.activeDeleteExpiredKeys()
+}
+
+// activeDeleteExpiredKeys samples keys with a TTL and deletes the expired ones,
+// sampling again while more than ActiveExpireThreshold of the sample had
+// expired, for at most MaxActiveExpireExecutionTime
+func (s *Storage) activeDeleteExpiredKeys() {
 	startTime := time.Now()
-	
+
 	for {
 		var expiredCount = 0
 		var sampleCountRemain = constant.ActiveExpireSampleSize
-		
-		for key, expiredTime := range dictStore.GetExpireDictStore() {
-			sampleCountRemain--
+
+		for key := range s.dictStore.GetExpireDictStore() {
 			if sampleCountRemain <= 0 {
 				break
 			}
-			if time.Now().UnixMilli() > int64(expiredTime) {
-				dictStore.Del(key)
+			sampleCountRemain--
+			if s.dictStore.DeleteIfExpired(key) {
 				expiredCount++
 			}
-			
 		}
 
 		if float64(expiredCount)/float64(constant.ActiveExpireSampleSize) <= constant.ActiveExpireThreshold {
 			break
 		}
-		
+
 		if time.Since(startTime) >= constant.MaxActiveExpireExecutionTime {
+			s.expireTimeCapReached++
 			break
 		}
 	}
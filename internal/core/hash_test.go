@@ -0,0 +1,37 @@
+package core_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func TestHashRandField(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "HSET", "hash", "f", "v")
+
+	assert.Equal(t, "*4\r\n$1\r\nf\r\n$1\r\nv\r\n$1\r\nf\r\n$1\r\nv\r\n", run(s, c, "HRANDFIELD", "hash", "-2", "WITHVALUES"))
+	assert.Equal(t, "-ERR value is out of range\r\n", run(s, c, "HRANDFIELD", "hash", "-2147483647"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(s, c, "HRANDFIELD", "hash", "x"))
+	assert.Equal(t, "$1\r\nf\r\n", run(s, c, "HRANDFIELD", "hash"))
+	assert.Equal(t, "*1\r\n$1\r\nf\r\n", run(s, c, "HRANDFIELD", "hash", "5"))
+	assert.Equal(t, "*0\r\n", run(s, c, "HRANDFIELD", "hash", "0"))
+	assert.Equal(t, "*0\r\n", run(s, c, "HRANDFIELD", "missing", "-3"))
+
+	// a hash past the listpack limits is sampled too, each field with its value
+	for i := 0; i < 1000; i++ {
+		run(s, c, "HSET", "large", "f"+strconv.Itoa(i), "v"+strconv.Itoa(i))
+	}
+	for _, count := range []string{"10", "-10"} {
+		reply, err := core.Decode([]byte(run(s, c, "HRANDFIELD", "large", count, "WITHVALUES")))
+		assert.NoError(t, err)
+		pairs := reply.([]interface{})
+		assert.Len(t, pairs, 20)
+		for i := 0; i < len(pairs); i += 2 {
+			assert.Equal(t, "v"+pairs[i].(string)[1:], pairs[i+1])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package core_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":1\r\n", run(s, c, "PFADD", "empty"))
+	assert.Equal(t, ":0\r\n", run(s, c, "PFADD", "empty"))
+	assert.Equal(t, ":0\r\n", run(s, c, "PFCOUNT", "empty"))
+	assert.Equal(t, ":1\r\n", run(s, c, "PFADD", "a", "x", "y", "z"))
+	assert.Equal(t, ":0\r\n", run(s, c, "PFADD", "a", "x"))
+	assert.Equal(t, ":3\r\n", run(s, c, "PFCOUNT", "a"))
+	run(s, c, "PFADD", "b", "z", "w")
+	assert.Equal(t, ":4\r\n", run(s, c, "PFCOUNT", "a", "b", "missing"))
+	assert.Equal(t, ":0\r\n", run(s, c, "PFCOUNT", "missing"))
+
+	run(s, c, "EXPIRE", "a", "100")
+	assert.Equal(t, "+OK\r\n", run(s, c, "PFMERGE", "a", "b"))
+	assert.Equal(t, ":4\r\n", run(s, c, "PFCOUNT", "a"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(s, c, "TTL", "a"))
+	assert.Equal(t, "+OK\r\n", run(s, c, "PFMERGE", "dest", "a", "missing"))
+	assert.Equal(t, ":4\r\n", run(s, c, "PFCOUNT", "dest"))
+
+	// a HyperLogLog is a string, that can be copied through GET and SET
+	assert.Equal(t, "+string\r\n", run(s, c, "TYPE", "a"))
+	assert.Equal(t, "$4\r\nHYLL\r\n", run(s, c, "GETRANGE", "a", "0", "3"))
+	v := run(s, c, "GET", "a")
+	run(s, c, "SET", "copy", v[strings.Index(v, "\r\n")+2:len(v)-2])
+	assert.Equal(t, ":4\r\n", run(s, c, "PFCOUNT", "copy"))
+
+	run(s, c, "SET", "str", "hello")
+	run(s, c, "RPUSH", "list", "a")
+	assert.Equal(t, "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n", run(s, c, "PFADD", "str", "a"))
+	assert.Equal(t, "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n", run(s, c, "PFCOUNT", "a", "str"))
+	assert.Equal(t, wrongType, run(s, c, "PFMERGE", "dest", "list"))
+	run(s, c, "SET", "bad", "HYLL\x01\x00")
+	assert.Equal(t, "-INVALIDOBJ Corrupted HLL object detected\r\n", run(s, c, "PFCOUNT", "bad"))
+}
+
+func TestPFDEBUG(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "PFADD", "h", "a")
+
+	assert.Equal(t, "+sparse\r\n", run(s, c, "PFDEBUG", "ENCODING", "h"))
+	assert.Regexp(t, `^\+(Z|XZ):\d+ v:\d+,1 (Z|XZ):\d+\r\n$`, run(s, c, "PFDEBUG", "DECODE", "h"))
+	assert.Equal(t, ":1\r\n", run(s, c, "PFDEBUG", "TODENSE", "h"))
+	assert.Equal(t, ":0\r\n", run(s, c, "PFDEBUG", "TODENSE", "h"))
+	assert.Equal(t, "+dense\r\n", run(s, c, "PFDEBUG", "ENCODING", "h"))
+	assert.Equal(t, "-ERR HLL encoding is not sparse\r\n", run(s, c, "PFDEBUG", "DECODE", "h"))
+	assert.Equal(t, ":1\r\n", run(s, c, "PFCOUNT", "h"))
+	assert.True(t, strings.HasPrefix(run(s, c, "PFDEBUG", "GETREG", "h"), "*16384\r\n:"))
+	assert.Equal(t, "-ERR The specified key does not exist\r\n", run(s, c, "PFDEBUG", "GETREG", "missing"))
+	assert.Equal(t, "-ERR Unknown PFDEBUG subcommand 'foo'\r\n", run(s, c, "PFDEBUG", "foo", "h"))
+
+	// enough elements make the key dense by themselves
+	args := []string{"PFADD", "big"}
+	for i := 0; i < 5000; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	run(s, c, args...)
+	assert.Equal(t, "+dense\r\n", run(s, c, "PFDEBUG", "ENCODING", "big"))
+	assert.Equal(t, ":12293\r\n", run(s, c, "STRLEN", "big"))
+}
@@ -0,0 +1,57 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+func TestKeyspaceWrongType(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "RPUSH", "list", "a")
+	run(s, c, "SET", "str", "v")
+
+	assert.Equal(t, wrongType, run(s, c, "GET", "list"))
+	assert.Equal(t, wrongType, run(s, c, "SADD", "list", "a"))
+	assert.Equal(t, wrongType, run(s, c, "ZADD", "str", "1", "a"))
+	assert.Equal(t, wrongType, run(s, c, "HSET", "str", "f", "v"))
+	assert.Equal(t, wrongType, run(s, c, "LMOVE", "list", "str", "LEFT", "LEFT"))
+	assert.Equal(t, wrongType, run(s, c, "ZUNION", "2", "list", "str"))
+	// nothing was popped or created
+	assert.Equal(t, ":1\r\n", run(s, c, "LLEN", "list"))
+	assert.Equal(t, "$1\r\nv\r\n", run(s, c, "GET", "str"))
+
+	// SET and the STORE commands replace a key of any type
+	assert.Equal(t, "+OK\r\n", run(s, c, "SET", "list", "v"))
+	assert.Equal(t, "+string\r\n", run(s, c, "TYPE", "list"))
+	run(s, c, "SADD", "set", "a")
+	assert.Equal(t, ":1\r\n", run(s, c, "SUNIONSTORE", "str", "set"))
+	assert.Equal(t, "+set\r\n", run(s, c, "TYPE", "str"))
+}
+
+func TestKeyspaceGenericCommands(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "RPUSH", "list", "a")
+	run(s, c, "HSET", "hash", "f", "v")
+	run(s, c, "ZADD", "zset", "1", "a")
+	run(s, c, "BF.MADD", "bf", "a")
+
+	for key, typ := range map[string]string{"list": "list", "hash": "hash", "zset": "zset", "bf": "MBbloom--", "missing": "none"} {
+		assert.Equal(t, "+"+typ+"\r\n", run(s, c, "TYPE", key))
+	}
+	assert.Equal(t, ":4\r\n", run(s, c, "EXISTS", "list", "hash", "zset", "bf", "missing"))
+	assert.Equal(t, ":1\r\n", run(s, c, "EXPIRE", "list", "100"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(s, c, "TTL", "list"))
+	assert.Equal(t, ":2\r\n", run(s, c, "DEL", "list", "hash"))
+	assert.Equal(t, ":-2\r\n", run(s, c, "TTL", "list"))
+
+	// a list recreated after DEL has no TTL
+	run(s, c, "RPUSH", "list", "a")
+	assert.Equal(t, ":-1\r\n", run(s, c, "TTL", "list"))
+	assert.Equal(t, "+none\r\n", run(s, c, "TYPE", "hash"))
+}
@@ -0,0 +1,625 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goredis-lite/internal/config"
+	"goredis-lite/internal/constant"
+	"goredis-lite/internal/data_structure"
+)
+
+// RDB file layout:
+//
+//	"GOREDIS" <version, 4 digits>
+//	{ [EXPIRETIME_MS <unix ms, 8 bytes>] <type, 1 byte> <key> <value> }
+//	EOF <crc64 of everything before, 8 bytes>
+//
+// Integers are little endian, lengths are uvarints and strings are a length
+// followed by their bytes.
+const (
+	rdbMagic   = "GOREDIS"
+	rdbVersion = "0001"
+
+	rdbTypeString        = 0  // <string>
+	rdbTypeList          = 1  // <len> <element>...
+	rdbTypeSet           = 2  // <len> <member>...
+	rdbTypeZSet          = 3  // <len> { <member> <score, float64 bits> }...
+	rdbTypeHash          = 4  // <len> { <field> <value> }...
+	rdbTypeCMS           = 16 // <CMS.MarshalBinary as a string>
+	rdbTypeBloom         = 17 // <Bloom.MarshalBinary as a string>, a filter saved before they could scale
+	rdbTypeScalableBloom = 18 // <ScalableBloom.MarshalBinary as a string>
+	rdbTypeCuckoo        = 19 // <CuckooFilter.MarshalBinary as a string>
+
+	rdbOpExpireTimeMs = 0xFC
+	rdbOpEOF          = 0xFF
+)
+
+var errRDBCorrupt = errors.New("bad RDB file")
+
+var rdbCrcTable = crc64.MakeTable(crc64.ECMA)
+
+// rdbEntry is one key read back from an RDB file
+type rdbEntry struct {
+	key      string
+	expireAt int64       // unix ms, 0 without TTL
+	value    interface{} // string, []string, []data_structure.Item, *Hash, *CMS, *ScalableBloom or *CuckooFilter
+}
+
+type rdbWriter struct {
+	buf []byte
+}
+
+func (w *rdbWriter) writeByte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *rdbWriter) writeUvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *rdbWriter) writeUint64(v uint64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, v)
+}
+
+func (w *rdbWriter) writeString(s string) {
+	w.writeUvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *rdbWriter) writeKey(typ byte, key string) {
+	w.writeByte(typ)
+	w.writeString(key)
+}
+
+type rdbReader struct {
+	data []byte
+}
+
+func (r *rdbReader) readByte() (byte, error) {
+	if len(r.data) == 0 {
+		return 0, errRDBCorrupt
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b, nil
+}
+
+func (r *rdbReader) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errRDBCorrupt
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+func (r *rdbReader) readUint64() (uint64, error) {
+	if len(r.data) < 8 {
+		return 0, errRDBCorrupt
+	}
+	v := binary.LittleEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v, nil
+}
+
+func (r *rdbReader) readString() (string, error) {
+	n, err := r.readUvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(len(r.data)) {
+		return "", errRDBCorrupt
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s, nil
+}
+
+// readValue decodes the value of a key of type typ
+func (r *rdbReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case rdbTypeString:
+		return r.readString()
+	case rdbTypeList:
+		n, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		list := data_structure.NewList()
+		for i := uint64(0); i < n; i++ {
+			element, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			list.PushTail(element)
+		}
+		return list, nil
+	case rdbTypeSet:
+		n, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		members := make([]string, 0, min(n, uint64(len(r.data))))
+		for i := uint64(0); i < n; i++ {
+			member, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, member)
+		}
+		return members, nil
+	case rdbTypeZSet:
+		n, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		items := make([]data_structure.Item, 0, min(n, uint64(len(r.data))))
+		for i := uint64(0); i < n; i++ {
+			member, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			score, err := r.readUint64()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, data_structure.Item{Score: math.Float64frombits(score), Member: member})
+		}
+		return items, nil
+	case rdbTypeHash:
+		n, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		hash := data_structure.NewHash()
+		for i := uint64(0); i < n; i++ {
+			field, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(field, value)
+		}
+		return hash, nil
+	case rdbTypeCMS:
+		data, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		cms := &data_structure.CMS{}
+		if err := cms.UnmarshalBinary([]byte(data)); err != nil {
+			return nil, fmt.Errorf("%w: %v", errRDBCorrupt, err)
+		}
+		return cms, nil
+	case rdbTypeBloom:
+		data, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		bloom := &data_structure.Bloom{}
+		if err := bloom.UnmarshalBinary([]byte(data)); err != nil {
+			return nil, fmt.Errorf("%w: %v", errRDBCorrupt, err)
+		}
+		return data_structure.NewScalableBloomFromLayer(bloom, constant.BfDefaultExpansion), nil
+	case rdbTypeScalableBloom:
+		data, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		bloom := &data_structure.ScalableBloom{}
+		if err := bloom.UnmarshalBinary([]byte(data)); err != nil {
+			return nil, fmt.Errorf("%w: %v", errRDBCorrupt, err)
+		}
+		return bloom, nil
+	case rdbTypeCuckoo:
+		data, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		cuckoo := &data_structure.CuckooFilter{}
+		if err := cuckoo.UnmarshalBinary([]byte(data)); err != nil {
+			return nil, fmt.Errorf("%w: %v", errRDBCorrupt, err)
+		}
+		return cuckoo, nil
+	}
+	return nil, fmt.Errorf("%w: unknown value type %d", errRDBCorrupt, typ)
+}
+
+// dumpRDB serializes every key of s. It must run on the goroutine owning s,
+// writeRDB puts the dumps of all storages in one file.
+func (s *Storage) dumpRDB() []byte {
+	w := &rdbWriter{}
+	for key, obj := range s.dictStore.GetDictStore() {
+		if !s.dictStore.HasExpired(key) {
+			s.dumpKey(w, key, obj)
+		}
+	}
+	return w.buf
+}
+
+// DumpKeys serializes the keys of s among keys like dumpRDB does, for
+// ExecuteOnDumps. It must run on the goroutine owning s.
+func (s *Storage) DumpKeys(keys []string) []byte {
+	w := &rdbWriter{}
+	for _, key := range keys {
+		if obj := s.dictStore.Get(key); obj != nil {
+			s.dumpKey(w, key, obj)
+		}
+	}
+	return w.buf
+}
+
+// dumpKey writes key, holding obj, and its TTL
+func (s *Storage) dumpKey(w *rdbWriter, key string, obj *data_structure.Obj) {
+	if expireAt, exist := s.dictStore.GetExpiry(key); exist {
+		w.writeByte(rdbOpExpireTimeMs)
+		w.writeUint64(uint64(expireAt))
+	}
+	switch v := obj.Value.(type) {
+	case string:
+		w.writeKey(rdbTypeString, key)
+		w.writeString(v)
+	case int64:
+		w.writeKey(rdbTypeString, key)
+		w.writeString(strconv.FormatInt(v, 10))
+	case []byte:
+		w.writeKey(rdbTypeString, key)
+		w.writeString(string(v))
+	case *data_structure.HyperLogLog:
+		// loaded back as a string, decoded by the next PF command
+		data, _ := v.MarshalBinary()
+		w.writeKey(rdbTypeString, key)
+		w.writeString(string(data))
+	case *data_structure.List:
+		elements := v.Range(0, -1)
+		w.writeKey(rdbTypeList, key)
+		w.writeUvarint(uint64(len(elements)))
+		for _, element := range elements {
+			w.writeString(element)
+		}
+	case *data_structure.SimpleSet:
+		members := v.Members()
+		w.writeKey(rdbTypeSet, key)
+		w.writeUvarint(uint64(len(members)))
+		for _, member := range members {
+			w.writeString(member)
+		}
+	case *data_structure.SortedSet:
+		w.writeKey(rdbTypeZSet, key)
+		items := v.Items(0, v.Len())
+		w.writeUvarint(uint64(len(items)))
+		for _, item := range items {
+			w.writeString(item.Member)
+			w.writeUint64(math.Float64bits(item.Score))
+		}
+	case *data_structure.Hash:
+		pairs := v.Pairs()
+		w.writeKey(rdbTypeHash, key)
+		w.writeUvarint(uint64(len(pairs) / 2))
+		for _, p := range pairs {
+			w.writeString(p)
+		}
+	case *data_structure.CMS:
+		data, _ := v.MarshalBinary()
+		w.writeKey(rdbTypeCMS, key)
+		w.writeString(string(data))
+	case *data_structure.ScalableBloom:
+		data, _ := v.MarshalBinary()
+		w.writeKey(rdbTypeScalableBloom, key)
+		w.writeString(string(data))
+	case *data_structure.CuckooFilter:
+		data, _ := v.MarshalBinary()
+		w.writeKey(rdbTypeCuckoo, key)
+		w.writeString(string(data))
+	}
+}
+
+// restoreRDB adds e to s. Keys whose TTL elapsed while the server was down
+// are skipped.
+func (s *Storage) restoreRDB(e *rdbEntry) {
+	if e.expireAt > 0 && e.expireAt <= time.Now().UnixMilli() {
+		return
+	}
+	switch v := e.value.(type) {
+	case string:
+		s.add(e.key, data_structure.ObjString, data_structure.NewStringValue(v))
+	case *data_structure.List:
+		s.add(e.key, data_structure.ObjList, v)
+	case []string:
+		set := data_structure.NewSimpleSet(e.key)
+		set.Add(v...)
+		s.add(e.key, data_structure.ObjSet, set)
+	case []data_structure.Item:
+		zset := data_structure.NewSortedSet(constant.DefaultBPlusTreeDegree)
+		for _, item := range v {
+			zset.Add(item.Score, item.Member)
+		}
+		s.add(e.key, data_structure.ObjZSet, zset)
+	case *data_structure.Hash:
+		s.add(e.key, data_structure.ObjHash, v)
+	case *data_structure.CMS:
+		s.add(e.key, data_structure.ObjCMS, v)
+	case *data_structure.ScalableBloom:
+		s.add(e.key, data_structure.ObjBloom, v)
+	case *data_structure.CuckooFilter:
+		s.add(e.key, data_structure.ObjCuckoo, v)
+	default:
+		return
+	}
+	if e.expireAt > 0 {
+		s.dictStore.SetExpireAt(e.key, e.expireAt)
+	}
+}
+
+// encodeRDB builds an RDB file out of the dumps of one or more storages
+func encodeRDB(dumps [][]byte) []byte {
+	size := len(rdbMagic) + len(rdbVersion) + 9
+	for _, dump := range dumps {
+		size += len(dump)
+	}
+	buf := make([]byte, 0, size)
+	buf = append(buf, rdbMagic...)
+	buf = append(buf, rdbVersion...)
+	for _, dump := range dumps {
+		buf = append(buf, dump...)
+	}
+	buf = append(buf, rdbOpEOF)
+	return binary.LittleEndian.AppendUint64(buf, crc64.Checksum(buf, rdbCrcTable))
+}
+
+// writeRDB writes dumps into a temporary file next to path and renames it
+// over path once synced, so a crash never leaves a truncated snapshot
+func writeRDB(path string, dumps [][]byte) error {
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf("temp-%d.rdb", os.Getpid()))
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(encodeRDB(dumps)); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// readRDB decodes the RDB file at path and calls fn for every key in it
+func readRDB(path string, fn func(e *rdbEntry)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rest, err := decodeRDB(data, fn)
+	if err == nil && len(rest) != 0 {
+		err = fmt.Errorf("%w: data after EOF", errRDBCorrupt)
+	}
+	return err
+}
+
+// decodeRDB decodes the RDB file at the start of data, calls fn for every key
+// in it and returns what follows its checksum
+func decodeRDB(data []byte, fn func(e *rdbEntry)) ([]byte, error) {
+	header := len(rdbMagic) + len(rdbVersion)
+	if len(data) < header+9 || string(data[:len(rdbMagic)]) != rdbMagic {
+		return nil, fmt.Errorf("%w: wrong signature", errRDBCorrupt)
+	}
+	if version := string(data[len(rdbMagic):header]); version != rdbVersion {
+		return nil, fmt.Errorf("%w: unsupported version %s", errRDBCorrupt, version)
+	}
+
+	// entries are only handed to fn once the checksum is verified
+	var entries []*rdbEntry
+	r := &rdbReader{data: data[header:]}
+	var expireAt int64
+	for {
+		typ, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case rdbOpEOF:
+			body := data[:len(data)-len(r.data)]
+			checksum, err := r.readUint64()
+			if err != nil {
+				return nil, err
+			}
+			if crc64.Checksum(body, rdbCrcTable) != checksum {
+				return nil, fmt.Errorf("%w: checksum mismatch", errRDBCorrupt)
+			}
+			for _, e := range entries {
+				fn(e)
+			}
+			return r.data, nil
+		case rdbOpExpireTimeMs:
+			v, err := r.readUint64()
+			if err != nil {
+				return nil, err
+			}
+			expireAt = int64(v)
+			continue
+		}
+		key, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.readValue(typ)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &rdbEntry{key: key, expireAt: expireAt, value: value})
+		expireAt = 0
+	}
+}
+
+// rdbState is the snapshot bookkeeping shared by every storage of the server
+type rdbState struct {
+	mu           sync.Mutex
+	saving       bool      // a SAVE or BGSAVE is running
+	lastSave     time.Time // last successful save
+	lastSaveTry  time.Time
+	lastSaveOk   bool
+	bgsave       sync.WaitGroup // running background writes
+	dirty        int64          // writes since the last successful save, updated atomically
+	dirtyAtStart int64          // dirty when the running save started
+}
+
+var rdb = &rdbState{
+	lastSave:   time.Now(),
+	lastSaveOk: true,
+}
+
+var errSaveInProgress = errors.New("ERR Background save already in progress")
+
+func rdbPath() string {
+	return filepath.Join(config.Dir, config.RdbFileName)
+}
+
+// begin claims the right to save
+func (r *rdbState) begin() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.saving {
+		return errSaveInProgress
+	}
+	r.saving = true
+	r.lastSaveTry = time.Now()
+	r.dirtyAtStart = atomic.LoadInt64(&r.dirty)
+	return nil
+}
+
+func (r *rdbState) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saving = false
+	r.lastSaveOk = err == nil
+	if err == nil {
+		r.lastSave = time.Now()
+		atomic.AddInt64(&r.dirty, -r.dirtyAtStart)
+	}
+}
+
+// dumpAll serializes every partition, all of them at the same time
+func dumpAll() [][]byte {
+	dumps := make([][]byte, partitions.Count())
+	runAll(func(id int, s *Storage) {
+		dumps[id] = s.dumpRDB()
+	})
+	return dumps
+}
+
+// Save writes a snapshot of every storage and returns once it is on disk
+func Save() error {
+	if err := rdb.begin(); err != nil {
+		return err
+	}
+	err := writeRDB(rdbPath(), dumpAll())
+	rdb.finish(err)
+	return err
+}
+
+// BackgroundSave serializes every storage in memory, which is the only part
+// that blocks the partitions, then writes the file on its own goroutine
+func BackgroundSave() error {
+	if err := rdb.begin(); err != nil {
+		return err
+	}
+	dumps := dumpAll()
+	rdb.bgsave.Add(1)
+	go func() {
+		defer rdb.bgsave.Done()
+		err := writeRDB(rdbPath(), dumps)
+		if err != nil {
+			log.Println("background saving error:", err)
+		} else {
+			log.Println("background saving terminated with success")
+		}
+		rdb.finish(err)
+	}()
+	return nil
+}
+
+// CheckSaveRules starts a BackgroundSave when one of config.SaveRules is met.
+// A failed save is retried after BgSaveRetryDelay.
+func CheckSaveRules() {
+	dirty := atomic.LoadInt64(&rdb.dirty)
+	rdb.mu.Lock()
+	saving, lastSave, lastSaveTry, lastSaveOk := rdb.saving, rdb.lastSave, rdb.lastSaveTry, rdb.lastSaveOk
+	rdb.mu.Unlock()
+	if saving || (!lastSaveOk && time.Since(lastSaveTry) < constant.BgSaveRetryDelay) {
+		return
+	}
+	for _, rule := range config.SaveRules {
+		if dirty >= int64(rule.Changes) && time.Since(lastSave) >= time.Duration(rule.Seconds)*time.Second {
+			log.Printf("%d changes in %d seconds. Saving...", rule.Changes, rule.Seconds)
+			if err := BackgroundSave(); err != nil {
+				log.Println("background saving error:", err)
+			}
+			return
+		}
+	}
+}
+
+// LoadRDB loads the snapshot file into the partitions set by UsePartitions,
+// routing every key to the partition owning it. A missing file is not an
+// error, the server then starts empty.
+func LoadRDB() error {
+	var entries []*rdbEntry
+	err := readRDB(rdbPath(), func(e *rdbEntry) {
+		entries = append(entries, e)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	restoreAll(entries)
+	log.Printf("DB loaded from disk: %d keys", len(entries))
+	return nil
+}
+
+// ExecuteOnDumps runs cmd, a read-only command whose keys are owned by
+// several partitions, against a storage holding copies of them: the dumps
+// made by DumpKeys on each partition
+func ExecuteOnDumps(dumps [][]byte, cmd *Command, c *Client) []byte {
+	s := NewStorage()
+	if _, err := decodeRDB(encodeRDB(dumps), s.restoreRDB); err != nil {
+		return Encode(err, false)
+	}
+	return ExecuteCommand(s, cmd, c)
+}
+
+// restoreAll adds entries to the partitions owning their keys
+func restoreAll(entries []*rdbEntry) {
+	byPartition := make([][]*rdbEntry, partitions.Count())
+	for _, e := range entries {
+		id := partitions.PartitionOf(e.key)
+		byPartition[id] = append(byPartition[id], e)
+	}
+	runAll(func(id int, s *Storage) {
+		for _, e := range byPartition[id] {
+			s.restoreRDB(e)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/config"
+	"goredis-lite/internal/core"
+)
+
+// partitions splits keys by their first byte over a fixed set of storages
+type partitions []*core.Storage
+
+func (p partitions) Count() int                 { return len(p) }
+func (p partitions) PartitionOf(key string) int { return int(key[0]) % len(p) }
+func (p partitions) Run(id int, fn func(s *core.Storage)) {
+	fn(p[id])
+}
+func (p partitions) Execute(cmd *core.Command, c *core.Client) []byte {
+	return core.ExecuteCommand(p[p.PartitionOf(cmd.Args[0])], cmd, c)
+}
+
+func run(s *core.Storage, c *core.Client, args ...string) string {
+	return string(core.ExecuteCommand(s, &core.Command{Cmd: args[0], Args: args[1:]}, c))
+}
+
+func TestRDBSaveAndLoad(t *testing.T) {
+	config.Dir = t.TempDir()
+	c := core.NewClient()
+
+	src := core.NewStorage()
+	run(src, c, "SET", "str", "value")
+	run(src, c, "SET", "ttl", "value", "EX", "100")
+	run(src, c, "INCRBY", "counter", "42")
+	run(src, c, "SETBIT", "bits", "9", "1")
+	run(src, c, "RPUSH", "list", "a", "b", "c")
+	run(src, c, "SADD", "set", "a", "b")
+	run(src, c, "ZADD", "zset", "1.5", "a", "2", "b")
+	run(src, c, "CMS.INITBYDIM", "cms", "100", "4")
+	run(src, c, "CMS.INCRBY", "cms", "a", "3")
+	run(src, c, "BF.MADD", "bf", "a", "b")
+	run(src, c, "PFADD", "hll", "a", "b", "c")
+	run(src, c, "CF.INSERT", "cf", "ITEMS", "a", "a", "b")
+	core.UsePartitions(partitions{src})
+	assert.NoError(t, core.Save())
+
+	// load into more partitions than were saved
+	dst := partitions{core.NewStorage(), core.NewStorage(), core.NewStorage()}
+	core.UsePartitions(dst)
+	assert.NoError(t, core.LoadRDB())
+
+	at := func(key string) *core.Storage { return dst[dst.PartitionOf(key)] }
+	assert.Equal(t, "$5\r\nvalue\r\n", run(at("str"), c, "GET", "str"))
+	assert.Equal(t, ":-1\r\n", run(at("str"), c, "TTL", "str"))
+	assert.Equal(t, ":43\r\n", run(at("counter"), c, "INCR", "counter"))
+	assert.Equal(t, "$2\r\n\x00\x40\r\n", run(at("bits"), c, "GET", "bits"))
+	ttl := run(at("ttl"), c, "TTL", "ttl")
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, ttl)
+	assert.Equal(t, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n", run(at("list"), c, "LRANGE", "list", "0", "-1"))
+	assert.Equal(t, ":1\r\n", run(at("set"), c, "SISMEMBER", "set", "b"))
+	assert.Equal(t, ":1\r\n", run(at("zset"), c, "ZRANK", "zset", "b"))
+	assert.Equal(t, "*1\r\n$1\r\n3\r\n", run(at("cms"), c, "CMS.QUERY", "cms", "a"))
+	assert.Equal(t, ":1\r\n", run(at("bf"), c, "BF.EXISTS", "bf", "b"))
+	assert.Equal(t, ":0\r\n", run(at("bf"), c, "BF.EXISTS", "bf", "c"))
+	assert.Equal(t, ":3\r\n", run(at("hll"), c, "PFCOUNT", "hll"))
+	assert.Equal(t, ":2\r\n", run(at("cf"), c, "CF.COUNT", "cf", "a"))
+}
+
+func TestRDBLoadMissingFile(t *testing.T) {
+	config.Dir = t.TempDir()
+	core.UsePartitions(partitions{core.NewStorage()})
+	assert.NoError(t, core.LoadRDB())
+}
+
+func TestRDBLoadCorrupt(t *testing.T) {
+	config.Dir = t.TempDir()
+	s := core.NewStorage()
+	run(s, core.NewClient(), "SET", "k", "v")
+	core.UsePartitions(partitions{s})
+	assert.NoError(t, core.Save())
+
+	path := filepath.Join(config.Dir, config.RdbFileName)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[len(data)-10] ^= 0xFF
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+	assert.Error(t, core.LoadRDB())
+}
+
+func TestExecuteOnDumps(t *testing.T) {
+	s1, s2 := core.NewStorage(), core.NewStorage()
+	c := core.NewClient()
+	run(s1, c, "SADD", "a", "x", "y")
+	run(s2, c, "SADD", "b", "y", "z")
+	run(s2, c, "SET", "str", "v")
+	run(s2, c, "SET", "gone", "v", "PX", "1")
+	time.Sleep(2 * time.Millisecond)
+
+	dumps := [][]byte{s1.DumpKeys([]string{"a"}), s2.DumpKeys([]string{"b", "missing", "gone"})}
+	sinter := &core.Command{Cmd: "SINTER", Args: []string{"a", "b"}}
+	assert.Equal(t, "*1\r\n$1\r\ny\r\n", string(core.ExecuteOnDumps(dumps, sinter, c)))
+	sdiff := &core.Command{Cmd: "SDIFF", Args: []string{"a", "b", "gone"}}
+	assert.Equal(t, "*1\r\n$1\r\nx\r\n", string(core.ExecuteOnDumps(dumps, sdiff, c)))
+
+	dumps = [][]byte{s1.DumpKeys([]string{"a"}), s2.DumpKeys([]string{"str"})}
+	assert.Equal(t, wrongType, string(core.ExecuteOnDumps(dumps, &core.Command{Cmd: "SUNION", Args: []string{"a", "str"}}, c)))
+}
@@ -4,40 +4,118 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
+
+	"goredis-lite/internal/config"
+	"goredis-lite/internal/constant"
 )
 
 const CRLF string = "\r\n"
 
 var RespNil = []byte("$-1\r\n")
 
+// RespNilArray is the RESP2 null array, e.g. the reply of a timed out BLPOP
+var RespNilArray = []byte("*-1\r\n")
+
+// RespNull is the RESP3 null, replacing both the null bulk string and the null array
+var RespNull = []byte("_\r\n")
+
+// Protocol versions a client can negotiate with HELLO
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// SimpleString is encoded as a status reply wherever it appears, e.g. inside arrays
+type SimpleString string
+
+// VerbatimString is a RESP3 string carrying a three letters format hint, e.g. "txt"
+type VerbatimString struct {
+	Format string
+	Text   string
+}
+
+// Map is a RESP3 map, stored flat as key, value, key, value... to keep the order
+type Map []interface{}
+
+// Set is a RESP3 set
+type Set []interface{}
+
+// Push is a RESP3 out-of-band push message
+type Push []interface{}
+
+// ErrIncomplete is returned by the decoders when the buffer ends in the middle
+// of a frame. The caller should keep the bytes and retry once more data arrives.
+var ErrIncomplete = errors.New("incomplete RESP frame")
+
+// ErrProtocol is returned when the buffer can never become a valid frame.
+var ErrProtocol = errors.New("ERR Protocol error")
+
+// Lengths are checked against the limits before anything is allocated or
+// buffered, so that a single header can't exhaust the memory
+var (
+	errMultibulkLen = fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+	errBulkLen      = fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+)
+
+// readLine returns the index of the '\r' that ends the line starting at data[start:]
+func readLine(data []byte, start int) (int, error) {
+	idx := bytes.IndexByte(data[start:], '\r')
+	if idx < 0 {
+		return 0, ErrIncomplete
+	}
+	end := start + idx
+	if end+1 >= len(data) {
+		return 0, ErrIncomplete
+	}
+	if data[end+1] != '\n' {
+		return 0, ErrProtocol
+	}
+	return end, nil
+}
+
 // +OK\r\n => OK, 5
 func readSimpleString(data []byte) (string, int, error) {
-	pos := 1
-	for data[pos] != '\r' {
-		pos++
+	end, err := readLine(data, 1)
+	if err != nil {
+		return "", 0, err
 	}
-	return string(data[1:pos]), pos + 2, nil
+	return string(data[1:end]), end + 2, nil
 }
 
 // :123\r\n => 123
 func readInt64(data []byte) (int64, int, error) {
+	end, err := readLine(data, 1)
+	if err != nil {
+		return 0, 0, err
+	}
 	var res int64 = 0
 	pos := 1
 	var sign int64 = 1
-	if data[pos] == '-' {
+	if pos < end && data[pos] == '-' {
 		sign = -1
 		pos++
-	}
-	if data[pos] == '+' {
+	} else if pos < end && data[pos] == '+' {
 		pos++
 	}
-	for data[pos] != '\r' {
-		res = res*10 + int64(data[pos]-'0')
-		pos++
+	if pos == end {
+		return 0, 0, ErrProtocol
+	}
+	for ; pos < end; pos++ {
+		if data[pos] < '0' || data[pos] > '9' {
+			return 0, 0, ErrProtocol
+		}
+		digit := int64(data[pos] - '0')
+		if res > (math.MaxInt64-digit)/10 {
+			return 0, 0, ErrProtocol
+		}
+		res = res*10 + digit
 	}
 
-	return sign * res, pos + 2, nil
+	return sign * res, end + 2, nil
 }
 
 func readError(data []byte) (string, int, error) {
@@ -45,36 +123,195 @@ func readError(data []byte) (string, int, error) {
 }
 
 // $5\r\nhello\r\n => 5, 4
-func readLen(data []byte) (int, int) {
-	res, pos, _ := readInt64(data)
-	return int(res), pos
+func readLen(data []byte) (int, int, error) {
+	res, pos, err := readInt64(data)
+	return int(res), pos, err
 }
 
 // $5\r\nhello\r\n => "hello"
-func readBulkString(data []byte) (string, int, error) {
-	length, pos := readLen(data)
+func readBulkString(data []byte) (interface{}, int, error) {
+	length, pos, err := readLen(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	// $-1\r\n is the null bulk string
+	if length < 0 {
+		return nil, pos, nil
+	}
+	if length > config.ProtoMaxBulkLen {
+		return nil, 0, errBulkLen
+	}
+	if pos+length+2 > len(data) {
+		return nil, 0, ErrIncomplete
+	}
+	if data[pos+length] != '\r' || data[pos+length+1] != '\n' {
+		return nil, 0, ErrProtocol
+	}
 	return string(data[pos:(pos + length)]), pos + length + 2, nil
 }
 
 // *2\r\n$5\r\nhello\r\n$5\r\nworld\r\n => {"hello", "world"}
 func readArray(data []byte) (interface{}, int, error) {
-	length, pos := readLen(data)
-	var res []interface{} = make([]interface{}, length)
+	length, pos, err := readLen(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	// *-1\r\n is the null array
+	if length < 0 {
+		return nil, pos, nil
+	}
+	if length > constant.MaxMultibulkLen {
+		return nil, 0, errMultibulkLen
+	}
+	res, delta, err := readElements(data[pos:], length)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, pos + delta, nil
+}
 
-	for i := range res {
+// readElements decodes n consecutive frames, the body of every aggregate type.
+// res grows with the frames received rather than with n, which is only
+// announced.
+func readElements(data []byte, n int) ([]interface{}, int, error) {
+	res := make([]interface{}, 0, min(n, 1024))
+	pos := 0
+	for len(res) < n {
 		elem, delta, err := DecodeOne(data[pos:])
 		if err != nil {
 			return nil, 0, err
 		}
-		res[i] = elem
+		res = append(res, elem)
 		pos += delta
 	}
 	return res, pos, nil
 }
 
+// _\r\n => nil
+func readNull(data []byte) (interface{}, int, error) {
+	end, err := readLine(data, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if end != 1 {
+		return nil, 0, ErrProtocol
+	}
+	return nil, end + 2, nil
+}
+
+// #t\r\n => true
+func readBoolean(data []byte) (bool, int, error) {
+	end, err := readLine(data, 1)
+	if err != nil {
+		return false, 0, err
+	}
+	if end != 2 || (data[1] != 't' && data[1] != 'f') {
+		return false, 0, ErrProtocol
+	}
+	return data[1] == 't', end + 2, nil
+}
+
+// ,3.14\r\n => 3.14
+func readDouble(data []byte) (float64, int, error) {
+	end, err := readLine(data, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := strconv.ParseFloat(string(data[1:end]), 64)
+	if err != nil {
+		return 0, 0, ErrProtocol
+	}
+	return f, end + 2, nil
+}
+
+// (3492890328409238509324850943850943825024385\r\n => *big.Int
+func readBigNumber(data []byte) (*big.Int, int, error) {
+	end, err := readLine(data, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	n, ok := new(big.Int).SetString(string(data[1:end]), 10)
+	if !ok {
+		return nil, 0, ErrProtocol
+	}
+	return n, end + 2, nil
+}
+
+// =15\r\ntxt:Some string\r\n => VerbatimString{"txt", "Some string"}
+func readVerbatimString(data []byte) (interface{}, int, error) {
+	value, pos, err := readBulkString(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	text, ok := value.(string)
+	if !ok || len(text) < 4 || text[3] != ':' {
+		return nil, 0, ErrProtocol
+	}
+	return VerbatimString{Format: text[:3], Text: text[4:]}, pos, nil
+}
+
+// %1\r\n+key\r\n:1\r\n => Map{"key", 1}
+func readMap(data []byte) (interface{}, int, error) {
+	length, pos, err := readLen(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length < 0 {
+		return nil, 0, ErrProtocol
+	}
+	if length > constant.MaxMultibulkLen {
+		return nil, 0, errMultibulkLen
+	}
+	res, delta, err := readElements(data[pos:], 2*length)
+	if err != nil {
+		return nil, 0, err
+	}
+	return Map(res), pos + delta, nil
+}
+
+// ~2\r\n+a\r\n+b\r\n => Set{"a", "b"}
+func readSet(data []byte) (interface{}, int, error) {
+	res, pos, err := readArray(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if res == nil {
+		return nil, 0, ErrProtocol
+	}
+	return Set(res.([]interface{})), pos, nil
+}
+
+// >2\r\n+message\r\n+hi\r\n => Push{"message", "hi"}
+func readPush(data []byte) (interface{}, int, error) {
+	res, pos, err := readArray(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if res == nil {
+		return nil, 0, ErrProtocol
+	}
+	return Push(res.([]interface{})), pos, nil
+}
+
+// |1\r\n+ttl\r\n:3600\r\n<value> => <value>
+// Attributes are auxiliary data sent ahead of a reply, they are skipped.
+func readAttribute(data []byte) (interface{}, int, error) {
+	_, pos, err := readMap(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	value, delta, err := DecodeOne(data[pos:])
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, pos + delta, nil
+}
+
+// DecodeOne decodes the first frame of data and returns it with the number of
+// bytes consumed. ErrIncomplete means data holds only a prefix of the frame.
 func DecodeOne(data []byte) (interface{}, int, error) {
 	if len(data) == 0 {
-		return nil, 0, errors.New("no data")
+		return nil, 0, ErrIncomplete
 	}
 	switch data[0] {
 	case '+':
@@ -87,8 +324,28 @@ func DecodeOne(data []byte) (interface{}, int, error) {
 		return readBulkString(data)
 	case '*':
 		return readArray(data)
+	case '_':
+		return readNull(data)
+	case '#':
+		return readBoolean(data)
+	case ',':
+		return readDouble(data)
+	case '(':
+		return readBigNumber(data)
+	case '!':
+		return readBulkString(data)
+	case '=':
+		return readVerbatimString(data)
+	case '%':
+		return readMap(data)
+	case '~':
+		return readSet(data)
+	case '>':
+		return readPush(data)
+	case '|':
+		return readAttribute(data)
 	}
-	return nil, 0, nil
+	return nil, 0, ErrProtocol
 }
 
 // RESP format data => raw data
@@ -110,14 +367,55 @@ func encodeStringArray(sa []string) []byte {
 	return []byte(fmt.Sprintf("*%d\r\n%s", len(sa), buf.Bytes()))
 }
 
+// encodeAggregate writes the header of an aggregate type followed by its
+// elements. RESP2 clients get every aggregate as a flat array.
+func encodeAggregate(prefix byte, length int, elems []interface{}, proto int) []byte {
+	if proto != RESP3 {
+		prefix, length = '*', len(elems)
+	}
+	var b []byte
+	buf := bytes.NewBuffer(b)
+	buf.WriteString(fmt.Sprintf("%c%d\r\n", prefix, length))
+	for _, x := range elems {
+		buf.Write(EncodeWithProto(x, false, proto))
+	}
+	return buf.Bytes()
+}
+
+// formatDouble renders f the way Redis does: shortest round-trip
+// representation, exponent notation only for very large or small values
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	abs := math.Abs(f)
+	if f == 0 || (abs >= 1e-4 && abs < 1e21) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
 // raw data => RESP format data
 func Encode(value interface{}, isSimpleString bool) []byte {
+	return EncodeWithProto(value, isSimpleString, RESP2)
+}
+
+// EncodeWithProto encodes value for a client speaking protocol version proto.
+// The RESP3-only types are downgraded to their RESP2 equivalent when proto is RESP2.
+func EncodeWithProto(value interface{}, isSimpleString bool, proto int) []byte {
 	switch v := value.(type) {
 	case string:
 		if isSimpleString {
 			return []byte(fmt.Sprintf("+%s%s", v, CRLF))
 		}
 		return []byte(fmt.Sprintf("$%d%s%s%s", len(v), CRLF, v, CRLF))
+	case SimpleString:
+		return []byte(fmt.Sprintf("+%s%s", v, CRLF))
 	case int64, int32, int16, int8, int:
 		return []byte(fmt.Sprintf(":%d\r\n", v))
 	case error:
@@ -132,28 +430,265 @@ func Encode(value interface{}, isSimpleString bool) []byte {
 		}
 		return []byte(fmt.Sprintf("*%d\r\n%s", len(value.([][]string)), buf.Bytes()))
 	case []interface{}:
-		var b []byte
-		buf := bytes.NewBuffer(b)
-		for _, x := range value.([]interface{}) {
-			buf.Write(Encode(x, false))
+		return encodeAggregate('*', len(v), v, proto)
+	case float64:
+		if proto == RESP3 {
+			return []byte(fmt.Sprintf(",%s\r\n", formatDouble(v)))
 		}
-		return []byte(fmt.Sprintf("*%d\r\n%s", len(value.([]interface{})), buf.Bytes()))
+		return encodeString(formatDouble(v))
+	case bool:
+		if proto == RESP3 {
+			if v {
+				return []byte("#t\r\n")
+			}
+			return []byte("#f\r\n")
+		}
+		if v {
+			return []byte(":1\r\n")
+		}
+		return []byte(":0\r\n")
+	case *big.Int:
+		if proto == RESP3 {
+			return []byte(fmt.Sprintf("(%s\r\n", v.String()))
+		}
+		return encodeString(v.String())
+	case VerbatimString:
+		if proto == RESP3 {
+			return []byte(fmt.Sprintf("=%d\r\n%s:%s\r\n", len(v.Text)+4, v.Format, v.Text))
+		}
+		return encodeString(v.Text)
+	case Map:
+		return encodeAggregate('%', len(v)/2, v, proto)
+	case Set:
+		return encodeAggregate('~', len(v), v, proto)
+	case Push:
+		return encodeAggregate('>', len(v), v, proto)
 	default:
+		if proto == RESP3 {
+			return RespNull
+		}
 		return RespNil
 	}
 }
 
+// toCommand converts a decoded RESP array into a Command. An empty array
+// yields a nil command, which callers skip like Redis does.
+func toCommand(value interface{}) (*Command, error) {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, ErrProtocol
+	}
+	if len(array) == 0 {
+		return nil, nil
+	}
+	tokens := make([]string, len(array))
+	for i := range tokens {
+		token, ok := array[i].(string)
+		if !ok {
+			return nil, ErrProtocol
+		}
+		tokens[i] = token
+	}
+	return &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, nil
+}
+
+// readCommand decodes the request at the start of data, either a RESP array
+// or an inline command, and returns the number of bytes consumed
+func readCommand(data []byte) (*Command, int, error) {
+	if len(data) > 0 && data[0] != '*' {
+		tokens, n, err := readInline(data)
+		if err != nil || len(tokens) == 0 {
+			return nil, n, err
+		}
+		return &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, n, nil
+	}
+	value, n, err := DecodeOne(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	cmd, err := toCommand(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cmd, n, nil
+}
+
+// SET foo "bar baz"\r\n => {"SET", "foo", "bar baz"}
+// Inline commands are what a human types through telnet or netcat, the line
+// may end with a bare \n.
+func readInline(data []byte) ([]string, int, error) {
+	idx := bytes.IndexByte(data, '\n')
+	if idx < 0 {
+		if len(data) > constant.MaxInlineSize {
+			return nil, 0, fmt.Errorf("%w: too big inline request", ErrProtocol)
+		}
+		return nil, 0, ErrIncomplete
+	}
+	line := data[:idx]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	tokens, err := splitInlineArgs(string(line))
+	if err != nil {
+		return nil, 0, err
+	}
+	return tokens, idx + 1, nil
+}
+
+// splitInlineArgs splits line into arguments following the quoting rules of
+// redis-cli: "double quotes" understand \n \r \t \b \a \\ \" and \xHH escapes,
+// 'single quotes' only understand \', and a closing quote must be followed by
+// a space or the end of the line.
+func splitInlineArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isInlineSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var cur []byte
+		inDouble, inSingle, done := false, false, false
+		for !done {
+			if inDouble {
+				if i == len(line) {
+					return nil, errUnbalancedQuotes
+				}
+				if line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' &&
+					isHexDigit(line[i+2]) && isHexDigit(line[i+3]) {
+					b, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					cur = append(cur, byte(b))
+					i += 3
+				} else if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						cur = append(cur, '\n')
+					case 'r':
+						cur = append(cur, '\r')
+					case 't':
+						cur = append(cur, '\t')
+					case 'b':
+						cur = append(cur, '\b')
+					case 'a':
+						cur = append(cur, '\a')
+					default:
+						cur = append(cur, line[i])
+					}
+				} else if line[i] == '"' {
+					// closing quote must be followed by a space or nothing at all
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				} else {
+					cur = append(cur, line[i])
+				}
+			} else if inSingle {
+				if i == len(line) {
+					return nil, errUnbalancedQuotes
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					cur = append(cur, '\'')
+				} else if line[i] == '\'' {
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				} else {
+					cur = append(cur, line[i])
+				}
+			} else {
+				if i == len(line) {
+					break
+				}
+				switch line[i] {
+				case ' ', '\n', '\r', '\t', 0:
+					done = true
+				case '"':
+					inDouble = true
+				case '\'':
+					inSingle = true
+				default:
+					cur = append(cur, line[i])
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, string(cur))
+	}
+}
+
+var errUnbalancedQuotes = fmt.Errorf("%w: unbalanced quotes in request", ErrProtocol)
+
+func isInlineSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// ParseCmd parses a single request, RESP array or inline
 func ParseCmd(data []byte) (*Command, error) {
-	value, err := Decode(data)
+	cmd, _, err := readCommand(data)
 	if err != nil {
 		return nil, err
 	}
+	if cmd == nil {
+		return nil, ErrProtocol
+	}
+	return cmd, nil
+}
 
-	array := value.([]interface{})
-	tokens := make([]string, len(array))
-	for i := range tokens {
-		tokens[i] = array[i].(string)
+// RespReader accumulates the bytes read from one connection and splits them
+// into commands. A frame cut in half by the kernel stays in the buffer until
+// the rest of it arrives, and pipelined commands are all returned at once.
+type RespReader struct {
+	buf []byte
+}
+
+func NewRespReader() *RespReader {
+	return &RespReader{}
+}
+
+// Feed appends freshly read bytes to the pending buffer
+func (r *RespReader) Feed(data []byte) {
+	r.buf = append(r.buf, data...)
+}
+
+// Buffered returns the number of bytes waiting for the rest of their frame
+func (r *RespReader) Buffered() int {
+	return len(r.buf)
+}
+
+// ReadCommands returns every complete command in the buffer, in order.
+// On a protocol error the commands decoded before the bad frame are
+// returned together with the error, and the buffer is discarded.
+func (r *RespReader) ReadCommands() ([]*Command, error) {
+	var cmds []*Command
+	pos := 0
+	for pos < len(r.buf) {
+		cmd, n, err := readCommand(r.buf[pos:])
+		if err == ErrIncomplete {
+			break
+		}
+		if err != nil {
+			r.buf = nil
+			return cmds, err
+		}
+		pos += n
+		if cmd != nil {
+			cmds = append(cmds, cmd)
+		}
 	}
-	res := &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}
-	return res, nil
+	// keep only the unconsumed tail
+	r.buf = append(r.buf[:0], r.buf[pos:]...)
+	return cmds, nil
 }
@@ -1,9 +1,13 @@
 package core_test
 
 import (
-	"goredis-lite/internal/core"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+	"math"
+	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -114,3 +118,212 @@ func TestParseCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeIncomplete(t *testing.T) {
+	cases := []string{
+		"",
+		"+OK",
+		"+OK\r",
+		":12",
+		"$5\r\nhel",
+		"$5\r\nhello\r",
+		"*2\r\n$5\r\nhello\r\n",
+		"*2\r\n$5\r\nhello\r\n$5\r\nwor",
+		"~2\r\n+a\r\n",
+		">2\r\n+message\r\n+h",
+	}
+	for _, c := range cases {
+		_, _, err := core.DecodeOne([]byte(c))
+		assert.Equal(t, core.ErrIncomplete, err, c)
+	}
+}
+
+func TestDecodeProtocolError(t *testing.T) {
+	cases := []string{
+		":abc\r\n",
+		"$3\r\nhello\r\n",
+		"*1\r\n?3\r\n",
+		"~-1\r\n",
+		">-1\r\n",
+	}
+	for _, c := range cases {
+		_, _, err := core.DecodeOne([]byte(c))
+		assert.Equal(t, core.ErrProtocol, err, c)
+	}
+}
+
+func TestDecodeLengthLimits(t *testing.T) {
+	cases := map[string]string{
+		"*99999999999999\r\n":                   "ERR Protocol error: invalid multibulk length",
+		"*1048577\r\n":                          "ERR Protocol error: invalid multibulk length",
+		"%1048577\r\n":                          "ERR Protocol error: invalid multibulk length",
+		"~1048577\r\n":                          "ERR Protocol error: invalid multibulk length",
+		"$99999999999999\r\n":                   "ERR Protocol error: invalid bulk length",
+		"*1\r\n$536870913\r\n":                  "ERR Protocol error: invalid bulk length",
+		"$99999999999999999999999999999999\r\n": "ERR Protocol error",
+	}
+	for c, msg := range cases {
+		_, _, err := core.DecodeOne([]byte(c))
+		assert.True(t, errors.Is(err, core.ErrProtocol), c)
+		assert.EqualError(t, err, msg, c)
+	}
+
+	// the limits themselves are accepted, waiting for the rest of the frame
+	for _, c := range []string{"*1048576\r\n", "$536870912\r\n"} {
+		_, _, err := core.DecodeOne([]byte(c))
+		assert.Equal(t, core.ErrIncomplete, err, c)
+	}
+}
+
+func TestRespReaderPartialFrame(t *testing.T) {
+	frame := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"
+	r := core.NewRespReader()
+	for i := 0; i < len(frame)-1; i++ {
+		r.Feed([]byte{frame[i]})
+		cmds, err := r.ReadCommands()
+		assert.Nil(t, err)
+		assert.Empty(t, cmds)
+	}
+	r.Feed([]byte{frame[len(frame)-1]})
+	cmds, err := r.ReadCommands()
+	assert.Nil(t, err)
+	assert.Len(t, cmds, 1)
+	assert.Equal(t, "SET", cmds[0].Cmd)
+	assert.Equal(t, []string{"key", "value"}, cmds[0].Args)
+	assert.Equal(t, 0, r.Buffered())
+}
+
+func TestRespReaderPipeline(t *testing.T) {
+	r := core.NewRespReader()
+	r.Feed([]byte("*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$1\r\na\r\n*2\r\n$3\r\nGET"))
+	cmds, err := r.ReadCommands()
+	assert.Nil(t, err)
+	assert.Len(t, cmds, 2)
+	assert.Equal(t, "PING", cmds[0].Cmd)
+	assert.Equal(t, "GET", cmds[1].Cmd)
+	assert.Equal(t, []string{"a"}, cmds[1].Args)
+
+	r.Feed([]byte("\r\n$1\r\nb\r\n"))
+	cmds, err = r.ReadCommands()
+	assert.Nil(t, err)
+	assert.Len(t, cmds, 1)
+	assert.Equal(t, []string{"b"}, cmds[0].Args)
+}
+
+func TestRespReaderLargeBulk(t *testing.T) {
+	value := strings.Repeat("x", 100000)
+	frame := fmt.Sprintf("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$%d\r\n%s\r\n", len(value), value)
+	r := core.NewRespReader()
+	var cmds []*core.Command
+	for i := 0; i < len(frame); i += 512 {
+		end := i + 512
+		if end > len(frame) {
+			end = len(frame)
+		}
+		r.Feed([]byte(frame[i:end]))
+		got, err := r.ReadCommands()
+		assert.Nil(t, err)
+		cmds = append(cmds, got...)
+	}
+	assert.Len(t, cmds, 1)
+	assert.Equal(t, value, cmds[0].Args[1])
+}
+
+func TestResp3Decode(t *testing.T) {
+	value, _ := core.Decode([]byte("_\r\n"))
+	assert.Nil(t, value)
+	value, _ = core.Decode([]byte("#t\r\n"))
+	assert.Equal(t, true, value)
+	value, _ = core.Decode([]byte(",3.14\r\n"))
+	assert.Equal(t, 3.14, value)
+	value, _ = core.Decode([]byte(",-inf\r\n"))
+	assert.True(t, math.IsInf(value.(float64), -1))
+	value, _ = core.Decode([]byte("(3492890328409238509324850943850943825024385\r\n"))
+	assert.Equal(t, "3492890328409238509324850943850943825024385", value.(*big.Int).String())
+	value, _ = core.Decode([]byte("=15\r\ntxt:Some string\r\n"))
+	assert.Equal(t, core.VerbatimString{Format: "txt", Text: "Some string"}, value)
+	value, _ = core.Decode([]byte("%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n"))
+	assert.Equal(t, core.Map{"first", int64(1), "second", int64(2)}, value)
+	value, _ = core.Decode([]byte("~2\r\n+a\r\n+b\r\n"))
+	assert.Equal(t, core.Set{"a", "b"}, value)
+	value, _ = core.Decode([]byte(">2\r\n+message\r\n+hi\r\n"))
+	assert.Equal(t, core.Push{"message", "hi"}, value)
+	value, _ = core.Decode([]byte("|1\r\n+ttl\r\n:3600\r\n+OK\r\n"))
+	assert.Equal(t, "OK", value)
+}
+
+func TestResp3EncodeDowngrade(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		resp2 string
+		resp3 string
+	}{
+		{nil, "$-1\r\n", "_\r\n"},
+		{true, ":1\r\n", "#t\r\n"},
+		{1.5, "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{10.0, "$2\r\n10\r\n", ",10\r\n"},
+		{math.Inf(1), "$3\r\ninf\r\n", ",inf\r\n"},
+		{big.NewInt(42), "$2\r\n42\r\n", "(42\r\n"},
+		{core.VerbatimString{Format: "txt", Text: "hi"}, "$2\r\nhi\r\n", "=6\r\ntxt:hi\r\n"},
+		{core.Map{"a", 1}, "*2\r\n$1\r\na\r\n:1\r\n", "%1\r\n$1\r\na\r\n:1\r\n"},
+		{core.Set{"a"}, "*1\r\n$1\r\na\r\n", "~1\r\n$1\r\na\r\n"},
+		{core.Push{"a"}, "*1\r\n$1\r\na\r\n", ">1\r\n$1\r\na\r\n"},
+		{[]interface{}{2.5}, "*1\r\n$3\r\n2.5\r\n", "*1\r\n,2.5\r\n"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.resp2, string(core.EncodeWithProto(c.value, false, core.RESP2)))
+		assert.Equal(t, c.resp3, string(core.EncodeWithProto(c.value, false, core.RESP3)))
+		decoded, _ := core.Decode(core.EncodeWithProto(c.value, false, core.RESP3))
+		if f, ok := c.value.(float64); ok && math.IsInf(f, 0) {
+			continue
+		}
+		assert.Equal(t, fmt.Sprintf("%v", c.value), fmt.Sprintf("%v", decoded))
+	}
+}
+
+func TestParseInlineCmd(t *testing.T) {
+	cases := map[string][]string{
+		"PING\r\n":                       {"PING"},
+		"set foo bar\n":                  {"SET", "foo", "bar"},
+		"  SET   foo   bar  \r\n":        {"SET", "foo", "bar"},
+		"SET foo \"hello world\"\r\n":    {"SET", "foo", "hello world"},
+		"SET foo \"a\\nb\\x41\\\"\"\r\n": {"SET", "foo", "a\nbA\""},
+		"SET foo 'it\\'s'\r\n":           {"SET", "foo", "it's"},
+		"SET foo 'no \\n escape'\r\n":    {"SET", "foo", "no \\n escape"},
+		"SET foo \"\"\r\n":               {"SET", "foo", ""},
+	}
+	for k, v := range cases {
+		cmd, err := core.ParseCmd([]byte(k))
+		assert.Nil(t, err, k)
+		assert.Equal(t, v[0], cmd.Cmd, k)
+		assert.Equal(t, v[1:], cmd.Args, k)
+	}
+}
+
+func TestParseInlineCmdUnbalancedQuotes(t *testing.T) {
+	cases := []string{
+		"SET foo \"bar\r\n",
+		"SET foo 'bar\r\n",
+		"SET foo \"bar\"baz\r\n",
+	}
+	for _, c := range cases {
+		_, err := core.ParseCmd([]byte(c))
+		assert.True(t, errors.Is(err, core.ErrProtocol), c)
+	}
+}
+
+func TestRespReaderMixedInline(t *testing.T) {
+	r := core.NewRespReader()
+	r.Feed([]byte("PING\r\n*2\r\n$3\r\nGET\r\n$1\r\na\r\n\r\nGET b"))
+	cmds, err := r.ReadCommands()
+	assert.Nil(t, err)
+	assert.Len(t, cmds, 2)
+	assert.Equal(t, "PING", cmds[0].Cmd)
+	assert.Equal(t, "GET", cmds[1].Cmd)
+
+	r.Feed([]byte("\n"))
+	cmds, err = r.ReadCommands()
+	assert.Nil(t, err)
+	assert.Len(t, cmds, 1)
+	assert.Equal(t, []string{"b"}, cmds[0].Args)
+}
@@ -0,0 +1,240 @@
+package core_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func TestSetRandMember(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "set", "a")
+
+	assert.Equal(t, "*3\r\n$1\r\na\r\n$1\r\na\r\n$1\r\na\r\n", run(s, c, "SRANDMEMBER", "set", "-3"))
+	assert.Equal(t, "-ERR value is out of range\r\n", run(s, c, "SRANDMEMBER", "set", "-9223372036854775808"))
+	assert.Equal(t, "-ERR value is out of range\r\n", run(s, c, "SRANDMEMBER", "set", "-2147483648"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(s, c, "SRANDMEMBER", "set", "x"))
+}
+
+func TestSetScan(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	// a compact set is returned whole
+	run(s, c, "SADD", "small", "1", "2", "3")
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n", run(s, c, "SSCAN", "small", "0", "COUNT", "1"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*1\r\n$1\r\n2\r\n", run(s, c, "SSCAN", "small", "0", "MATCH", "2"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*0\r\n", run(s, c, "SSCAN", "missing", "0"))
+	assert.Equal(t, "-ERR invalid cursor\r\n", run(s, c, "SSCAN", "small", "x"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "SSCAN", "small", "0", "COUNT", "0"))
+
+	// a hashtable is returned a page at a time
+	args := []string{"SADD", "big"}
+	for i := 0; i < 1000; i++ {
+		args = append(args, "m"+strconv.Itoa(i))
+	}
+	run(s, c, args...)
+	seen := map[string]bool{}
+	pages := 0
+	for cursor := "0"; ; pages++ {
+		reply, err := core.Decode([]byte(run(s, c, "SSCAN", "big", cursor, "COUNT", "20", "MATCH", "m1*")))
+		assert.NoError(t, err)
+		page := reply.([]interface{})
+		for _, member := range page[1].([]interface{}) {
+			assert.True(t, strings.HasPrefix(member.(string), "m1"))
+			seen[member.(string)] = true
+		}
+		if cursor = page[0].(string); cursor == "0" {
+			break
+		}
+	}
+	assert.Greater(t, pages, 10)
+	// m1, m10-m19 and m100-m199
+	assert.Len(t, seen, 111)
+}
+
+func TestSetOps(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "a", "1", "2", "3")
+	run(s, c, "SADD", "b", "2", "3", "4")
+	run(s, c, "SADD", "c", "3", "x")
+
+	assert.Equal(t, "*5\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n$1\r\n4\r\n$1\r\nx\r\n", run(s, c, "SUNION", "a", "b", "c"))
+	assert.Equal(t, "*1\r\n$1\r\n3\r\n", run(s, c, "SINTER", "a", "b", "c"))
+	assert.Equal(t, "*1\r\n$1\r\n1\r\n", run(s, c, "SDIFF", "a", "b", "c"))
+	assert.Equal(t, "*0\r\n", run(s, c, "SINTER", "a", "missing"))
+	assert.Equal(t, "*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n", run(s, c, "SDIFF", "a", "missing"))
+	c3 := core.NewClient()
+	run(s, c3, "HELLO", "3")
+	assert.Equal(t, "~1\r\n$1\r\n3\r\n", run(s, c3, "SINTER", "a", "b", "c"))
+
+	// a destination that is also a source is read before being replaced
+	assert.Equal(t, ":2\r\n", run(s, c, "SINTERSTORE", "a", "a", "b"))
+	assert.Equal(t, "*2\r\n$1\r\n2\r\n$1\r\n3\r\n", run(s, c, "SMEMBERS", "a"))
+	assert.Equal(t, ":4\r\n", run(s, c, "SUNIONSTORE", "u", "a", "b", "c"))
+	assert.Equal(t, ":1\r\n", run(s, c, "SDIFFSTORE", "d", "b", "a"))
+	assert.Equal(t, "*1\r\n$1\r\n4\r\n", run(s, c, "SMEMBERS", "d"))
+	// whatever its type, the destination is replaced, and deleted by an
+	// empty result
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, ":1\r\n", run(s, c, "SDIFFSTORE", "str", "b", "a"))
+	assert.Equal(t, "+set\r\n", run(s, c, "TYPE", "str"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SINTERSTORE", "d", "a", "missing"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "d"))
+
+	run(s, c, "SET", "str2", "v")
+	assert.Equal(t, wrongType, run(s, c, "SINTER", "a", "str2"))
+	assert.Equal(t, wrongType, run(s, c, "SUNIONSTORE", "dest", "a", "str2"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "dest"))
+}
+
+func TestSetInterCard(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "a", "1", "2", "3", "4")
+	run(s, c, "SADD", "b", "2", "3", "4", "5")
+
+	assert.Equal(t, ":3\r\n", run(s, c, "SINTERCARD", "2", "a", "b"))
+	assert.Equal(t, ":2\r\n", run(s, c, "SINTERCARD", "2", "a", "b", "LIMIT", "2"))
+	assert.Equal(t, ":3\r\n", run(s, c, "SINTERCARD", "2", "a", "b", "LIMIT", "0"))
+	assert.Equal(t, ":3\r\n", run(s, c, "SINTERCARD", "2", "a", "b", "LIMIT", "10"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SINTERCARD", "2", "a", "missing"))
+	assert.Equal(t, "-ERR LIMIT can't be negative\r\n", run(s, c, "SINTERCARD", "2", "a", "b", "LIMIT", "-1"))
+	assert.Equal(t, "-ERR numkeys should be greater than 0\r\n", run(s, c, "SINTERCARD", "0", "a"))
+	assert.Equal(t, "-ERR Number of keys can't be greater than number of args\r\n", run(s, c, "SINTERCARD", "3", "a", "b"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "SINTERCARD", "1", "a", "b"))
+}
+
+func TestSetMove(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "src", "a", "b")
+
+	assert.Equal(t, ":1\r\n", run(s, c, "SMOVE", "src", "dest", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SMOVE", "src", "dest", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SMOVE", "missing", "dest", "a"))
+	assert.Equal(t, ":1\r\n", run(s, c, "SMOVE", "src", "src", "b"))
+	assert.Equal(t, "*1\r\n$1\r\nb\r\n", run(s, c, "SMEMBERS", "src"))
+	// the source is deleted with its last member
+	assert.Equal(t, ":1\r\n", run(s, c, "SMOVE", "src", "dest", "b"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "src"))
+	assert.Equal(t, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", run(s, c, "SMEMBERS", "dest"))
+
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, wrongType, run(s, c, "SMOVE", "dest", "str", "a"))
+	assert.Equal(t, wrongType, run(s, c, "SMOVE", "str", "dest", "a"))
+	assert.Equal(t, ":2\r\n", run(s, c, "SCARD", "dest"))
+}
+
+func TestSetPop(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "set", "a", "b", "c")
+
+	reply, err := core.Decode([]byte(run(s, c, "SPOP", "set", "2")))
+	assert.NoError(t, err)
+	popped := reply.([]interface{})
+	assert.Len(t, popped, 2)
+	assert.NotEqual(t, popped[0], popped[1])
+	for _, member := range popped {
+		assert.Equal(t, ":0\r\n", run(s, c, "SISMEMBER", "set", member.(string)))
+	}
+	assert.Equal(t, ":1\r\n", run(s, c, "SCARD", "set"))
+	assert.Equal(t, "*0\r\n", run(s, c, "SPOP", "set", "0"))
+	// a count above the size pops everything and deletes the key
+	assert.Equal(t, "*1\r\n", run(s, c, "SPOP", "set", "5")[:4])
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "set"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "SPOP", "set"))
+	assert.Equal(t, "*0\r\n", run(s, c, "SPOP", "set", "1"))
+	assert.Equal(t, "-ERR value is out of range, must be positive\r\n", run(s, c, "SPOP", "set", "-1"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "SPOP", "set", "1", "2"))
+
+	run(s, c, "SADD", "one", "x")
+	assert.Equal(t, "$1\r\nx\r\n", run(s, c, "SPOP", "one"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "one"))
+}
+
+func TestSetRandMemberCount(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "set", "a", "b", "c")
+
+	for _, count := range []string{"2", "3", "10"} {
+		reply, err := core.Decode([]byte(run(s, c, "SRANDMEMBER", "set", count)))
+		assert.NoError(t, err)
+		members := reply.([]interface{})
+		n, _ := strconv.Atoi(count)
+		assert.Len(t, members, min(n, 3))
+		seen := map[interface{}]bool{}
+		for _, member := range members {
+			assert.Contains(t, []interface{}{"a", "b", "c"}, member)
+			assert.False(t, seen[member])
+			seen[member] = true
+		}
+	}
+	// a negative count may repeat members
+	reply, err := core.Decode([]byte(run(s, c, "SRANDMEMBER", "set", "-10")))
+	assert.NoError(t, err)
+	assert.Len(t, reply, 10)
+	for _, member := range reply.([]interface{}) {
+		assert.Contains(t, []interface{}{"a", "b", "c"}, member)
+	}
+	assert.Equal(t, "*0\r\n", run(s, c, "SRANDMEMBER", "set", "0"))
+	assert.Equal(t, "*0\r\n", run(s, c, "SRANDMEMBER", "missing", "-5"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "SRANDMEMBER", "missing"))
+	assert.Equal(t, ":3\r\n", run(s, c, "SCARD", "set"))
+
+	// a count far below the size picks members until enough distinct ones
+	for i := 0; i < 1000; i++ {
+		run(s, c, "SADD", "large", "m"+strconv.Itoa(i))
+	}
+	reply, err = core.Decode([]byte(run(s, c, "SRANDMEMBER", "large", "10")))
+	assert.NoError(t, err)
+	seen := map[interface{}]bool{}
+	for _, member := range reply.([]interface{}) {
+		assert.Equal(t, ":1\r\n", run(s, c, "SISMEMBER", "large", member.(string)))
+		seen[member] = true
+	}
+	assert.Len(t, seen, 10)
+}
+
+func TestSetMisMember(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "set", "a", "1")
+
+	assert.Equal(t, "*3\r\n:1\r\n:0\r\n:1\r\n", run(s, c, "SMISMEMBER", "set", "a", "b", "1"))
+	assert.Equal(t, "*2\r\n:0\r\n:0\r\n", run(s, c, "SMISMEMBER", "missing", "a", "b"))
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, wrongType, run(s, c, "SMISMEMBER", "str", "a"))
+}
+
+func TestSetScanMatch(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "SADD", "set", "hello", "hallo", "hxllo", "hllo", "heeeello", "h*llo", "h[llo", "ab")
+
+	match := func(pattern string) []interface{} {
+		reply, err := core.Decode([]byte(run(s, c, "SSCAN", "set", "0", "MATCH", pattern)))
+		assert.NoError(t, err)
+		return reply.([]interface{})[1].([]interface{})
+	}
+	assert.Equal(t, []interface{}{"hello", "hallo", "hxllo", "h*llo", "h[llo"}, match("h?llo"))
+	assert.Equal(t, []interface{}{"hello", "hallo", "hxllo", "hllo", "heeeello", "h*llo", "h[llo"}, match("h*llo"))
+	assert.Equal(t, []interface{}{"hello", "hallo"}, match("h[ae]llo"))
+	assert.Equal(t, []interface{}{"hxllo", "h*llo", "h[llo"}, match("h[^ae]llo"))
+	assert.Equal(t, []interface{}{"hello", "hallo"}, match("h[a-e]llo"))
+	assert.Equal(t, []interface{}{"hello", "hallo"}, match("h[e-a]llo"))
+	assert.Equal(t, []interface{}{"h*llo"}, match(`h\*llo`))
+	assert.Equal(t, []interface{}{"h*llo", "h[llo"}, match(`h[\*\[]llo`))
+	assert.Equal(t, []interface{}{"ab"}, match("**b"))
+	assert.Equal(t, []interface{}{}, match("h"))
+	assert.Equal(t, []interface{}{}, match("abc?"))
+	// an unterminated class ends the pattern
+	assert.Equal(t, []interface{}{"ab"}, match("a[b"))
+}
@@ -0,0 +1,159 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func TestSortedSetRandMember(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "ZADD", "zset", "1", "a")
+
+	assert.Equal(t, "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\na\r\n$1\r\n1\r\n", run(s, c, "ZRANDMEMBER", "zset", "-2", "WITHSCORES"))
+	assert.Equal(t, "*1\r\n$1\r\na\r\n", run(s, c, "ZRANDMEMBER", "zset", "2147483647"))
+	// a negative count is bounded by the number of picks, not by the set
+	assert.Equal(t, "-ERR value is out of range\r\n", run(s, c, "ZRANDMEMBER", "zset", "-1048577"))
+	assert.Equal(t, "-ERR value is out of range\r\n", run(s, c, "ZRANDMEMBER", "zset", "2147483648"))
+}
+
+func TestSortedSetAdd(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":2\r\n", run(s, c, "ZADD", "zset", "1", "a", "2", "b"))
+	assert.Equal(t, ":0\r\n", run(s, c, "ZADD", "zset", "3", "a"))
+	assert.Equal(t, "$1\r\n3\r\n", run(s, c, "ZSCORE", "zset", "a"))
+	// CH counts the updated members too
+	assert.Equal(t, ":2\r\n", run(s, c, "ZADD", "zset", "CH", "4", "a", "2", "b", "1", "c"))
+
+	// NX only adds, XX only updates
+	assert.Equal(t, ":1\r\n", run(s, c, "ZADD", "zset", "NX", "10", "a", "1", "d"))
+	assert.Equal(t, "$1\r\n4\r\n", run(s, c, "ZSCORE", "zset", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "ZADD", "zset", "XX", "5", "a", "1", "e"))
+	assert.Equal(t, "$1\r\n5\r\n", run(s, c, "ZSCORE", "zset", "a"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "ZSCORE", "zset", "e"))
+
+	// GT and LT only update in their direction, but still add
+	assert.Equal(t, ":2\r\n", run(s, c, "ZADD", "zset", "GT", "CH", "4", "a", "3", "b", "1", "e"))
+	assert.Equal(t, "$1\r\n5\r\n", run(s, c, "ZSCORE", "zset", "a"))
+	assert.Equal(t, "$1\r\n3\r\n", run(s, c, "ZSCORE", "zset", "b"))
+	assert.Equal(t, ":0\r\n", run(s, c, "ZADD", "zset", "LT", "6", "a", "1", "b"))
+	assert.Equal(t, "$1\r\n5\r\n", run(s, c, "ZSCORE", "zset", "a"))
+	assert.Equal(t, "$1\r\n1\r\n", run(s, c, "ZSCORE", "zset", "b"))
+	assert.Equal(t, ":1\r\n", run(s, c, "ZADD", "zset", "XX", "GT", "CH", "6", "a", "7", "missing"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "ZSCORE", "zset", "missing"))
+
+	// INCR replies the new score, nil when the update is aborted
+	assert.Equal(t, "$1\r\n8\r\n", run(s, c, "ZADD", "zset", "INCR", "2", "a"))
+	assert.Equal(t, "$3\r\n1.5\r\n", run(s, c, "ZADD", "zset", "incr", "1.5", "new"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "ZADD", "zset", "NX", "INCR", "1", "a"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "ZADD", "zset", "XX", "INCR", "1", "absent"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "ZADD", "zset", "GT", "INCR", "-1", "a"))
+	assert.Equal(t, "$1\r\n9\r\n", run(s, c, "ZADD", "zset", "GT", "INCR", "1", "a"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "ZADD", "zset", "NX", "INCR", "1", "b"))
+	assert.Equal(t, "$1\r\n1\r\n", run(s, c, "ZSCORE", "zset", "b"))
+	c3 := core.NewClient()
+	run(s, c3, "HELLO", "3")
+	assert.Equal(t, "_\r\n", run(s, c3, "ZADD", "zset", "NX", "INCR", "1", "a"))
+	assert.Equal(t, ",10\r\n", run(s, c3, "ZADD", "zset", "INCR", "1", "a"))
+	run(s, c, "ZADD", "inf", "inf", "a")
+	assert.Equal(t, "-ERR resulting score is not a number (NaN)\r\n", run(s, c, "ZADD", "inf", "INCR", "-inf", "a"))
+
+	assert.Equal(t, "-ERR XX and NX options at the same time are not compatible\r\n", run(s, c, "ZADD", "zset", "NX", "XX", "1", "a"))
+	assert.Equal(t, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n", run(s, c, "ZADD", "zset", "GT", "LT", "1", "a"))
+	assert.Equal(t, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n", run(s, c, "ZADD", "zset", "NX", "GT", "1", "a"))
+	assert.Equal(t, "-ERR INCR option supports a single increment-element pair\r\n", run(s, c, "ZADD", "zset", "INCR", "1", "a", "2", "b"))
+	assert.Equal(t, "-ERR value is not a valid float\r\n", run(s, c, "ZADD", "zset", "x", "a"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZADD", "zset", "1", "a", "2"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZADD", "zset", "NX", "1"))
+	// a rejected command doesn't leave an empty sorted set behind
+	assert.Equal(t, ":0\r\n", run(s, c, "ZADD", "new", "XX", "1", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "new"))
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, wrongType, run(s, c, "ZADD", "str", "1", "a"))
+}
+
+func TestSortedSetOps(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "ZADD", "a", "1", "x", "2", "y")
+	run(s, c, "ZADD", "b", "3", "y", "4", "z")
+	// the members of a set are scored 1
+	run(s, c, "SADD", "set", "y", "z")
+
+	assert.Equal(t, "*6\r\n$1\r\nx\r\n$1\r\n1\r\n$1\r\nz\r\n$1\r\n4\r\n$1\r\ny\r\n$1\r\n5\r\n", run(s, c, "ZUNION", "2", "a", "b", "WITHSCORES"))
+	assert.Equal(t, "*2\r\n$1\r\ny\r\n$1\r\n5\r\n", run(s, c, "ZINTER", "2", "a", "b", "WITHSCORES"))
+	assert.Equal(t, "*2\r\n$1\r\nx\r\n$1\r\n1\r\n", run(s, c, "ZDIFF", "2", "a", "b", "WITHSCORES"))
+	assert.Equal(t, "*2\r\n$1\r\ny\r\n$1\r\nz\r\n", run(s, c, "ZINTER", "2", "b", "set"))
+	assert.Equal(t, "*4\r\n$1\r\ny\r\n$1\r\n5\r\n$1\r\nz\r\n$1\r\n6\r\n", run(s, c, "ZINTER", "2", "b", "set", "WEIGHTS", "1", "2", "WITHSCORES"))
+
+	// WEIGHTS multiply the scores before they are aggregated
+	assert.Equal(t, "*6\r\n$1\r\nx\r\n$1\r\n2\r\n$1\r\ny\r\n$1\r\n4\r\n$1\r\nz\r\n$2\r\n40\r\n", run(s, c, "ZUNION", "2", "a", "b", "WEIGHTS", "2", "10", "AGGREGATE", "MIN", "WITHSCORES"))
+	assert.Equal(t, "*2\r\n$1\r\ny\r\n$2\r\n30\r\n", run(s, c, "ZINTER", "2", "a", "b", "WEIGHTS", "2", "10", "AGGREGATE", "MAX", "WITHSCORES"))
+	assert.Equal(t, "*2\r\n$1\r\ny\r\n$1\r\n2\r\n", run(s, c, "ZINTER", "2", "a", "b", "AGGREGATE", "min", "WITHSCORES"))
+	assert.Equal(t, "*2\r\n$1\r\ny\r\n$1\r\n1\r\n", run(s, c, "ZINTER", "2", "a", "b", "WEIGHTS", "2", "-1", "WITHSCORES"))
+
+	// inf * 0 and inf - inf are 0 rather than nan
+	run(s, c, "ZADD", "inf", "inf", "y", "-inf", "z")
+	assert.Equal(t, "*4\r\n$1\r\ny\r\n$1\r\n0\r\n$1\r\nz\r\n$1\r\n0\r\n", run(s, c, "ZUNION", "1", "inf", "WEIGHTS", "0", "WITHSCORES"))
+	assert.Equal(t, "*4\r\n$1\r\ny\r\n$1\r\n0\r\n$1\r\nz\r\n$1\r\n0\r\n", run(s, c, "ZINTER", "2", "inf", "inf", "WEIGHTS", "1", "-1", "WITHSCORES"))
+	assert.Equal(t, "*4\r\n$1\r\nz\r\n$4\r\n-inf\r\n$1\r\ny\r\n$1\r\n1\r\n", run(s, c, "ZINTER", "2", "inf", "set", "AGGREGATE", "MIN", "WITHSCORES"))
+	assert.Equal(t, "*4\r\n$1\r\nz\r\n$1\r\n1\r\n$1\r\ny\r\n$3\r\ninf\r\n", run(s, c, "ZUNION", "2", "inf", "set", "AGGREGATE", "MAX", "WITHSCORES"))
+
+	assert.Equal(t, "-ERR weight value is not a float\r\n", run(s, c, "ZUNION", "2", "a", "b", "WEIGHTS", "1", "x"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZUNION", "2", "a", "b", "WEIGHTS", "1"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZUNION", "2", "a", "b", "AGGREGATE", "AVG"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZDIFF", "2", "a", "b", "WEIGHTS", "1", "1"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZUNIONSTORE", "dest", "2", "a", "b", "WITHSCORES"))
+	assert.Equal(t, "-ERR at least 1 input key is needed for 'zunion' command\r\n", run(s, c, "ZUNION", "0", "a"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZINTER", "3", "a", "b"))
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, wrongType, run(s, c, "ZUNION", "2", "a", "str"))
+}
+
+func TestSortedSetOpsStore(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "ZADD", "a", "1", "x", "2", "y")
+	run(s, c, "ZADD", "b", "3", "y", "4", "z")
+
+	assert.Equal(t, ":3\r\n", run(s, c, "ZUNIONSTORE", "dest", "2", "a", "b", "WEIGHTS", "1", "2"))
+	assert.Equal(t, "*6\r\n$1\r\nx\r\n$1\r\n1\r\n$1\r\ny\r\n$1\r\n8\r\n$1\r\nz\r\n$1\r\n8\r\n", run(s, c, "ZRANGE", "dest", "0", "-1", "WITHSCORES"))
+	// a destination that is also a source is read before being replaced
+	assert.Equal(t, ":1\r\n", run(s, c, "ZINTERSTORE", "a", "2", "a", "b"))
+	assert.Equal(t, "*2\r\n$1\r\ny\r\n$1\r\n5\r\n", run(s, c, "ZRANGE", "a", "0", "-1", "WITHSCORES"))
+	assert.Equal(t, ":1\r\n", run(s, c, "ZDIFFSTORE", "b", "2", "b", "a"))
+	assert.Equal(t, "*2\r\n$1\r\nz\r\n$1\r\n4\r\n", run(s, c, "ZRANGE", "b", "0", "-1", "WITHSCORES"))
+	// whatever its type, the destination is replaced, and deleted by an
+	// empty result
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, ":1\r\n", run(s, c, "ZUNIONSTORE", "str", "1", "a"))
+	assert.Equal(t, "+zset\r\n", run(s, c, "TYPE", "str"))
+	assert.Equal(t, ":0\r\n", run(s, c, "ZINTERSTORE", "dest", "2", "a", "b"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "dest"))
+	assert.Equal(t, ":0\r\n", run(s, c, "ZDIFFSTORE", "str", "1", "missing"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "str"))
+}
+
+func TestSortedSetInterCard(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "ZADD", "a", "1", "w", "2", "x", "3", "y", "4", "z")
+	run(s, c, "ZADD", "b", "1", "x", "2", "y", "3", "z")
+	run(s, c, "SADD", "set", "y", "z")
+
+	assert.Equal(t, ":3\r\n", run(s, c, "ZINTERCARD", "2", "a", "b"))
+	assert.Equal(t, ":2\r\n", run(s, c, "ZINTERCARD", "3", "a", "b", "set"))
+	assert.Equal(t, ":2\r\n", run(s, c, "ZINTERCARD", "2", "a", "b", "LIMIT", "2"))
+	assert.Equal(t, ":3\r\n", run(s, c, "ZINTERCARD", "2", "a", "b", "LIMIT", "0"))
+	assert.Equal(t, ":3\r\n", run(s, c, "ZINTERCARD", "2", "a", "b", "LIMIT", "100"))
+	assert.Equal(t, ":0\r\n", run(s, c, "ZINTERCARD", "2", "a", "missing"))
+	assert.Equal(t, "-ERR LIMIT can't be negative\r\n", run(s, c, "ZINTERCARD", "2", "a", "b", "LIMIT", "-1"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "ZINTERCARD", "2", "a", "b", "LIMIT"))
+	assert.Equal(t, "-ERR numkeys should be greater than 0\r\n", run(s, c, "ZINTERCARD", "0", "a"))
+	run(s, c, "SET", "str", "v")
+	assert.Equal(t, wrongType, run(s, c, "ZINTERCARD", "2", "a", "str"))
+}
@@ -1,19 +1,247 @@
 package core
 
-import "goredis-lite/internal/data_structure"
+import (
+	"errors"
+	"strconv"
+	"sync"
 
-var (
+	"goredis-lite/internal/data_structure"
+)
+
+// Storage holds the keyspace a command can touch. The single-threaded
+// server uses defaultStorage, in share-nothing mode each Worker owns one.
+type Storage struct {
+	// dictStore maps every key, whatever its type, to an Obj tagged with
+	// the type of its value
 	dictStore *data_structure.Dict
-	zsetStore map[string]*data_structure.SortedSet
-	setStore  map[string]*data_structure.SimpleSet
-	cmsStore  map[string]*data_structure.CMS
-	bloomStore map[string]*data_structure.Bloom
+
+	// number of active expiration cycles stopped by MaxActiveExpireExecutionTime
+	expireTimeCapReached int64
+
+	// dirty counts the changes made to the keyspace, like server.dirty in
+	// Redis: a write command that leaves it untouched is neither logged in
+	// the AOF nor counted by the save rules
+	dirty int64
+
+	// blockedPops queues by key the blocking commands waiting for the key
+	// to be pushed to, readyKeys the keys pushed to by the running command,
+	// see serveBlockedPops. numBlockedPops counts the queued entries,
+	// staleBlockedPops those already served or cancelled.
+	blockedPops      map[string][]*blockedPop
+	readyKeys        []string
+	numBlockedPops   int64
+	staleBlockedPops int64
+
+	aof *aofSegment // nil unless appendonly is enabled
+	// rewritten replaces the command being executed in the AOF, see
+	// rewriteCommand
+	rewritten *Command
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		dictStore: data_structure.CreateDict(),
+	}
+}
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// lookup returns the value at key, nil when the key is missing, or
+// errWrongType when the key holds another type than typ
+func (s *Storage) lookup(key string, typ data_structure.ObjType) (interface{}, error) {
+	obj := s.dictStore.Get(key)
+	if obj == nil {
+		return nil, nil
+	}
+	if obj.Type != typ {
+		return nil, errWrongType
+	}
+	return obj.Value, nil
+}
+
+// getString returns the string at key, exist is false when the key is missing
+func (s *Storage) getString(key string) (value string, exist bool, err error) {
+	obj, value, err := s.getStringObj(key)
+	return value, obj != nil, err
+}
+
+// getStringObj returns the Obj holding the string at key, nil when the key
+// is missing, and the string
+func (s *Storage) getStringObj(key string) (*data_structure.Obj, string, error) {
+	obj := s.dictStore.Get(key)
+	switch {
+	case obj == nil:
+		return nil, "", nil
+	case obj.Type != data_structure.ObjString:
+		return nil, "", errWrongType
+	}
+	switch v := obj.Value.(type) {
+	case int64:
+		return obj, strconv.FormatInt(v, 10), nil
+	case []byte:
+		return obj, string(v), nil
+	case *data_structure.HyperLogLog:
+		data, _ := v.MarshalBinary()
+		return obj, string(data), nil
+	}
+	return obj, obj.Value.(string), nil
+}
+
+// getBitmap returns the Obj holding the string at key, nil when the key is
+// missing, and its bytes. A string written by SETBIT or BITFIELD is held as
+// a []byte, which they modify in place instead of copying the whole string
+// on every write, and which is returned as is: the commands reading it must
+// not modify it.
+func (s *Storage) getBitmap(key string) (*data_structure.Obj, []byte, error) {
+	obj := s.dictStore.Get(key)
+	if obj != nil && obj.Type == data_structure.ObjString {
+		if buf, ok := obj.Value.([]byte); ok {
+			return obj, buf, nil
+		}
+	}
+	obj, value, err := s.getStringObj(key)
+	return obj, []byte(value), err
+}
+
+// getList returns the list at key, nil when the key is missing
+func (s *Storage) getList(key string) (*data_structure.List, error) {
+	v, err := s.lookup(key, data_structure.ObjList)
+	list, _ := v.(*data_structure.List)
+	return list, err
+}
+
+// getSet returns the set at key, nil when the key is missing
+func (s *Storage) getSet(key string) (*data_structure.SimpleSet, error) {
+	v, err := s.lookup(key, data_structure.ObjSet)
+	set, _ := v.(*data_structure.SimpleSet)
+	return set, err
+}
+
+// getZSet returns the sorted set at key, nil when the key is missing
+func (s *Storage) getZSet(key string) (*data_structure.SortedSet, error) {
+	v, err := s.lookup(key, data_structure.ObjZSet)
+	zset, _ := v.(*data_structure.SortedSet)
+	return zset, err
+}
+
+// getHash returns the hash at key, nil when the key is missing
+func (s *Storage) getHash(key string) (*data_structure.Hash, error) {
+	v, err := s.lookup(key, data_structure.ObjHash)
+	hash, _ := v.(*data_structure.Hash)
+	return hash, err
+}
+
+// getCMS returns the count-min sketch at key, nil when the key is missing
+func (s *Storage) getCMS(key string) (*data_structure.CMS, error) {
+	v, err := s.lookup(key, data_structure.ObjCMS)
+	cms, _ := v.(*data_structure.CMS)
+	return cms, err
+}
+
+// getBloom returns the bloom filter at key, nil when the key is missing
+func (s *Storage) getBloom(key string) (*data_structure.ScalableBloom, error) {
+	v, err := s.lookup(key, data_structure.ObjBloom)
+	bloom, _ := v.(*data_structure.ScalableBloom)
+	return bloom, err
+}
+
+// getCuckoo returns the cuckoo filter at key, nil when the key is missing
+func (s *Storage) getCuckoo(key string) (*data_structure.CuckooFilter, error) {
+	v, err := s.lookup(key, data_structure.ObjCuckoo)
+	cuckoo, _ := v.(*data_structure.CuckooFilter)
+	return cuckoo, err
+}
+
+var (
+	errNotHLL       = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	errCorruptedHLL = errors.New("INVALIDOBJ Corrupted HLL object detected")
 )
 
-func init() {
-	dictStore = data_structure.CreateDict()
-	zsetStore = make(map[string]*data_structure.SortedSet)
-	setStore = make(map[string]*data_structure.SimpleSet)
-	cmsStore = make(map[string]*data_structure.CMS)
-	bloomStore = make(map[string]*data_structure.Bloom)
+// getHLL returns the HyperLogLog at key, nil when the key is missing. Like
+// in Redis it is a string: one holding an encoded HyperLogLog, set from the
+// reply of GET for instance, is decoded in place.
+func (s *Storage) getHLL(key string) (*data_structure.HyperLogLog, error) {
+	obj := s.dictStore.Get(key)
+	switch {
+	case obj == nil:
+		return nil, nil
+	case obj.Type != data_structure.ObjString:
+		return nil, errWrongType
+	}
+	var data []byte
+	switch v := obj.Value.(type) {
+	case *data_structure.HyperLogLog:
+		return v, nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, errNotHLL
+	}
+	hll := &data_structure.HyperLogLog{}
+	if err := hll.UnmarshalBinary(data); err != nil {
+		if errors.Is(err, data_structure.ErrNotHLL) {
+			return nil, errNotHLL
+		}
+		return nil, errCorruptedHLL
+	}
+	obj.Value = hll
+	return hll, nil
+}
+
+// add stores value, of type typ, at key. The key must be missing: to
+// replace one, delete it first so that its TTL goes away too.
+func (s *Storage) add(key string, typ data_structure.ObjType, value interface{}) {
+	s.dictStore.Set(key, s.dictStore.NewObj(key, typ, value, -1))
+}
+
+var defaultStorage = NewStorage()
+
+// Partitions gives persistence access to the storages of the server. The
+// single-threaded server has one partition, defaultStorage; in share-nothing
+// mode every worker owns one.
+type Partitions interface {
+	// Count returns the number of partitions
+	Count() int
+	// PartitionOf returns the partition owning key
+	PartitionOf(key string) int
+	// Run calls fn on the storage of partition id, on the goroutine owning
+	// it, and returns once fn returned
+	Run(id int, fn func(s *Storage))
+	// Execute runs cmd as if sent by c, on the partitions owning its keys
+	Execute(cmd *Command, c *Client) []byte
+}
+
+type defaultPartition struct{}
+
+func (defaultPartition) Count() int                      { return 1 }
+func (defaultPartition) PartitionOf(key string) int      { return 0 }
+func (defaultPartition) Run(id int, fn func(s *Storage)) { fn(defaultStorage) }
+func (defaultPartition) Execute(cmd *Command, c *Client) []byte {
+	return ExecuteCommand(defaultStorage, cmd, c)
+}
+
+var partitions Partitions = defaultPartition{}
+
+// UsePartitions makes persistence reach the storages of p instead of
+// defaultStorage. It must be called before the server starts.
+func UsePartitions(p Partitions) {
+	partitions = p
+}
+
+// runAll calls fn on every partition, all of them at the same time, and
+// returns once every call returned
+func runAll(fn func(id int, s *Storage)) {
+	var wg sync.WaitGroup
+	for id := 0; id < partitions.Count(); id++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			partitions.Run(id, func(s *Storage) {
+				fn(id, s)
+			})
+		}()
+	}
+	wg.Wait()
 }
@@ -0,0 +1,165 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+func TestSetOptions(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	// a lock is taken once, then released by its owner
+	assert.Equal(t, "+OK\r\n", run(s, c, "SET", "lock", "a", "NX", "PX", "30000"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "SET", "lock", "b", "NX", "PX", "30000"))
+	assert.Contains(t, []string{":29\r\n", ":30\r\n"}, run(s, c, "TTL", "lock"))
+	assert.Equal(t, "$1\r\na\r\n", run(s, c, "GETDEL", "lock"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "SET", "lock", "b", "XX"))
+
+	run(s, c, "SET", "k", "v", "EX", "100")
+	assert.Equal(t, "+OK\r\n", run(s, c, "SET", "k", "w", "KEEPTTL"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(s, c, "TTL", "k"))
+	assert.Equal(t, "$1\r\nw\r\n", run(s, c, "SET", "k", "x", "GET"))
+	assert.Equal(t, ":-1\r\n", run(s, c, "TTL", "k"))
+	assert.Equal(t, "+OK\r\n", run(s, c, "SET", "k", "y", "PXAT", "1"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "GET", "k"))
+
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "SET", "k", "v", "NX", "XX"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "SET", "k", "v", "EX", "1", "KEEPTTL"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "SET", "k", "v", "EX"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", run(s, c, "SET", "k", "v", "PX", "0"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", run(s, c, "SET", "k", "v", "EX", "9223372036854775807"))
+}
+
+func TestStringGetters(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":1\r\n", run(s, c, "SETNX", "k", "a"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SETNX", "k", "b"))
+	assert.Equal(t, "+OK\r\n", run(s, c, "SETEX", "k", "100", "c"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(s, c, "TTL", "k"))
+	assert.Equal(t, "$1\r\nc\r\n", run(s, c, "GETSET", "k", "d"))
+	assert.Equal(t, ":-1\r\n", run(s, c, "TTL", "k"))
+	assert.Equal(t, "+OK\r\n", run(s, c, "PSETEX", "k", "100000", "e"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(s, c, "TTL", "k"))
+	assert.Equal(t, "-ERR invalid expire time in 'psetex' command\r\n", run(s, c, "PSETEX", "k", "-5", "e"))
+
+	assert.Equal(t, "$1\r\ne\r\n", run(s, c, "GETEX", "k", "PERSIST"))
+	assert.Equal(t, ":-1\r\n", run(s, c, "TTL", "k"))
+	assert.Equal(t, "$1\r\ne\r\n", run(s, c, "GETEX", "k", "EX", "50"))
+	assert.Contains(t, []string{":49\r\n", ":50\r\n"}, run(s, c, "TTL", "k"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(s, c, "GETEX", "k", "KEEPTTL"))
+	assert.Equal(t, "$-1\r\n", run(s, c, "GETEX", "missing", "EX", "50"))
+
+	run(s, c, "RPUSH", "list", "a")
+	assert.Equal(t, wrongType, run(s, c, "GETSET", "list", "v"))
+	assert.Equal(t, wrongType, run(s, c, "GETDEL", "list"))
+	assert.Equal(t, "+OK\r\n", run(s, c, "SETEX", "list", "10", "v"))
+}
+
+func TestCounters(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":1\r\n", run(s, c, "INCR", "n"))
+	assert.Equal(t, ":11\r\n", run(s, c, "INCRBY", "n", "10"))
+	assert.Equal(t, ":6\r\n", run(s, c, "DECRBY", "n", "5"))
+	assert.Equal(t, ":5\r\n", run(s, c, "DECR", "n"))
+	assert.Equal(t, "$3\r\nint\r\n", run(s, c, "OBJECT", "ENCODING", "n"))
+	assert.Equal(t, "$1\r\n5\r\n", run(s, c, "GET", "n"))
+
+	// an increment keeps the TTL
+	run(s, c, "SET", "n", "9223372036854775806", "EX", "100")
+	assert.Equal(t, ":9223372036854775807\r\n", run(s, c, "INCR", "n"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(s, c, "TTL", "n"))
+	assert.Equal(t, "-ERR increment or decrement would overflow\r\n", run(s, c, "INCR", "n"))
+	assert.Equal(t, "-ERR decrement would overflow\r\n", run(s, c, "DECRBY", "n", "-9223372036854775808"))
+
+	for _, v := range []string{"abc", "012", "+1", " 1", "1.5"} {
+		run(s, c, "SET", "s", v)
+		assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(s, c, "INCR", "s"), v)
+	}
+
+	run(s, c, "SET", "f", "10.5")
+	assert.Equal(t, "$4\r\n10.6\r\n", run(s, c, "INCRBYFLOAT", "f", "0.1"))
+	assert.Equal(t, "$2\r\n10\r\n", run(s, c, "INCRBYFLOAT", "f", "-0.6"))
+	assert.Equal(t, "$3\r\nint\r\n", run(s, c, "OBJECT", "ENCODING", "f"))
+	assert.Equal(t, "$4\r\n5010\r\n", run(s, c, "INCRBYFLOAT", "f", "5.0e3"))
+	assert.Equal(t, "-ERR value is not a valid float\r\n", run(s, c, "INCRBYFLOAT", "f", "nan"))
+	run(s, c, "SET", "f", "1e400")
+	assert.Equal(t, "-ERR value is not a valid float\r\n", run(s, c, "INCRBYFLOAT", "f", "1"))
+}
+
+func TestStringRanges(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, ":5\r\n", run(s, c, "APPEND", "k", "Hello"))
+	assert.Equal(t, ":11\r\n", run(s, c, "APPEND", "k", " World"))
+	assert.Equal(t, ":11\r\n", run(s, c, "STRLEN", "k"))
+	assert.Equal(t, ":0\r\n", run(s, c, "STRLEN", "missing"))
+
+	assert.Equal(t, "$5\r\nHello\r\n", run(s, c, "GETRANGE", "k", "0", "4"))
+	assert.Equal(t, "$5\r\nWorld\r\n", run(s, c, "GETRANGE", "k", "-5", "-1"))
+	assert.Equal(t, "$11\r\nHello World\r\n", run(s, c, "GETRANGE", "k", "-100", "100"))
+	assert.Equal(t, "$0\r\n\r\n", run(s, c, "GETRANGE", "k", "5", "2"))
+	assert.Equal(t, "$0\r\n\r\n", run(s, c, "GETRANGE", "k", "-1", "-5"))
+	assert.Equal(t, "$0\r\n\r\n", run(s, c, "GETRANGE", "missing", "0", "-1"))
+
+	assert.Equal(t, ":11\r\n", run(s, c, "SETRANGE", "k", "6", "Redis"))
+	assert.Equal(t, "$11\r\nHello Redis\r\n", run(s, c, "GET", "k"))
+	assert.Equal(t, ":4\r\n", run(s, c, "SETRANGE", "pad", "3", "x"))
+	assert.Equal(t, "$4\r\n\x00\x00\x00x\r\n", run(s, c, "GET", "pad"))
+	assert.Equal(t, ":0\r\n", run(s, c, "SETRANGE", "missing", "3", ""))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "missing"))
+	assert.Equal(t, "-ERR offset is out of range\r\n", run(s, c, "SETRANGE", "k", "-1", "x"))
+	assert.Equal(t, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n", run(s, c, "SETRANGE", "k", "536870911", "xx"))
+
+	// appending to an integer makes another integer
+	run(s, c, "SET", "n", "12")
+	run(s, c, "APPEND", "n", "3")
+	assert.Equal(t, ":124\r\n", run(s, c, "INCR", "n"))
+}
+
+func TestMultiKeyStrings(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+
+	assert.Equal(t, "+OK\r\n", run(s, c, "MSET", "a", "1", "b", "2"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'mset' command\r\n", run(s, c, "MSET", "a", "1", "b"))
+	run(s, c, "RPUSH", "list", "x")
+	assert.Equal(t, "*4\r\n$1\r\n1\r\n$1\r\n2\r\n$-1\r\n$-1\r\n", run(s, c, "MGET", "a", "b", "missing", "list"))
+	assert.Equal(t, ":0\r\n", run(s, c, "MSETNX", "c", "3", "a", "x"))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", "c"))
+	assert.Equal(t, ":1\r\n", run(s, c, "MSETNX", "c", "3", "d", "4"))
+	assert.Equal(t, "*2\r\n$1\r\n3\r\n$1\r\n4\r\n", run(s, c, "MGET", "c", "d"))
+}
+
+func TestLCS(t *testing.T) {
+	s := core.NewStorage()
+	c := core.NewClient()
+	run(s, c, "MSET", "key1", "ohmytext", "key2", "mynewtext")
+
+	assert.Equal(t, "$6\r\nmytext\r\n", run(s, c, "LCS", "key1", "key2"))
+	assert.Equal(t, ":6\r\n", run(s, c, "LCS", "key1", "key2", "LEN"))
+	assert.Equal(t, "*4\r\n$7\r\nmatches\r\n*2\r\n"+
+		"*2\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n"+
+		"*2\r\n*2\r\n:2\r\n:3\r\n*2\r\n:0\r\n:1\r\n"+
+		"$3\r\nlen\r\n:6\r\n", run(s, c, "LCS", "key1", "key2", "IDX"))
+	assert.Equal(t, "*4\r\n$7\r\nmatches\r\n*1\r\n"+
+		"*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n"+
+		"$3\r\nlen\r\n:6\r\n", run(s, c, "LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"))
+	assert.Equal(t, "$0\r\n\r\n", run(s, c, "LCS", "key1", "missing"))
+	assert.Equal(t, "-ERR If you want both the length and indexes, please just use IDX.\r\n", run(s, c, "LCS", "key1", "key2", "LEN", "IDX"))
+
+	run(s, c, "RPUSH", "list", "x")
+	assert.Equal(t, "-ERR The specified keys must contain string values\r\n", run(s, c, "LCS", "key1", "list"))
+
+	// the table of 20001*20001 uint32 would exceed proto-max-bulk-len
+	run(s, c, "MSET", "long1", strings.Repeat("a", 20000), "long2", strings.Repeat("b", 20000))
+	assert.Equal(t, "-ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len\r\n", run(s, c, "LCS", "long1", "long2"))
+}
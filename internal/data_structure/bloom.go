@@ -1,14 +1,21 @@
 package data_structure
 
 import (
-	"github.com/spaolacci/murmur3"
+	"encoding/binary"
+	"errors"
 	"math"
+
+	"github.com/spaolacci/murmur3"
 )
 
 const Ln2 float64 = 0.693147180559945
 const Ln2Square float64 = 0.480453013918201
 const ABigSeed uint32 = 0x9747b28c
 
+// BloomMaxBytes bounds the size of the bit arrays of a filter, whatever the
+// capacity, error rate and expansion asked for
+const BloomMaxBytes = 1 << 30
+
 type Bloom struct {
 	Hashes      int
 	Entries     uint64
@@ -41,18 +48,24 @@ func CreateBloomFilter(entries uint64, errorRate float64) *Bloom {
 		Error:   errorRate,
 	}
 	bloom.bitPerEntry = calcBpe(errorRate)
-	bits := uint64(float64(entries) * bloom.bitPerEntry)
-	if bits%64 != 0 {
-		bloom.bytes = ((bits / 64) + 1) * 8
-	} else {
-		bloom.bytes = bits / 8
-	}
+	bloom.bytes, _ = bloomBytes(entries, errorRate)
 	bloom.bits = bloom.bytes * 8
 	bloom.Hashes = int(math.Ceil(Ln2 * bloom.bitPerEntry))
 	bloom.bf = make([]uint8, bloom.bytes)
 	return &bloom
 }
 
+// bloomBytes returns the size of the bit array of a filter holding entries
+// items with errorRate, a multiple of 8 bytes, and false when it is larger
+// than BloomMaxBytes
+func bloomBytes(entries uint64, errorRate float64) (uint64, bool) {
+	bits := float64(entries) * calcBpe(errorRate)
+	if !(bits <= BloomMaxBytes*8) { // nan too
+		return 0, false
+	}
+	return max(uint64(bits)+63, 64) / 64 * 8, true
+}
+
 func (b *Bloom) CalcHash(entry string) HashValue {
 	hasher := murmur3.New128WithSeed(ABigSeed)
 	hasher.Write([]byte(entry))
@@ -105,4 +118,31 @@ func (b *Bloom) ExistHash(initHash HashValue) bool {
 		}
 	}
 	return true
-}
\ No newline at end of file
+}
+
+// MarshalBinary encodes the filter parameters followed by its bit array.
+// The derived fields are recomputed by UnmarshalBinary.
+func (b *Bloom) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 16+len(b.bf))
+	buf = binary.LittleEndian.AppendUint64(buf, b.Entries)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(b.Error))
+	return append(buf, b.bf...), nil
+}
+
+func (b *Bloom) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return errors.New("bloom: encoded filter too short")
+	}
+	entries := binary.LittleEndian.Uint64(data)
+	errorRate := math.Float64frombits(binary.LittleEndian.Uint64(data[8:]))
+	if !(errorRate > 0 && errorRate < 1) {
+		return errors.New("bloom: invalid error rate")
+	}
+	if size, ok := bloomBytes(entries, errorRate); !ok || uint64(len(data)-16) != size {
+		return errors.New("bloom: bit array size mismatch")
+	}
+	decoded := CreateBloomFilter(entries, errorRate)
+	copy(decoded.bf, data[16:])
+	*b = *decoded
+	return nil
+}
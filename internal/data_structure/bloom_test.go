@@ -31,4 +31,21 @@ func TestBloom_AddHash(t *testing.T) {
 	b.AddHash(hash)
 	assert.True(t, b.ExistHash(hash))
 	assert.True(t, b.Exist("abcdef"))
-}
\ No newline at end of file
+}
+func TestBloom_MarshalBinary(t *testing.T) {
+	b := CreateBloomFilter(100, 0.01)
+	b.Add("a")
+	b.Add("b")
+	data, err := b.MarshalBinary()
+	assert.NoError(t, err)
+
+	var decoded Bloom
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.EqualValues(t, b.Entries, decoded.Entries)
+	assert.EqualValues(t, b.Hashes, decoded.Hashes)
+	assert.True(t, decoded.Exist("a"))
+	assert.True(t, decoded.Exist("b"))
+	assert.False(t, decoded.Exist("c"))
+
+	assert.Error(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+}
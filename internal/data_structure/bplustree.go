@@ -59,28 +59,36 @@ func (t *BPlusTree) Score(member string) (float64, bool) {
 	return item.Score, true
 }
 
+// Results of Add
+const (
+	AddIgnored   = iota // the member is empty
+	AddNew              // the member was inserted
+	AddUpdated          // the score of the member changed
+	AddUnchanged        // the member already had this score
+)
+
 func (t *BPlusTree) Add(score float64, member string) int {
 	if len(member) == 0 {
-		return 0
+		return AddIgnored
 	}
 
 	if existingItem, exist := t.MemberMap[member]; exist {
 		oldScore := existingItem.Score
 		if oldScore == score {
-			return 1
+			return AddUnchanged
 		}
 		// Reposition: remove, update score, re-insert
 		t.removeFromTree(member, oldScore)
 		existingItem.Score = score
 		t.insertIntoTree(existingItem)
-		return 1
+		return AddUpdated
 	}
 
 	// New member
 	item := &Item{Score: score, Member: member}
 	t.MemberMap[member] = item
 	t.insertIntoTree(item)
-	return 1
+	return AddNew
 }
 
 func (t *BPlusTree) insertIntoTree(item *Item) {
@@ -89,11 +97,7 @@ func (t *BPlusTree) insertIntoTree(item *Item) {
 	// Navigate to leaf, increment sizes
 	for !node.IsLeaf {
 		node.Size++
-		i := 0
-		for i < len(node.Items) && item.Score >= node.Items[i].Score {
-			i++
-		}
-		node = node.Children[i]
+		node = node.Children[node.childIndex(item)]
 	}
 
 	// Insert in sorted order
@@ -112,27 +116,146 @@ func (t *BPlusTree) insertIntoTree(item *Item) {
 
 func (t *BPlusTree) removeFromTree(member string, score float64) {
 	node := t.Root
+	target := &Item{Score: score, Member: member}
 
 	// Navigate to leaf, decrement sizes
 	for !node.IsLeaf {
 		node.Size--
-		i := 0
-		for i < len(node.Items) && score >= node.Items[i].Score {
-			i++
-		}
-		node = node.Children[i]
+		node = node.Children[node.childIndex(target)]
 	}
 
-	// Remove from leaf (no rebalancing)
+	// Remove from leaf, then refill it if it became underfull
 	for i, item := range node.Items {
 		if item.Member == member {
 			node.Items = append(node.Items[:i], node.Items[i+1:]...)
 			node.Size--
+			t.rebalance(node)
 			return
 		}
 	}
 }
 
+// minItems is the least number of items a node other than the root holds,
+// what the smaller half of a split gets
+func (t *BPlusTree) minItems() int {
+	return max((t.Degree-1)/2, 1)
+}
+
+// rebalance restores the occupancy of node after a removal: it borrows an
+// item from a sibling that can spare one, or merges with a sibling, which
+// removes a separator from the parent and may rebalance it in turn
+func (t *BPlusTree) rebalance(node *Node) {
+	if node == t.Root {
+		// a root left with a single child is replaced by it
+		if !node.IsLeaf && len(node.Children) == 1 {
+			t.Root = node.Children[0]
+			t.Root.Parent = nil
+		}
+		return
+	}
+	if len(node.Items) >= t.minItems() {
+		return
+	}
+
+	parent := node.Parent
+	idx := 0
+	for parent.Children[idx] != node {
+		idx++
+	}
+	var left, right *Node
+	if idx > 0 {
+		left = parent.Children[idx-1]
+	}
+	if idx+1 < len(parent.Children) {
+		right = parent.Children[idx+1]
+	}
+
+	switch {
+	case left != nil && len(left.Items) > t.minItems():
+		t.borrowFromLeft(node, left, idx)
+	case right != nil && len(right.Items) > t.minItems():
+		t.borrowFromRight(node, right, idx)
+	case left != nil:
+		t.merge(left, node, idx-1)
+	default:
+		t.merge(node, right, idx)
+	}
+}
+
+// borrowFromLeft moves the last item of left, the sibling before node, to
+// node; idx is the index of node in their parent
+func (t *BPlusTree) borrowFromLeft(node, left *Node, idx int) {
+	parent := node.Parent
+	last := len(left.Items) - 1
+	if node.IsLeaf {
+		node.Items = append([]*Item{left.Items[last]}, node.Items...)
+		left.Items = left.Items[:last]
+		left.Size--
+		node.Size++
+		first := node.Items[0]
+		parent.Items[idx-1] = &Item{Score: first.Score, Member: first.Member}
+		return
+	}
+
+	// rotate through the parent: its separator comes down, the last key of
+	// left goes up, and the last child of left moves along
+	child := left.Children[last+1]
+	node.Items = append([]*Item{parent.Items[idx-1]}, node.Items...)
+	node.Children = append([]*Node{child}, node.Children...)
+	parent.Items[idx-1] = left.Items[last]
+	left.Items = left.Items[:last]
+	left.Children = left.Children[:last+1]
+	child.Parent = node
+	left.Size -= child.Size
+	node.Size += child.Size
+}
+
+// borrowFromRight moves the first item of right, the sibling after node, to
+// node; idx is the index of node in their parent
+func (t *BPlusTree) borrowFromRight(node, right *Node, idx int) {
+	parent := node.Parent
+	if node.IsLeaf {
+		node.Items = append(node.Items, right.Items[0])
+		right.Items = right.Items[1:]
+		right.Size--
+		node.Size++
+		first := right.Items[0]
+		parent.Items[idx] = &Item{Score: first.Score, Member: first.Member}
+		return
+	}
+
+	child := right.Children[0]
+	node.Items = append(node.Items, parent.Items[idx])
+	node.Children = append(node.Children, child)
+	parent.Items[idx] = right.Items[0]
+	right.Items = right.Items[1:]
+	right.Children = right.Children[1:]
+	child.Parent = node
+	right.Size -= child.Size
+	node.Size += child.Size
+}
+
+// merge moves every item of right into left, its sibling before it, and
+// removes right and the separator at sepIdx from their parent
+func (t *BPlusTree) merge(left, right *Node, sepIdx int) {
+	parent := left.Parent
+	if left.IsLeaf {
+		left.Items = append(left.Items, right.Items...)
+		left.Next = right.Next
+	} else {
+		left.Items = append(append(left.Items, parent.Items[sepIdx]), right.Items...)
+		left.Children = append(left.Children, right.Children...)
+		for _, child := range right.Children {
+			child.Parent = left
+		}
+	}
+	left.Size += right.Size
+
+	parent.Items = append(parent.Items[:sepIdx], parent.Items[sepIdx+1:]...)
+	parent.Children = append(parent.Children[:sepIdx+1], parent.Children[sepIdx+2:]...)
+	t.rebalance(parent)
+}
+
 func (t *BPlusTree) splitNode(node *Node) {
 	if node.Parent == nil {
 		t.splitRoot()
@@ -162,9 +285,10 @@ func (t *BPlusTree) splitLeaf(node *Node) {
 	node.Size = len(node.Items)
 	node.Next = newLeaf
 
-	// Promote first key of new leaf to parent
+	// Promote a copy of the first key of new leaf to parent, the item itself
+	// changes when its member gets a new score
 	parent := node.Parent
-	promotedItem := newLeaf.Items[0]
+	promotedItem := &Item{Score: newLeaf.Items[0].Score, Member: newLeaf.Items[0].Member}
 
 	childIndex := 0
 	for childIndex < len(parent.Children) {
@@ -261,25 +385,88 @@ func (t *BPlusTree) GetRank(member string) int {
 }
 
 func (t *BPlusTree) getRankByItem(targetItem *Item) int {
-	accumulatedItems := 0
-	node := t.Root
+	return t.countWhile(func(item *Item) bool {
+		return item.CompareTo(targetItem) < 0
+	})
+}
 
-	// Accumulate sizes of left subtrees
+// childIndex returns the index of the child of internal node n whose subtree
+// holds item. Keys of child i are below Items[i], those of child i+1 are at
+// least Items[i].
+func (n *Node) childIndex(item *Item) int {
+	i := 0
+	for i < len(n.Items) && item.CompareTo(n.Items[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// countWhile returns the number of items, in order, before the first one for
+// which pred is false. pred must hold for a prefix of the items, e.g. "score
+// below 10": whole subtrees are then counted with their Size in O(log N).
+func (t *BPlusTree) countWhile(pred func(item *Item) bool) int {
+	count := 0
+	node := t.Root
 	for !node.IsLeaf {
 		i := 0
-		for i < len(node.Items) && targetItem.Score >= node.Items[i].Score {
-			accumulatedItems += node.Children[i].Size
+		// every key of child i is below Items[i], so pred holds for all of them
+		for i < len(node.Items) && pred(node.Items[i]) {
+			count += node.Children[i].Size
 			i++
 		}
 		node = node.Children[i]
 	}
+	for _, item := range node.Items {
+		if !pred(item) {
+			break
+		}
+		count++
+	}
+	return count
+}
 
-	// Find position in leaf
-	for i, item := range node.Items {
-		if item.Member == targetItem.Member {
-			return accumulatedItems + i
+// leafAt returns the leaf holding the item of rank 0 <= rank < Len() and the
+// position of the item in the leaf
+func (t *BPlusTree) leafAt(rank int) (*Node, int) {
+	node := t.Root
+	for !node.IsLeaf {
+		i := 0
+		for i < len(node.Children)-1 && rank >= node.Children[i].Size {
+			rank -= node.Children[i].Size
+			i++
 		}
+		node = node.Children[i]
 	}
+	return node, rank
+}
 
-	return -1
+// Len returns the number of items
+func (t *BPlusTree) Len() int {
+	return len(t.MemberMap)
+}
+
+// Items returns the items ranked start to end-1 in ascending order, walking
+// the leaves from the one holding start. 0 <= start <= end <= Len().
+func (t *BPlusTree) Items(start, end int) []*Item {
+	if start >= end {
+		return nil
+	}
+	res := make([]*Item, 0, end-start)
+	node, offset := t.leafAt(start)
+	for ; node != nil && len(res) < cap(res); node, offset = node.Next, 0 {
+		n := min(len(node.Items), offset+cap(res)-len(res))
+		res = append(res, node.Items[offset:n]...)
+	}
+	return res
+}
+
+// Remove deletes member and reports whether it was in the tree
+func (t *BPlusTree) Remove(member string) bool {
+	item, exist := t.MemberMap[member]
+	if !exist {
+		return false
+	}
+	t.removeFromTree(member, item.Score)
+	delete(t.MemberMap, member)
+	return true
 }
@@ -1,6 +1,8 @@
 package data_structure
 
 import (
+	"math/rand"
+	"strconv"
 	"testing"
 )
 
@@ -60,17 +62,26 @@ func TestSplitLeaf(t *testing.T) {
 
 func TestUpdateExistingMember(t *testing.T) {
 	tree := NewBPlusTree(3)
-	tree.Add(5, "x")
-	tree.Add(5, "x") // same score -> no change
+	if ret := tree.Add(5, "x"); ret != AddNew {
+		t.Fatalf("expected AddNew, got %d", ret)
+	}
+	if ret := tree.Add(5, "x"); ret != AddUnchanged { // same score -> no change
+		t.Fatalf("expected AddUnchanged, got %d", ret)
+	}
 	s, _ := tree.Score("x")
 	if s != 5 {
 		t.Fatalf("expected score 5, got %v", s)
 	}
-	tree.Add(10, "x")
+	if ret := tree.Add(10, "x"); ret != AddUpdated {
+		t.Fatalf("expected AddUpdated, got %d", ret)
+	}
 	s, _ = tree.Score("x")
 	if s != 10 {
 		t.Fatalf("expected updated score 10")
 	}
+	if ret := tree.Add(1, ""); ret != AddIgnored {
+		t.Fatalf("expected AddIgnored, got %d", ret)
+	}
 }
 
 func TestRankOrder(t *testing.T) {
@@ -153,8 +164,8 @@ func TestBPlusTree_Add_UpdateExisting(t *testing.T) {
 	// Update with new score
 	result := tree.Add(20.0, "member1")
 
-	if result != 1 {
-		t.Errorf("expected Add to return 1 when updating, got %d", result)
+	if result != AddUpdated {
+		t.Errorf("expected Add to return AddUpdated when updating, got %d", result)
 	}
 
 	score, found := tree.Score("member1")
@@ -532,3 +543,118 @@ func TestBPlusTree_FloatingPointScores(t *testing.T) {
 		t.Errorf("ranks not in expected order: %d, %d, %d", rank1, rank2, rank3)
 	}
 }
+
+// checkTree verifies the invariants of the tree: sizes, separators, node
+// occupancy, parent pointers and the leaf chain holding every item in order
+func checkTree(t *testing.T, tree *BPlusTree) {
+	t.Helper()
+	var leaves []*Node
+	var walk func(node *Node, lo, hi *Item) int
+	walk = func(node *Node, lo, hi *Item) int {
+		if node != tree.Root && len(node.Items) < tree.minItems() {
+			t.Fatalf("node with %d items, expected at least %d", len(node.Items), tree.minItems())
+		}
+		if len(node.Items) > tree.Degree-1 {
+			t.Fatalf("node with %d items, expected at most %d", len(node.Items), tree.Degree-1)
+		}
+		for _, item := range node.Items {
+			if (lo != nil && item.CompareTo(lo) < 0) || (hi != nil && item.CompareTo(hi) >= 0) {
+				t.Fatalf("item %v out of its subtree bounds", *item)
+			}
+		}
+		if node.IsLeaf {
+			leaves = append(leaves, node)
+			if node.Size != len(node.Items) {
+				t.Fatalf("leaf size %d, expected %d", node.Size, len(node.Items))
+			}
+			return node.Size
+		}
+		if len(node.Children) != len(node.Items)+1 {
+			t.Fatalf("%d children for %d items", len(node.Children), len(node.Items))
+		}
+		size := 0
+		for i, child := range node.Children {
+			if child.Parent != node {
+				t.Fatal("wrong parent pointer")
+			}
+			childLo, childHi := lo, hi
+			if i > 0 {
+				childLo = node.Items[i-1]
+			}
+			if i < len(node.Items) {
+				childHi = node.Items[i]
+			}
+			size += walk(child, childLo, childHi)
+		}
+		if node.Size != size {
+			t.Fatalf("node size %d, expected %d", node.Size, size)
+		}
+		return size
+	}
+	if size := walk(tree.Root, nil, nil); size != len(tree.MemberMap) {
+		t.Fatalf("tree holds %d items, expected %d", size, len(tree.MemberMap))
+	}
+
+	var prev *Item
+	for i, leaf := range leaves {
+		if i+1 < len(leaves) && leaf.Next != leaves[i+1] || i+1 == len(leaves) && leaf.Next != nil {
+			t.Fatal("broken leaf chain")
+		}
+		for _, item := range leaf.Items {
+			if prev != nil && prev.CompareTo(item) >= 0 {
+				t.Fatalf("items %v and %v out of order", *prev, *item)
+			}
+			if tree.MemberMap[item.Member] != item {
+				t.Fatalf("item %v not in the member map", *item)
+			}
+			prev = item
+		}
+	}
+}
+
+func TestBPlusTree_Remove(t *testing.T) {
+	tree := NewBPlusTree(3)
+	for i := 0; i < 20; i++ {
+		tree.Add(float64(i), string(rune('a'+i)))
+	}
+	if tree.Remove("z") {
+		t.Error("removed a missing member")
+	}
+	for i := 0; i < 20; i += 2 {
+		if !tree.Remove(string(rune('a' + i))) {
+			t.Errorf("member %c not removed", 'a'+i)
+		}
+		checkTree(t, tree)
+	}
+	if tree.Len() != 10 {
+		t.Errorf("expected 10 items, got %d", tree.Len())
+	}
+	if rank := tree.GetRank("d"); rank != 1 {
+		t.Errorf("expected rank 1, got %d", rank)
+	}
+	for i := 1; i < 20; i += 2 {
+		tree.Remove(string(rune('a' + i)))
+		checkTree(t, tree)
+	}
+	if !tree.Root.IsLeaf || tree.Root.Size != 0 {
+		t.Error("expected an empty leaf root")
+	}
+}
+
+func TestBPlusTree_RandomAddRemove(t *testing.T) {
+	for _, degree := range []int{3, 4, 5, 8} {
+		tree := NewBPlusTree(degree)
+		for i := 0; i < 3000; i++ {
+			member := strconv.Itoa(rand.Intn(200))
+			if rand.Intn(3) == 0 {
+				tree.Remove(member)
+			} else {
+				tree.Add(float64(rand.Intn(20)), member)
+			}
+			if i%50 == 0 {
+				checkTree(t, tree)
+			}
+		}
+		checkTree(t, tree)
+	}
+}
@@ -1,8 +1,11 @@
 package data_structure
 
 import (
-	"github.com/spaolacci/murmur3"
+	"encoding/binary"
+	"errors"
 	"math"
+
+	"github.com/spaolacci/murmur3"
 )
 
 // Log10PointFive is a precomputed value for log10(0.5).
@@ -94,4 +97,39 @@ func (c *CMS) Count(item string) uint32 {
 		}
 	}
 	return minCount
-}
\ No newline at end of file
+}
+
+// MarshalBinary encodes the dimensions of the sketch followed by its counters,
+// row by row.
+func (c *CMS) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 8+4*int(c.width)*int(c.depth))
+	buf = binary.LittleEndian.AppendUint32(buf, c.width)
+	buf = binary.LittleEndian.AppendUint32(buf, c.depth)
+	for _, row := range c.counter {
+		for _, v := range row {
+			buf = binary.LittleEndian.AppendUint32(buf, v)
+		}
+	}
+	return buf, nil
+}
+
+func (c *CMS) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("cms: encoded sketch too short")
+	}
+	w := binary.LittleEndian.Uint32(data)
+	d := binary.LittleEndian.Uint32(data[4:])
+	if uint64(len(data)-8) != 4*uint64(w)*uint64(d) {
+		return errors.New("cms: counter matrix size mismatch")
+	}
+	decoded := CreateCMS(w, d)
+	data = data[8:]
+	for i := range decoded.counter {
+		for j := range decoded.counter[i] {
+			decoded.counter[i][j] = binary.LittleEndian.Uint32(data)
+			data = data[4:]
+		}
+	}
+	*c = *decoded
+	return nil
+}
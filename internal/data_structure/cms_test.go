@@ -0,0 +1,23 @@
+package data_structure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCMS_MarshalBinary(t *testing.T) {
+	c := CreateCMS(100, 4)
+	c.IncrBy("a", 3)
+	c.IncrBy("b", 7)
+	data, err := c.MarshalBinary()
+	assert.NoError(t, err)
+
+	var decoded CMS
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.EqualValues(t, 3, decoded.Count("a"))
+	assert.EqualValues(t, 7, decoded.Count("b"))
+	assert.EqualValues(t, 0, decoded.Count("c"))
+
+	assert.Error(t, decoded.UnmarshalBinary(data[:len(data)-4]))
+}
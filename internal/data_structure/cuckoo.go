@@ -0,0 +1,329 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+
+	"github.com/spaolacci/murmur3"
+)
+
+const (
+	// CuckooMaxBytes bounds the size of the fingerprints of a filter,
+	// whatever the capacity and expansion asked for
+	CuckooMaxBytes = 1 << 30
+	// CuckooMaxLayers bounds the number of sub-filters a lookup goes through
+	CuckooMaxLayers = 256
+	// CuckooMaxExpansion bounds the expansion of a filter, like RedisBloom
+	CuckooMaxExpansion = 32768
+)
+
+var (
+	// ErrCuckooFull is returned when an item finds no room in a cuckoo
+	// filter that can't expand
+	ErrCuckooFull = errors.New("Filter is full")
+	// ErrCuckooTooLarge is returned when the sub-filter a filter needs would
+	// make its fingerprints larger than CuckooMaxBytes, or its sub-filters
+	// more than CuckooMaxLayers
+	ErrCuckooTooLarge = errors.New("filter would exceed the maximum size")
+)
+
+// cuckooLayer is one sub-filter: numBuckets buckets of BucketSize 8 bit
+// fingerprints, 0 marking an empty slot. numBuckets is a power of 2 so that
+// the alternate bucket of a fingerprint can be found back from either.
+type cuckooLayer struct {
+	numBuckets uint64
+	slots      []uint8
+}
+
+// CuckooFilter is a cuckoo filter like the one of RedisBloom: an item is
+// stored as a fingerprint in one of two buckets, kicking out the
+// fingerprints in its way to their other bucket up to MaxIterations times.
+// Unlike a bloom filter, items can be deleted. When an item finds no room,
+// a sub-filter Expansion times larger is added, unless Expansion is 0.
+type CuckooFilter struct {
+	BucketSize    int
+	MaxIterations int
+	Expansion     uint64
+	Items         uint64 // items added and not deleted
+	Deleted       uint64 // items deleted
+	layers        []cuckooLayer
+}
+
+func nextPow2(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(n-1)
+}
+
+// NewCuckooFilter returns a filter holding at least capacity items in its
+// first sub-filter. It fails with ErrCuckooTooLarge when that sub-filter is
+// larger than CuckooMaxBytes.
+func NewCuckooFilter(capacity uint64, bucketSize, maxIterations int, expansion uint64) (*CuckooFilter, error) {
+	f := &CuckooFilter{BucketSize: bucketSize, MaxIterations: maxIterations, Expansion: expansion}
+	numBuckets := capacity / uint64(bucketSize)
+	if capacity%uint64(bucketSize) != 0 {
+		numBuckets++
+	}
+	if err := f.addLayer(numBuckets); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// addLayer adds a sub-filter of numBuckets buckets rounded up to a power of
+// 2, unless the filter would exceed CuckooMaxBytes or CuckooMaxLayers
+func (f *CuckooFilter) addLayer(numBuckets uint64) error {
+	room := (CuckooMaxBytes - f.Size()) / uint64(f.BucketSize)
+	// checked before being rounded up too, which could wrap
+	if len(f.layers) == CuckooMaxLayers || numBuckets > room || nextPow2(numBuckets) > room {
+		return ErrCuckooTooLarge
+	}
+	numBuckets = nextPow2(numBuckets)
+	f.layers = append(f.layers, cuckooLayer{
+		numBuckets: numBuckets,
+		slots:      make([]uint8, numBuckets*uint64(f.BucketSize)),
+	})
+	return nil
+}
+
+// cuckooHash returns the hash of item and its fingerprint, never 0
+func cuckooHash(item string) (uint64, uint8) {
+	h := murmur3.Sum64([]byte(item))
+	return h, uint8(h%255 + 1)
+}
+
+// altIndex returns the other bucket of fp when in bucket i
+func (l *cuckooLayer) altIndex(i uint64, fp uint8) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & (l.numBuckets - 1)
+}
+
+// buckets returns the two buckets fp of hash h may be in
+func (l *cuckooLayer) buckets(h uint64, fp uint8) (uint64, uint64) {
+	i := h & (l.numBuckets - 1)
+	return i, l.altIndex(i, fp)
+}
+
+func (l *cuckooLayer) bucket(i uint64, size int) []uint8 {
+	return l.slots[i*uint64(size) : (i+1)*uint64(size)]
+}
+
+// insert puts fp in a free slot of bucket i and reports whether there was one
+func (l *cuckooLayer) insert(i uint64, fp uint8, size int) bool {
+	bucket := l.bucket(i, size)
+	for slot, v := range bucket {
+		if v == 0 {
+			bucket[slot] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// count returns how many times fp is in bucket i
+func (l *cuckooLayer) count(i uint64, fp uint8, size int) uint64 {
+	var n uint64
+	for _, v := range l.bucket(i, size) {
+		if v == fp {
+			n++
+		}
+	}
+	return n
+}
+
+// remove deletes one fp from bucket i and reports whether it was there
+func (l *cuckooLayer) remove(i uint64, fp uint8, size int) bool {
+	bucket := l.bucket(i, size)
+	for slot, v := range bucket {
+		if v == fp {
+			bucket[slot] = 0
+			return true
+		}
+	}
+	return false
+}
+
+// kickInsert makes room for fp in bucket i by moving the fingerprints in
+// its way to their other bucket. When there is still no room after
+// MaxIterations moves, they are undone and it returns false.
+func (f *CuckooFilter) kickInsert(l *cuckooLayer, i uint64, fp uint8) bool {
+	var path []uint64 // the slot swapped at each move
+	for n := 0; n < f.MaxIterations; n++ {
+		pos := i*uint64(f.BucketSize) + uint64(n%f.BucketSize)
+		fp, l.slots[pos] = l.slots[pos], fp
+		path = append(path, pos)
+		i = l.altIndex(i, fp)
+		if l.insert(i, fp, f.BucketSize) {
+			return true
+		}
+	}
+	for n := len(path) - 1; n >= 0; n-- {
+		fp, l.slots[path[n]] = l.slots[path[n]], fp
+	}
+	return false
+}
+
+// Add adds item, which may already be there
+func (f *CuckooFilter) Add(item string) error {
+	h, fp := cuckooHash(item)
+	// a free slot in any sub-filter, the newest ones having the most room
+	for n := len(f.layers) - 1; n >= 0; n-- {
+		l := &f.layers[n]
+		i1, i2 := l.buckets(h, fp)
+		if l.insert(i1, fp, f.BucketSize) || l.insert(i2, fp, f.BucketSize) {
+			f.Items++
+			return nil
+		}
+	}
+	last := &f.layers[len(f.layers)-1]
+	i1, _ := last.buckets(h, fp)
+	if !f.kickInsert(last, i1, fp) {
+		if f.Expansion == 0 {
+			return ErrCuckooFull
+		}
+		hi, numBuckets := bits.Mul64(last.numBuckets, f.Expansion)
+		if hi != 0 {
+			return ErrCuckooTooLarge
+		}
+		if err := f.addLayer(numBuckets); err != nil {
+			return err
+		}
+		last = &f.layers[len(f.layers)-1]
+		i1, i2 := last.buckets(h, fp)
+		if !last.insert(i1, fp, f.BucketSize) && !last.insert(i2, fp, f.BucketSize) {
+			return ErrCuckooFull
+		}
+	}
+	f.Items++
+	return nil
+}
+
+// AddNX adds item unless it probably is there already, and reports whether
+// it did
+func (f *CuckooFilter) AddNX(item string) (bool, error) {
+	if f.Exist(item) {
+		return false, nil
+	}
+	return true, f.Add(item)
+}
+
+// Count returns how many times item was probably added, more when other
+// items share its fingerprint and buckets
+func (f *CuckooFilter) Count(item string) uint64 {
+	h, fp := cuckooHash(item)
+	var n uint64
+	for _, l := range f.layers {
+		i1, i2 := l.buckets(h, fp)
+		n += l.count(i1, fp, f.BucketSize)
+		if i2 != i1 {
+			n += l.count(i2, fp, f.BucketSize)
+		}
+	}
+	return n
+}
+
+// Exist reports whether item was probably added
+func (f *CuckooFilter) Exist(item string) bool {
+	h, fp := cuckooHash(item)
+	for _, l := range f.layers {
+		i1, i2 := l.buckets(h, fp)
+		if l.count(i1, fp, f.BucketSize) > 0 || l.count(i2, fp, f.BucketSize) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete deletes one occurrence of item and reports whether there was one.
+// Deleting an item that was not added may delete another one.
+func (f *CuckooFilter) Delete(item string) bool {
+	h, fp := cuckooHash(item)
+	for n := len(f.layers) - 1; n >= 0; n-- {
+		l := &f.layers[n]
+		i1, i2 := l.buckets(h, fp)
+		if l.remove(i1, fp, f.BucketSize) || l.remove(i2, fp, f.BucketSize) {
+			f.Items--
+			f.Deleted++
+			return true
+		}
+	}
+	return false
+}
+
+// Buckets returns the number of buckets of the sub-filters
+func (f *CuckooFilter) Buckets() uint64 {
+	var n uint64
+	for _, l := range f.layers {
+		n += l.numBuckets
+	}
+	return n
+}
+
+// Size returns the number of bytes of the fingerprints
+func (f *CuckooFilter) Size() uint64 {
+	return f.Buckets() * uint64(f.BucketSize)
+}
+
+// Layers returns the number of sub-filters
+func (f *CuckooFilter) Layers() int {
+	return len(f.layers)
+}
+
+// MarshalBinary encodes the parameters and counters of the filter and its
+// number of sub-filters, followed by the number of buckets and the
+// fingerprints of each
+func (f *CuckooFilter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 48+f.Size()+8*uint64(len(f.layers)))
+	for _, v := range []uint64{uint64(f.BucketSize), uint64(f.MaxIterations), f.Expansion, f.Items, f.Deleted, uint64(len(f.layers))} {
+		buf = binary.LittleEndian.AppendUint64(buf, v)
+	}
+	for _, l := range f.layers {
+		buf = binary.LittleEndian.AppendUint64(buf, l.numBuckets)
+		buf = append(buf, l.slots...)
+	}
+	return buf, nil
+}
+
+func (f *CuckooFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < 48 {
+		return errors.New("cuckoo: encoded filter too short")
+	}
+	var header [6]uint64
+	for i := range header {
+		header[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+	decoded := CuckooFilter{
+		BucketSize:    int(header[0]),
+		MaxIterations: int(header[1]),
+		Expansion:     header[2],
+		Items:         header[3],
+		Deleted:       header[4],
+	}
+	if decoded.BucketSize < 1 || decoded.BucketSize > 255 || decoded.Expansion > CuckooMaxExpansion ||
+		header[5] == 0 || header[5] > CuckooMaxLayers {
+		return errors.New("cuckoo: invalid parameters")
+	}
+	data = data[48:]
+	for n := uint64(0); n < header[5]; n++ {
+		if len(data) < 8 {
+			return errors.New("cuckoo: truncated sub-filter")
+		}
+		numBuckets := binary.LittleEndian.Uint64(data)
+		// divided rather than multiplied, which could wrap
+		if numBuckets == 0 || numBuckets&(numBuckets-1) != 0 || numBuckets > uint64(len(data)-8)/uint64(decoded.BucketSize) {
+			return errors.New("cuckoo: invalid sub-filter")
+		}
+		if err := decoded.addLayer(numBuckets); err != nil {
+			return err
+		}
+		size := numBuckets * uint64(decoded.BucketSize)
+		copy(decoded.layers[n].slots, data[8:8+size])
+		data = data[8+size:]
+	}
+	if len(data) != 0 {
+		return errors.New("cuckoo: trailing bytes after the last sub-filter")
+	}
+	*f = decoded
+	return nil
+}
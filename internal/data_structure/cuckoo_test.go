@@ -0,0 +1,124 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCuckooFilter_AddDelete(t *testing.T) {
+	f, _ := NewCuckooFilter(1024, 2, 20, 1)
+	assert.EqualValues(t, 512, f.Buckets())
+	assert.NoError(t, f.Add("a"))
+	assert.NoError(t, f.Add("a"))
+	assert.True(t, f.Exist("a"))
+	assert.False(t, f.Exist("b"))
+	assert.EqualValues(t, 2, f.Count("a"))
+
+	assert.True(t, f.Delete("a"))
+	assert.EqualValues(t, 1, f.Count("a"))
+	assert.True(t, f.Delete("a"))
+	assert.False(t, f.Exist("a"))
+	assert.False(t, f.Delete("a"))
+	assert.EqualValues(t, 0, f.Items)
+	assert.EqualValues(t, 2, f.Deleted)
+
+	added, err := f.AddNX("b")
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, _ = f.AddNX("b")
+	assert.False(t, added)
+}
+
+func TestCuckooFilter_Expansion(t *testing.T) {
+	f, _ := NewCuckooFilter(64, 4, 20, 2)
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, f.Add(strconv.Itoa(i)))
+	}
+	assert.Greater(t, f.Layers(), 1)
+	assert.EqualValues(t, 1000, f.Items)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, f.Exist(strconv.Itoa(i)))
+	}
+	// deleting every item empties the filter
+	for i := 0; i < 1000; i++ {
+		assert.True(t, f.Delete(strconv.Itoa(i)))
+	}
+	for _, l := range f.layers {
+		assert.Equal(t, make([]uint8, len(l.slots)), l.slots)
+	}
+}
+
+func TestCuckooFilter_Full(t *testing.T) {
+	f, _ := NewCuckooFilter(8, 2, 10, 0)
+	var err error
+	added := 0
+	for i := 0; i < 100 && err == nil; i++ {
+		if err = f.Add(strconv.Itoa(i)); err == nil {
+			added++
+		}
+	}
+	assert.ErrorIs(t, err, ErrCuckooFull)
+	assert.Equal(t, 1, f.Layers())
+	assert.EqualValues(t, added, f.Items)
+	// the moves made looking for room are undone
+	for i := 0; i < added; i++ {
+		assert.True(t, f.Exist(strconv.Itoa(i)))
+	}
+}
+
+func TestCuckooFilter_MarshalBinary(t *testing.T) {
+	f, _ := NewCuckooFilter(16, 2, 20, 1)
+	for i := 0; i < 100; i++ {
+		f.Add(strconv.Itoa(i))
+	}
+	f.Delete("0")
+	data, err := f.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := &CuckooFilter{}
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, f, decoded)
+	assert.Error(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, decoded.UnmarshalBinary(data[:10]))
+}
+
+func TestCuckooFilter_Bounds(t *testing.T) {
+	_, err := NewCuckooFilter(math.MaxUint64, 1, 20, 1)
+	assert.ErrorIs(t, err, ErrCuckooTooLarge)
+	// 2^28+1 buckets of 3 fit, not once rounded up to 2^29
+	_, err = NewCuckooFilter(3<<28+3, 3, 20, 1)
+	assert.ErrorIs(t, err, ErrCuckooTooLarge)
+
+	// the size of a new sub-filter can't wrap
+	f, _ := NewCuckooFilter(2, 1, 1, math.MaxUint64)
+	for i := 0; ; i++ {
+		if err := f.Add(strconv.Itoa(i)); err != nil {
+			assert.ErrorIs(t, err, ErrCuckooTooLarge)
+			break
+		}
+	}
+	assert.Equal(t, 1, f.Layers())
+
+	// nor can the number of sub-filters grow without limit
+	f, _ = NewCuckooFilter(2, 1, 1, 1)
+	for i := 0; ; i++ {
+		if err := f.Add(strconv.Itoa(i)); err != nil {
+			assert.ErrorIs(t, err, ErrCuckooTooLarge)
+			break
+		}
+	}
+	assert.Equal(t, CuckooMaxLayers, f.Layers())
+
+	// a decoded filter is bounded too
+	f, _ = NewCuckooFilter(4, 2, 20, 1)
+	data, _ := f.MarshalBinary()
+	binary.LittleEndian.PutUint64(data[48:], 1<<63) // 2^63 buckets of 2 bytes
+	assert.Error(t, f.UnmarshalBinary(data))
+	binary.LittleEndian.PutUint64(data[48:], 2)
+	binary.LittleEndian.PutUint64(data[40:], CuckooMaxLayers+1)
+	assert.Error(t, f.UnmarshalBinary(data))
+}
@@ -8,7 +8,56 @@ import (
 	"goredis-lite/internal/config"
 )
 
+// ObjType tells what the Value of an Obj holds
+type ObjType uint8
+
+const (
+	ObjString ObjType = iota // string, int64 for an integer, see NewStringValue, []byte once written as a bitmap
+	ObjList                  // *List
+	ObjSet                   // *SimpleSet
+	ObjZSet                  // *SortedSet
+	ObjHash                  // *Hash
+	ObjCMS                   // *CMS
+	ObjBloom                 // *ScalableBloom
+	ObjCuckoo                // *CuckooFilter
+)
+
+// String returns the name TYPE replies, module types are named like the
+// RedisBloom module does
+func (t ObjType) String() string {
+	switch t {
+	case ObjString:
+		return "string"
+	case ObjList:
+		return "list"
+	case ObjSet:
+		return "set"
+	case ObjZSet:
+		return "zset"
+	case ObjHash:
+		return "hash"
+	case ObjCMS:
+		return "CMSk-TYPE"
+	case ObjBloom:
+		return "MBbloom--"
+	case ObjCuckoo:
+		return "MBbloomCF"
+	}
+	return "none"
+}
+
+// NewStringValue returns the Value of an ObjString holding s: an int64 when
+// s is the canonical form of one, like the int encoding of Redis, so that
+// counters are not parsed again on every increment, s otherwise
+func NewStringValue(s string) interface{} {
+	if n, ok := parseInt(s); ok {
+		return n
+	}
+	return s
+}
+
 type Obj struct {
+	Type           ObjType
 	Value          interface{}
 	LastAccessTime uint32
 }
@@ -16,6 +65,8 @@ type Obj struct {
 type Dict struct {
 	dictStore        map[string]*Obj
 	expiredDictStore map[string]int64
+	ePool            *EvictionPool
+	expiredKeys      int64 // keys deleted because their TTL elapsed
 }
 
 func now() uint32 {
@@ -26,6 +77,7 @@ func CreateDict() *Dict {
 	dict := Dict{
 		dictStore:        make(map[string]*Obj),
 		expiredDictStore: make(map[string]int64),
+		ePool:            newEpool(0),
 	}
 	return &dict
 }
@@ -38,8 +90,18 @@ func (d *Dict) GetDictStore() map[string]*Obj {
 	return d.dictStore
 }
 
-func (d *Dict) NewObj(key string, value interface{}, ttlMs int64) *Obj {
+// Stat returns the number of keys and of keys with a TTL in this dict
+func (d *Dict) Stat() KeySpaceStat {
+	return KeySpaceStat{
+		Key:     int64(len(d.dictStore)),
+		Expire:  int64(len(d.expiredDictStore)),
+		Expired: d.expiredKeys,
+	}
+}
+
+func (d *Dict) NewObj(key string, typ ObjType, value interface{}, ttlMs int64) *Obj {
 	obj := &Obj{
+		Type:           typ,
 		Value:          value,
 		LastAccessTime: now(),
 	}
@@ -58,6 +120,20 @@ func (d *Dict) SetExpiry(key string, ttlMs int64) {
 	d.expiredDictStore[key] = int64(time.Now().UnixMilli()) + int64(ttlMs)
 }
 
+// SetExpireAt sets the TTL of key to end at the unix time expireAtMs
+func (d *Dict) SetExpireAt(key string, expireAtMs int64) {
+	d.expiredDictStore[key] = expireAtMs
+}
+
+// Persist removes the TTL of key and reports whether it had one
+func (d *Dict) Persist(key string) bool {
+	if _, exist := d.expiredDictStore[key]; !exist {
+		return false
+	}
+	delete(d.expiredDictStore, key)
+	return true
+}
+
 func (d *Dict) HasExpired(key string) bool {
 	exp, exist := d.expiredDictStore[key]
 	if !exist {
@@ -66,12 +142,22 @@ func (d *Dict) HasExpired(key string) bool {
 	return exp <= int64(time.Now().UnixMilli())
 }
 
+// DeleteIfExpired deletes k when its TTL has elapsed and reports whether it did
+func (d *Dict) DeleteIfExpired(k string) bool {
+	if !d.HasExpired(k) {
+		return false
+	}
+	if d.Del(k) {
+		d.expiredKeys++
+	}
+	return true
+}
+
 func (d *Dict) Get(k string) *Obj {
 	v := d.dictStore[k]
 	if v != nil {
 		v.LastAccessTime = now()
-		if d.HasExpired(k) {
-			d.Del(k)
+		if d.DeleteIfExpired(k) {
 			return nil
 		}
 	}
@@ -81,14 +167,14 @@ func (d *Dict) Get(k string) *Obj {
 func (d *Dict) populateEpool() {
 	remain := config.EpoolLruSampleSize
 	for k := range d.dictStore {
-		ePool.Push(k, d.dictStore[k].LastAccessTime)
+		d.ePool.Push(k, d.dictStore[k].LastAccessTime)
 		remain--
 		if remain == 0 {
 			break
 		}
 	}
 	log.Println("EPool:")
-	for _, item := range ePool.pool {
+	for _, item := range d.ePool.pool {
 		log.Println(item.key, item.lastAccessTime)
 	}
 }
@@ -97,8 +183,8 @@ func (d *Dict) evictLru() {
 	d.populateEpool()
 	evictCount := int64(config.EvictionRatio * float64(config.MaxKeyNumber))
 	log.Print("trigger LRU eviction")
-	for i := 0; i < int(evictCount) && len(ePool.pool) > 0; i++ {
-		item := ePool.Pop()
+	for i := 0; i < int(evictCount) && len(d.ePool.pool) > 0; i++ {
+		item := d.ePool.Pop()
 		if item != nil {
 			d.Del(item.key)
 		}
@@ -146,10 +232,6 @@ func (d *Dict) Set(k string, obj *Obj) {
 	if len(d.dictStore) == config.MaxKeyNumber {
 		d.evict()
 	}
-	v := d.dictStore[k]
-	if v == nil {
-		HashKeySpaceStat.Key++
-	}
 	d.dictStore[k] = obj
 }
 
@@ -158,7 +240,6 @@ func (d *Dict) Del(k string) bool {
 	if _, exist := d.dictStore[k]; exist {
 		delete(d.dictStore, k)
 		delete(d.expiredDictStore, k)
-		HashKeySpaceStat.Key--
 		return true
 	}
 	return false
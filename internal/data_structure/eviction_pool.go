@@ -73,6 +73,4 @@ func newEpool(size int) *EvictionPool {
 	return &EvictionPool{
 		pool: make([]*EvictionCandidate, size),
 	}
-}
-
-var ePool *EvictionPool = newEpool(0)
\ No newline at end of file
+}
\ No newline at end of file
@@ -0,0 +1,191 @@
+package data_structure
+
+import (
+	"math/rand"
+	"slices"
+
+	"goredis-lite/internal/config"
+)
+
+const (
+	EncodingListpack  = "listpack"
+	EncodingHashtable = "hashtable"
+)
+
+// Hash maps fields to values. A small hash keeps its pairs in one slice, in
+// insertion order, like a Redis listpack: lookups scan it, which is faster
+// than hashing for a few short entries. It converts to a map once it grows
+// past config.HashMaxListpackEntries fields or stores a field or value longer
+// than config.HashMaxListpackValue bytes, and never converts back.
+type Hash struct {
+	pairs []string          // field, value, field, value... while compact
+	dict  map[string]string // nil while compact
+}
+
+func NewHash() *Hash {
+	return &Hash{}
+}
+
+// Encoding returns EncodingListpack while the hash is compact,
+// EncodingHashtable otherwise
+func (h *Hash) Encoding() string {
+	if h.dict == nil {
+		return EncodingListpack
+	}
+	return EncodingHashtable
+}
+
+func (h *Hash) Len() int {
+	if h.dict == nil {
+		return len(h.pairs) / 2
+	}
+	return len(h.dict)
+}
+
+// index returns the position of field in pairs, or -1
+func (h *Hash) index(field string) int {
+	for i := 0; i < len(h.pairs); i += 2 {
+		if h.pairs[i] == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Hash) convert() {
+	h.dict = make(map[string]string, len(h.pairs)/2)
+	for i := 0; i < len(h.pairs); i += 2 {
+		h.dict[h.pairs[i]] = h.pairs[i+1]
+	}
+	h.pairs = nil
+}
+
+func (h *Hash) Get(field string) (string, bool) {
+	if h.dict == nil {
+		if i := h.index(field); i >= 0 {
+			return h.pairs[i+1], true
+		}
+		return "", false
+	}
+	value, exist := h.dict[field]
+	return value, exist
+}
+
+// Set sets field to value and reports whether field is new
+func (h *Hash) Set(field, value string) bool {
+	if h.dict == nil {
+		fits := len(field) <= config.HashMaxListpackValue && len(value) <= config.HashMaxListpackValue
+		i := h.index(field)
+		switch {
+		case i >= 0 && fits:
+			h.pairs[i+1] = value
+			return false
+		case i < 0 && fits && h.Len() < config.HashMaxListpackEntries:
+			h.pairs = append(h.pairs, field, value)
+			return true
+		}
+		h.convert()
+	}
+	_, exist := h.dict[field]
+	h.dict[field] = value
+	return !exist
+}
+
+// Del removes field and reports whether it existed
+func (h *Hash) Del(field string) bool {
+	if h.dict == nil {
+		i := h.index(field)
+		if i < 0 {
+			return false
+		}
+		h.pairs = append(h.pairs[:i], h.pairs[i+2:]...)
+		return true
+	}
+	if _, exist := h.dict[field]; !exist {
+		return false
+	}
+	delete(h.dict, field)
+	return true
+}
+
+// Pairs returns field, value, field, value... in insertion order while the
+// hash is compact, in no particular order otherwise
+func (h *Hash) Pairs() []string {
+	if h.dict == nil {
+		return append([]string(nil), h.pairs...)
+	}
+	pairs := make([]string, 0, 2*len(h.dict))
+	for field, value := range h.dict {
+		pairs = append(pairs, field, value)
+	}
+	return pairs
+}
+
+// RandomPairs returns field, value, field, value... for count fields picked
+// at random without copying the hash: distinct ones, all of them when count
+// exceeds its size, unless repeat is set. A compact hash is read at random
+// indexes. A map has none, so its fields are picked in one pass.
+func (h *Hash) RandomPairs(count int, repeat bool) []string {
+	n := h.Len()
+	if !repeat {
+		count = min(count, n)
+	}
+	if n == 0 || count <= 0 {
+		return nil
+	}
+	res := make([]string, 2*count)
+	if h.dict == nil {
+		var picks []int
+		if repeat {
+			picks = make([]int, count)
+			for k := range picks {
+				picks[k] = rand.Intn(n)
+			}
+		} else {
+			picks = rand.Perm(n)[:count]
+		}
+		for k, i := range picks {
+			res[2*k], res[2*k+1] = h.pairs[2*i], h.pairs[2*i+1]
+		}
+		return res
+	}
+
+	if !repeat {
+		// selection sampling: the i-th field is kept with the probability
+		// that count-k of the n-i fields left are, then the order is shuffled
+		i, k := 0, 0
+		for field, value := range h.dict {
+			if rand.Intn(n-i) < count-k {
+				res[2*k], res[2*k+1] = field, value
+				if k++; k == count {
+					break
+				}
+			}
+			i++
+		}
+		rand.Shuffle(count, func(a, b int) {
+			res[2*a], res[2*b] = res[2*b], res[2*a]
+			res[2*a+1], res[2*b+1] = res[2*b+1], res[2*a+1]
+		})
+		return res
+	}
+
+	// the position of each pick, visited in order in one pass
+	pos := make([]int, count)
+	order := make([]int, count)
+	for k := range pos {
+		pos[k], order[k] = rand.Intn(n), k
+	}
+	slices.SortFunc(order, func(a, b int) int { return pos[a] - pos[b] })
+	i, k := 0, 0
+	for field, value := range h.dict {
+		for ; k < count && pos[order[k]] == i; k++ {
+			res[2*order[k]], res[2*order[k]+1] = field, value
+		}
+		if k == count {
+			break
+		}
+		i++
+	}
+	return res
+}
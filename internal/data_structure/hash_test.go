@@ -0,0 +1,84 @@
+package data_structure
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/config"
+)
+
+func TestHash_SetGetDel(t *testing.T) {
+	h := NewHash()
+	assert.True(t, h.Set("a", "1"))
+	assert.True(t, h.Set("b", "2"))
+	assert.False(t, h.Set("a", "3"))
+	assert.EqualValues(t, 2, h.Len())
+
+	v, ok := h.Get("a")
+	assert.True(t, ok)
+	assert.EqualValues(t, "3", v)
+	_, ok = h.Get("c")
+	assert.False(t, ok)
+
+	assert.EqualValues(t, []string{"a", "3", "b", "2"}, h.Pairs())
+	assert.True(t, h.Del("a"))
+	assert.False(t, h.Del("a"))
+	assert.EqualValues(t, []string{"b", "2"}, h.Pairs())
+	assert.EqualValues(t, EncodingListpack, h.Encoding())
+}
+
+func TestHash_ConvertOnEntries(t *testing.T) {
+	h := NewHash()
+	for i := 0; i < config.HashMaxListpackEntries; i++ {
+		h.Set(strconv.Itoa(i), "v")
+	}
+	assert.EqualValues(t, EncodingListpack, h.Encoding())
+	h.Set("one more", "v")
+	assert.EqualValues(t, EncodingHashtable, h.Encoding())
+	assert.EqualValues(t, config.HashMaxListpackEntries+1, h.Len())
+	v, ok := h.Get("0")
+	assert.True(t, ok)
+	assert.EqualValues(t, "v", v)
+}
+
+func TestHash_ConvertOnValueLength(t *testing.T) {
+	h := NewHash()
+	h.Set("a", "short")
+	h.Set("a", strings.Repeat("x", config.HashMaxListpackValue+1))
+	assert.EqualValues(t, EncodingHashtable, h.Encoding())
+	assert.EqualValues(t, 1, h.Len())
+	assert.EqualValues(t, 2, len(h.Pairs()))
+}
+
+func TestHash_RandomPairs(t *testing.T) {
+	for _, n := range []int{3, config.HashMaxListpackEntries + 1} {
+		h := NewHash()
+		for i := 0; i < n; i++ {
+			h.Set(strconv.Itoa(i), "v"+strconv.Itoa(i))
+		}
+
+		// distinct fields, each with its value
+		seen := map[string]bool{}
+		for i := 0; i < 1000; i++ {
+			pairs := h.RandomPairs(2, false)
+			assert.Len(t, pairs, 4, h.Encoding())
+			assert.NotEqual(t, pairs[0], pairs[2], h.Encoding())
+			for j := 0; j < len(pairs); j += 2 {
+				assert.Equal(t, "v"+pairs[j], pairs[j+1], h.Encoding())
+				seen[pairs[j]] = true
+			}
+		}
+		assert.Len(t, seen, n, h.Encoding())
+		assert.Len(t, h.RandomPairs(n+5, false), 2*n, h.Encoding())
+
+		// repeated fields, each with its value
+		pairs := h.RandomPairs(2*n, true)
+		assert.Len(t, pairs, 4*n, h.Encoding())
+		for j := 0; j < len(pairs); j += 2 {
+			assert.Equal(t, "v"+pairs[j], pairs[j+1], h.Encoding())
+		}
+	}
+	assert.Nil(t, NewHash().RandomPairs(3, true))
+}
@@ -0,0 +1,131 @@
+package data_structure
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+	"slices"
+)
+
+const hashTableMinBuckets = 4
+
+var hashTableSeed = maphash.MakeSeed()
+
+// hashTable is a chained hash table of strings with a power of 2 number of
+// buckets, which unlike a map can be iterated by a cursor the way SCAN
+// iterates a dict in Redis. It grows when it holds more strings than
+// buckets and shrinks when it holds less than one for 8 buckets.
+type hashTable struct {
+	buckets [][]string
+	n       int
+}
+
+func newHashTable(size int) *hashTable {
+	t := &hashTable{}
+	t.resize(size)
+	return t
+}
+
+// resize rehashes the strings into the smallest power of 2 number of
+// buckets holding size strings
+func (t *hashTable) resize(size int) {
+	numBuckets := hashTableMinBuckets
+	for numBuckets < size {
+		numBuckets *= 2
+	}
+	if numBuckets == len(t.buckets) {
+		return
+	}
+	old := t.buckets
+	t.buckets = make([][]string, numBuckets)
+	for _, bucket := range old {
+		for _, s := range bucket {
+			i := t.index(s)
+			t.buckets[i] = append(t.buckets[i], s)
+		}
+	}
+}
+
+func (t *hashTable) index(s string) uint64 {
+	return maphash.String(hashTableSeed, s) & uint64(len(t.buckets)-1)
+}
+
+func (t *hashTable) contains(s string) bool {
+	return slices.Contains(t.buckets[t.index(s)], s)
+}
+
+// add adds s and reports whether it was missing
+func (t *hashTable) add(s string) bool {
+	i := t.index(s)
+	if slices.Contains(t.buckets[i], s) {
+		return false
+	}
+	t.buckets[i] = append(t.buckets[i], s)
+	t.n++
+	if t.n > len(t.buckets) {
+		t.resize(t.n)
+	}
+	return true
+}
+
+// remove removes s and reports whether it was there
+func (t *hashTable) remove(s string) bool {
+	i := t.index(s)
+	j := slices.Index(t.buckets[i], s)
+	if j < 0 {
+		return false
+	}
+	t.buckets[i] = slices.Delete(t.buckets[i], j, j+1)
+	t.n--
+	if t.n < len(t.buckets)/8 {
+		t.resize(t.n)
+	}
+	return true
+}
+
+func (t *hashTable) len() int {
+	return t.n
+}
+
+// random returns a string of a random non-empty bucket, like
+// dictGetRandomKey in Redis: the table holding at least one string for 8
+// buckets, a few tries find one. It must not be empty.
+func (t *hashTable) random() string {
+	for {
+		if bucket := t.buckets[rand.Intn(len(t.buckets))]; len(bucket) > 0 {
+			return bucket[rand.Intn(len(bucket))]
+		}
+	}
+}
+
+// appendTo appends the strings to dst
+func (t *hashTable) appendTo(dst []string) []string {
+	for _, bucket := range t.buckets {
+		dst = append(dst, bucket...)
+	}
+	return dst
+}
+
+// scan appends to dst the strings of the buckets from cursor on, until
+// count strings or 10*count empty buckets were visited, and returns the
+// cursor of the next bucket, 0 once every bucket was visited. Like in
+// Redis, the cursor is incremented on its reversed bits, so that a string
+// present during the whole iteration is returned at least once even when
+// the table is resized between calls.
+func (t *hashTable) scan(cursor uint64, count int, dst []string) ([]string, uint64) {
+	mask := uint64(len(t.buckets) - 1)
+	for found, empty := 0, 0; found < count && empty < 10*count; {
+		bucket := t.buckets[cursor&mask]
+		if len(bucket) == 0 {
+			empty++
+		}
+		dst = append(dst, bucket...)
+		found += len(bucket)
+		cursor |= ^mask
+		cursor = bits.Reverse64(bits.Reverse64(cursor) + 1)
+		if cursor == 0 {
+			break
+		}
+	}
+	return dst, cursor
+}
@@ -0,0 +1,80 @@
+package data_structure
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashTable_AddRemove(t *testing.T) {
+	h := newHashTable(0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, h.add(strconv.Itoa(i)))
+	}
+	assert.False(t, h.add("7"))
+	assert.Equal(t, 100, h.len())
+	assert.Len(t, h.buckets, 128)
+	assert.True(t, h.contains("99"))
+	assert.False(t, h.contains("100"))
+
+	for i := 0; i < 95; i++ {
+		assert.True(t, h.remove(strconv.Itoa(i)))
+	}
+	assert.False(t, h.remove("0"))
+	assert.ElementsMatch(t, []string{"95", "96", "97", "98", "99"}, h.appendTo(nil))
+	// the table shrinks with the strings
+	assert.Len(t, h.buckets, 16)
+}
+
+func TestHashTable_Random(t *testing.T) {
+	h := newHashTable(0)
+	for i := 0; i < 100; i++ {
+		h.add(strconv.Itoa(i))
+	}
+	for i := 0; i < 95; i++ {
+		h.remove(strconv.Itoa(i))
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		seen[h.random()] = true
+	}
+	assert.Equal(t, map[string]bool{"95": true, "96": true, "97": true, "98": true, "99": true}, seen)
+}
+
+func TestHashTable_Scan(t *testing.T) {
+	h := newHashTable(0)
+	for i := 0; i < 1000; i++ {
+		h.add(strconv.Itoa(i))
+	}
+	seen := map[string]bool{}
+	var page []string
+	cursor, calls := uint64(0), 0
+	for {
+		page, cursor = h.scan(cursor, 10, page[:0])
+		assert.LessOrEqual(t, len(page), 30)
+		for _, s := range page {
+			seen[s] = true
+		}
+		// the table grows then shrinks during the iteration
+		switch calls++; calls {
+		case 10:
+			for i := 1000; i < 3000; i++ {
+				h.add(strconv.Itoa(i))
+			}
+		case 30:
+			for i := 1000; i < 3000; i++ {
+				h.remove(strconv.Itoa(i))
+			}
+			for i := 500; i < 1000; i++ {
+				h.remove(strconv.Itoa(i))
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 500; i++ {
+		assert.True(t, seen[strconv.Itoa(i)], i)
+	}
+}
@@ -0,0 +1,314 @@
+package data_structure
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spaolacci/murmur3"
+	"goredis-lite/internal/config"
+)
+
+const (
+	// HLLPrecision is the number of hash bits choosing a register, like in
+	// Redis: 16384 registers, for a standard error of 0.81%
+	HLLPrecision = 14
+	HLLRegisters = 1 << HLLPrecision
+	// hllQ is the number of hash bits left to compute the rank of an item
+	hllQ = 64 - HLLPrecision
+	// hllAlphaInf is 1 / (2 ln 2), the bias correction when m is infinite
+	hllAlphaInf = 0.721347520444481703680
+	hllSeed     = 0xadc83b19
+
+	hllMagic          = "HYLL"
+	hllDense          = 0
+	hllSparse         = 1
+	hllSparseEntryLen = 3 // bytes of an encoded sparse register
+	hllDenseLen       = HLLRegisters * 6 / 8
+)
+
+// ErrNotHLL is returned by UnmarshalBinary when data is not an encoded
+// HyperLogLog at all, other errors mean that it is corrupted
+var ErrNotHLL = errors.New("hll: missing HYLL header")
+
+// hllEntry is a register of a sparse HyperLogLog
+type hllEntry struct {
+	index uint16
+	rank  uint8
+}
+
+// HyperLogLog estimates the number of distinct items added to it. It starts
+// sparse, holding only the registers that are not 0, and becomes dense, one
+// byte per register, once its sparse encoding would take more than
+// config.HLLSparseMaxBytes.
+type HyperLogLog struct {
+	sparse []hllEntry // sorted by index, unused once dense
+	dense  []uint8    // nil while sparse
+	card   int64      // the cached Count, -1 when stale
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{}
+}
+
+// hllPos returns the register of item and the rank to store in it: the
+// position of the first 1 in the rest of its hash
+func hllPos(item string) (uint16, uint8) {
+	h := murmur3.Sum64WithSeed([]byte(item), hllSeed)
+	rest := h>>HLLPrecision | 1<<hllQ // the sentinel bounds the rank to hllQ+1
+	return uint16(h & (HLLRegisters - 1)), uint8(bits.TrailingZeros64(rest) + 1)
+}
+
+// IsSparse reports whether h uses the sparse representation
+func (h *HyperLogLog) IsSparse() bool {
+	return h.dense == nil
+}
+
+// ToDense moves h to the dense representation and reports whether it was
+// sparse
+func (h *HyperLogLog) ToDense() bool {
+	if h.dense != nil {
+		return false
+	}
+	h.dense = make([]uint8, HLLRegisters)
+	for _, e := range h.sparse {
+		h.dense[e.index] = e.rank
+	}
+	h.sparse = nil
+	return true
+}
+
+// setMax raises the register index to rank and reports whether it was lower
+func (h *HyperLogLog) setMax(index uint16, rank uint8) bool {
+	if h.dense != nil {
+		if h.dense[index] >= rank {
+			return false
+		}
+		h.dense[index] = rank
+		return true
+	}
+	i := sort.Search(len(h.sparse), func(i int) bool { return h.sparse[i].index >= index })
+	if i < len(h.sparse) && h.sparse[i].index == index {
+		if h.sparse[i].rank >= rank {
+			return false
+		}
+		h.sparse[i].rank = rank
+		return true
+	}
+	h.sparse = slices.Insert(h.sparse, i, hllEntry{index, rank})
+	if len(h.sparse)*hllSparseEntryLen > config.HLLSparseMaxBytes {
+		h.ToDense()
+	}
+	return true
+}
+
+// each calls fn with every register that is not 0
+func (h *HyperLogLog) each(fn func(index uint16, rank uint8)) {
+	if h.dense == nil {
+		for _, e := range h.sparse {
+			fn(e.index, e.rank)
+		}
+		return
+	}
+	for i, rank := range h.dense {
+		if rank != 0 {
+			fn(uint16(i), rank)
+		}
+	}
+}
+
+// Add adds items and reports whether a register changed, that is whether
+// the estimate may have changed
+func (h *HyperLogLog) Add(items ...string) bool {
+	changed := false
+	for _, item := range items {
+		if h.setMax(hllPos(item)) {
+			changed = true
+		}
+	}
+	if changed {
+		h.card = -1
+	}
+	return changed
+}
+
+// Merge makes h estimate the union of itself and other. h becomes dense
+// when other is.
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	if other.dense != nil {
+		h.ToDense()
+	}
+	changed := false
+	other.each(func(index uint16, rank uint8) {
+		if h.setMax(index, rank) {
+			changed = true
+		}
+	})
+	if changed {
+		h.card = -1
+	}
+}
+
+// Registers returns the value of every register
+func (h *HyperLogLog) Registers() []uint8 {
+	registers := make([]uint8, HLLRegisters)
+	h.each(func(index uint16, rank uint8) {
+		registers[index] = rank
+	})
+	return registers
+}
+
+// Count returns the estimated number of distinct items added, with the
+// estimator of Otmar Ertl that Redis uses, which needs no bias correction
+func (h *HyperLogLog) Count() int64 {
+	if h.card >= 0 {
+		return h.card
+	}
+	var histogram [hllQ + 2]int // number of registers holding each rank
+	histogram[0] = HLLRegisters
+	h.each(func(_ uint16, rank uint8) {
+		histogram[0]--
+		histogram[rank]++
+	})
+	m := float64(HLLRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	h.card = int64(math.Round(hllAlphaInf * m * m / z))
+	return h.card
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if z == prev {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == prev {
+			return z / 3
+		}
+	}
+}
+
+// Decode describes the registers of h with the opcodes of the sparse
+// encoding of Redis: Z:n and XZ:n for runs of n zeros, v:rank,n for runs
+// of up to 4 registers holding rank
+func (h *HyperLogLog) Decode() string {
+	var ops []string
+	registers := h.Registers()
+	for i := 0; i < len(registers); {
+		n := 1
+		for i+n < len(registers) && registers[i+n] == registers[i] {
+			n++
+		}
+		for remain := n; remain > 0; {
+			switch {
+			case registers[i] != 0:
+				run := min(remain, 4)
+				ops = append(ops, fmt.Sprintf("v:%d,%d", registers[i], run))
+				remain -= run
+			case remain > 64:
+				ops = append(ops, fmt.Sprintf("XZ:%d", remain))
+				remain = 0
+			default:
+				ops = append(ops, fmt.Sprintf("Z:%d", remain))
+				remain = 0
+			}
+		}
+		i += n
+	}
+	return strings.Join(ops, " ")
+}
+
+// MarshalBinary encodes h as the HYLL magic, its representation, then the
+// index and rank of each sparse register or every dense register packed
+// in 6 bits
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	buf := []byte(hllMagic)
+	if h.dense == nil {
+		buf = append(buf, hllSparse)
+		for _, e := range h.sparse {
+			buf = append(buf, byte(e.index>>8), byte(e.index), e.rank)
+		}
+		return buf, nil
+	}
+	buf = append(buf, hllDense)
+	packed := make([]byte, hllDenseLen)
+	for i, rank := range h.dense {
+		bit := i * 6
+		packed[bit/8] |= rank << (bit % 8)
+		if bit%8 > 2 {
+			packed[bit/8+1] |= rank >> (8 - bit%8)
+		}
+	}
+	return append(buf, packed...), nil
+}
+
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) <= len(hllMagic) || string(data[:len(hllMagic)]) != hllMagic {
+		return ErrNotHLL
+	}
+	body := data[len(hllMagic)+1:]
+	decoded := HyperLogLog{card: -1}
+	switch data[len(hllMagic)] {
+	case hllSparse:
+		if len(body)%hllSparseEntryLen != 0 {
+			return errors.New("hll: truncated sparse register")
+		}
+		for i := 0; i < len(body); i += hllSparseEntryLen {
+			e := hllEntry{uint16(body[i])<<8 | uint16(body[i+1]), body[i+2]}
+			if e.index >= HLLRegisters || e.rank == 0 || e.rank > hllQ+1 ||
+				(len(decoded.sparse) > 0 && e.index <= decoded.sparse[len(decoded.sparse)-1].index) {
+				return errors.New("hll: invalid sparse register")
+			}
+			decoded.sparse = append(decoded.sparse, e)
+		}
+	case hllDense:
+		if len(body) != hllDenseLen {
+			return errors.New("hll: dense registers size mismatch")
+		}
+		decoded.dense = make([]uint8, HLLRegisters)
+		for i := range decoded.dense {
+			bit := i * 6
+			rank := body[bit/8] >> (bit % 8)
+			if bit%8 > 2 {
+				rank |= body[bit/8+1] << (8 - bit%8)
+			}
+			if rank &= 63; rank > hllQ+1 {
+				return errors.New("hll: invalid dense register")
+			}
+			decoded.dense[i] = rank
+		}
+	default:
+		return errors.New("hll: unknown representation")
+	}
+	*h = decoded
+	return nil
+}
@@ -0,0 +1,88 @@
+package data_structure
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHyperLogLog_Count(t *testing.T) {
+	h := NewHyperLogLog()
+	assert.EqualValues(t, 0, h.Count())
+	assert.True(t, h.Add("a", "b", "c"))
+	assert.False(t, h.Add("a"))
+	assert.EqualValues(t, 3, h.Count())
+
+	for _, n := range []int{1000, 100000} {
+		h := NewHyperLogLog()
+		for i := 0; i < n; i++ {
+			h.Add(strconv.Itoa(i))
+		}
+		// 5 standard errors
+		assert.InDelta(t, n, h.Count(), float64(n)*0.0081*5, n)
+	}
+}
+
+func TestHyperLogLog_SparseToDense(t *testing.T) {
+	h := NewHyperLogLog()
+	for i := 0; len(h.sparse)*hllSparseEntryLen <= 3000-hllSparseEntryLen; i++ {
+		h.Add(strconv.Itoa(i))
+	}
+	assert.True(t, h.IsSparse())
+	before := h.Registers()
+	count := h.Count()
+
+	assert.True(t, h.ToDense())
+	assert.False(t, h.ToDense())
+	assert.Equal(t, before, h.Registers())
+	h.card = -1
+	assert.Equal(t, count, h.Count())
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a, b := NewHyperLogLog(), NewHyperLogLog()
+	for i := 0; i < 2000; i++ {
+		a.Add(strconv.Itoa(i))
+		b.Add(strconv.Itoa(i + 1000))
+	}
+	union := NewHyperLogLog()
+	union.Merge(a)
+	union.Merge(b)
+	assert.InDelta(t, 3000, union.Count(), 3000*0.0081*5)
+
+	small := NewHyperLogLog()
+	small.Add("x")
+	small.Merge(a)
+	assert.False(t, small.IsSparse())
+	assert.True(t, math.Abs(float64(small.Count()-a.Count())) <= 1)
+}
+
+func TestHyperLogLog_MarshalBinary(t *testing.T) {
+	sparse, dense := NewHyperLogLog(), NewHyperLogLog()
+	sparse.Add("a", "b")
+	for i := 0; i < 5000; i++ {
+		dense.Add(strconv.Itoa(i))
+	}
+	for _, h := range []*HyperLogLog{sparse, dense} {
+		data, err := h.MarshalBinary()
+		assert.NoError(t, err)
+		var decoded HyperLogLog
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, h.IsSparse(), decoded.IsSparse())
+		assert.Equal(t, h.Registers(), decoded.Registers())
+		assert.Equal(t, h.Count(), decoded.Count())
+		assert.Error(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+	}
+
+	var decoded HyperLogLog
+	assert.ErrorIs(t, decoded.UnmarshalBinary([]byte("hello")), ErrNotHLL)
+	assert.Equal(t, "Z:10 v:3,4 v:3,1 XZ:16369", func() string {
+		h := NewHyperLogLog()
+		for i := uint16(10); i < 15; i++ {
+			h.setMax(i, 3)
+		}
+		return h.Decode()
+	}())
+}
@@ -0,0 +1,333 @@
+package data_structure
+
+import "goredis-lite/internal/config"
+
+const EncodingQuicklist = "quicklist"
+
+type quicklistNode struct {
+	entries []string
+	prev    *quicklistNode
+	next    *quicklistNode
+}
+
+// List is a quicklist: a doubly linked list of nodes each holding up to
+// config.ListMaxListpackSize entries. Pushes and pops at both ends are O(1),
+// and a node keeps neighbouring entries together instead of paying a list
+// node per entry.
+type List struct {
+	head   *quicklistNode
+	tail   *quicklistNode
+	length int
+}
+
+func NewList() *List {
+	return &List{}
+}
+
+func (l *List) Len() int {
+	return l.length
+}
+
+// Encoding returns EncodingListpack while the list fits in one node,
+// EncodingQuicklist otherwise
+func (l *List) Encoding() string {
+	if l.head == l.tail {
+		return EncodingListpack
+	}
+	return EncodingQuicklist
+}
+
+func (l *List) PushHead(values ...string) {
+	for _, v := range values {
+		if l.head == nil || len(l.head.entries) >= config.ListMaxListpackSize {
+			l.insertNodeAfter(nil, &quicklistNode{})
+		}
+		l.head.entries = append(l.head.entries, "")
+		copy(l.head.entries[1:], l.head.entries)
+		l.head.entries[0] = v
+		l.length++
+	}
+}
+
+func (l *List) PushTail(values ...string) {
+	for _, v := range values {
+		if l.tail == nil || len(l.tail.entries) >= config.ListMaxListpackSize {
+			l.insertNodeAfter(l.tail, &quicklistNode{})
+		}
+		l.tail.entries = append(l.tail.entries, v)
+		l.length++
+	}
+}
+
+func (l *List) PopHead() (string, bool) {
+	if l.head == nil {
+		return "", false
+	}
+	v := l.head.entries[0]
+	l.removeAt(l.head, 0)
+	return v, true
+}
+
+func (l *List) PopTail() (string, bool) {
+	if l.tail == nil {
+		return "", false
+	}
+	v := l.tail.entries[len(l.tail.entries)-1]
+	l.removeAt(l.tail, len(l.tail.entries)-1)
+	return v, true
+}
+
+// insertNodeAfter links n after prev, or at the head when prev is nil
+func (l *List) insertNodeAfter(prev, n *quicklistNode) {
+	n.prev = prev
+	if prev == nil {
+		n.next = l.head
+		l.head = n
+	} else {
+		n.next = prev.next
+		prev.next = n
+	}
+	if n.next == nil {
+		l.tail = n
+	} else {
+		n.next.prev = n
+	}
+}
+
+func (l *List) unlinkNode(n *quicklistNode) {
+	if n.prev == nil {
+		l.head = n.next
+	} else {
+		n.prev.next = n.next
+	}
+	if n.next == nil {
+		l.tail = n.prev
+	} else {
+		n.next.prev = n.prev
+	}
+}
+
+// removeAt removes the entry at offset i of node n, and n once empty
+func (l *List) removeAt(n *quicklistNode, i int) {
+	n.entries = append(n.entries[:i], n.entries[i+1:]...)
+	l.length--
+	if len(n.entries) == 0 {
+		l.unlinkNode(n)
+	}
+}
+
+// insertAt inserts v at offset i of node n, splitting n when it is full
+func (l *List) insertAt(n *quicklistNode, i int, v string) {
+	if len(n.entries) >= config.ListMaxListpackSize {
+		half := len(n.entries) / 2
+		right := &quicklistNode{entries: append([]string(nil), n.entries[half:]...)}
+		n.entries = n.entries[:half]
+		l.insertNodeAfter(n, right)
+		if i > half {
+			n, i = right, i-half
+		}
+	}
+	n.entries = append(n.entries, "")
+	copy(n.entries[i+1:], n.entries[i:])
+	n.entries[i] = v
+	l.length++
+}
+
+// locate returns the node and offset of the entry at index 0 <= i < Len(),
+// walking from the nearest end
+func (l *List) locate(i int) (*quicklistNode, int) {
+	if i < l.length/2 {
+		for n := l.head; n != nil; n = n.next {
+			if i < len(n.entries) {
+				return n, i
+			}
+			i -= len(n.entries)
+		}
+		return nil, 0
+	}
+	i = l.length - 1 - i
+	for n := l.tail; n != nil; n = n.prev {
+		if i < len(n.entries) {
+			return n, len(n.entries) - 1 - i
+		}
+		i -= len(n.entries)
+	}
+	return nil, 0
+}
+
+// normalize turns a possibly negative index into an offset from the head,
+// reporting whether it is in range
+func (l *List) normalize(i int) (int, bool) {
+	if i < 0 {
+		i += l.length
+	}
+	return i, i >= 0 && i < l.length
+}
+
+// Index returns the entry at index i, negative indexes count from the tail
+func (l *List) Index(i int) (string, bool) {
+	i, ok := l.normalize(i)
+	if !ok {
+		return "", false
+	}
+	n, offset := l.locate(i)
+	return n.entries[offset], true
+}
+
+// Set replaces the entry at index i and reports whether i is in range
+func (l *List) Set(i int, v string) bool {
+	i, ok := l.normalize(i)
+	if !ok {
+		return false
+	}
+	n, offset := l.locate(i)
+	n.entries[offset] = v
+	return true
+}
+
+// rangeBounds clamps start and stop, inclusive and possibly negative, the way
+// LRANGE and LTRIM do; start > stop means an empty range
+func (l *List) rangeBounds(start, stop int) (int, int) {
+	if start < 0 {
+		start = max(start+l.length, 0)
+	}
+	if stop < 0 {
+		stop += l.length
+	}
+	return start, min(stop, l.length-1)
+}
+
+// Range returns the entries from start to stop, both inclusive
+func (l *List) Range(start, stop int) []string {
+	start, stop = l.rangeBounds(start, stop)
+	if start > stop {
+		return []string{}
+	}
+	res := make([]string, 0, stop-start+1)
+	n, offset := l.locate(start)
+	for ; n != nil && len(res) < cap(res); n, offset = n.next, 0 {
+		end := min(len(n.entries), offset+cap(res)-len(res))
+		res = append(res, n.entries[offset:end]...)
+	}
+	return res
+}
+
+// Trim keeps only the entries from start to stop, both inclusive
+func (l *List) Trim(start, stop int) {
+	start, stop = l.rangeBounds(start, stop)
+	if start > stop {
+		*l = List{}
+		return
+	}
+	for tail := l.length - 1 - stop; tail > 0; tail-- {
+		l.PopTail()
+	}
+	for ; start > 0; start-- {
+		l.PopHead()
+	}
+}
+
+// Insert inserts v before, or after, the first entry equal to pivot and
+// reports whether pivot was found
+func (l *List) Insert(pivot, v string, after bool) bool {
+	for n := l.head; n != nil; n = n.next {
+		for i, e := range n.entries {
+			if e != pivot {
+				continue
+			}
+			if after {
+				i++
+			}
+			l.insertAt(n, i, v)
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes the first count entries equal to v from the head, the last
+// -count from the tail when count is negative, all of them when it is 0
+func (l *List) Remove(count int, v string) int {
+	removed := 0
+	if count >= 0 {
+		for n := l.head; n != nil; {
+			next := n.next
+			for i := 0; i < len(n.entries); {
+				if n.entries[i] != v {
+					i++
+					continue
+				}
+				l.removeAt(n, i)
+				removed++
+				if removed == count {
+					return removed
+				}
+			}
+			n = next
+		}
+		return removed
+	}
+	for n := l.tail; n != nil; {
+		prev := n.prev
+		for i := len(n.entries) - 1; i >= 0; i-- {
+			if n.entries[i] != v {
+				continue
+			}
+			l.removeAt(n, i)
+			removed++
+			if removed == -count {
+				return removed
+			}
+		}
+		n = prev
+	}
+	return removed
+}
+
+// Pos returns the indexes of the entries equal to v, the way LPOS does: it
+// skips the first rank-1 matches, scanning from the tail when rank is
+// negative, stops after count matches unless count is 0 and compares at most
+// maxLen entries unless maxLen is 0
+func (l *List) Pos(v string, rank, count, maxLen int) []int {
+	var res []int
+	skip := max(rank, -rank) - 1
+	scanned := 0
+	if rank > 0 {
+		idx := 0
+		for n := l.head; n != nil; n = n.next {
+			for _, e := range n.entries {
+				if maxLen > 0 && scanned == maxLen {
+					return res
+				}
+				scanned++
+				if e == v {
+					if skip > 0 {
+						skip--
+					} else if res = append(res, idx); len(res) == count {
+						return res
+					}
+				}
+				idx++
+			}
+		}
+		return res
+	}
+	idx := l.length - 1
+	for n := l.tail; n != nil; n = n.prev {
+		for i := len(n.entries) - 1; i >= 0; i-- {
+			if maxLen > 0 && scanned == maxLen {
+				return res
+			}
+			scanned++
+			if n.entries[i] == v {
+				if skip > 0 {
+					skip--
+				} else if res = append(res, idx); len(res) == count {
+					return res
+				}
+			}
+			idx--
+		}
+	}
+	return res
+}
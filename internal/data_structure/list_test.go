@@ -0,0 +1,155 @@
+package data_structure
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/config"
+)
+
+// withSmallNodes makes lists split into nodes of 4 elements
+func withSmallNodes(t *testing.T) {
+	size := config.ListMaxListpackSize
+	config.ListMaxListpackSize = 4
+	t.Cleanup(func() { config.ListMaxListpackSize = size })
+}
+
+func TestList_PushPop(t *testing.T) {
+	withSmallNodes(t)
+	l := NewList()
+	l.PushTail("c", "d", "e")
+	l.PushHead("b", "a")
+	assert.EqualValues(t, 5, l.Len())
+	assert.EqualValues(t, EncodingQuicklist, l.Encoding())
+	assert.EqualValues(t, []string{"a", "b", "c", "d", "e"}, l.Range(0, -1))
+
+	v, ok := l.PopHead()
+	assert.True(t, ok)
+	assert.EqualValues(t, "a", v)
+	v, ok = l.PopTail()
+	assert.True(t, ok)
+	assert.EqualValues(t, "e", v)
+	assert.EqualValues(t, EncodingListpack, l.Encoding())
+	for l.Len() > 0 {
+		l.PopTail()
+	}
+	_, ok = l.PopHead()
+	assert.False(t, ok)
+}
+
+func TestList_IndexRange(t *testing.T) {
+	withSmallNodes(t)
+	l := NewList()
+	for i := 0; i < 10; i++ {
+		l.PushTail(strconv.Itoa(i))
+	}
+	v, ok := l.Index(7)
+	assert.True(t, ok)
+	assert.EqualValues(t, "7", v)
+	v, _ = l.Index(-1)
+	assert.EqualValues(t, "9", v)
+	_, ok = l.Index(10)
+	assert.False(t, ok)
+	_, ok = l.Index(-11)
+	assert.False(t, ok)
+
+	assert.EqualValues(t, []string{"3", "4", "5", "6"}, l.Range(3, 6))
+	assert.EqualValues(t, []string{"8", "9"}, l.Range(-2, 100))
+	assert.EqualValues(t, []string{"0", "1"}, l.Range(-100, 1))
+	assert.EqualValues(t, []string{}, l.Range(5, 2))
+
+	assert.True(t, l.Set(-2, "x"))
+	assert.False(t, l.Set(10, "x"))
+	v, _ = l.Index(8)
+	assert.EqualValues(t, "x", v)
+}
+
+func TestList_Trim(t *testing.T) {
+	withSmallNodes(t)
+	l := NewList()
+	l.PushTail("0", "1", "2", "3", "4", "5", "6", "7", "8", "9")
+	l.Trim(2, -3)
+	assert.EqualValues(t, []string{"2", "3", "4", "5", "6", "7"}, l.Range(0, -1))
+	l.Trim(4, 1)
+	assert.EqualValues(t, 0, l.Len())
+}
+
+func TestList_InsertRemove(t *testing.T) {
+	withSmallNodes(t)
+	l := NewList()
+	l.PushTail("a", "b", "a", "c", "a")
+	assert.True(t, l.Insert("b", "x", false))
+	assert.True(t, l.Insert("c", "y", true))
+	assert.False(t, l.Insert("z", "y", true))
+	assert.EqualValues(t, []string{"a", "x", "b", "a", "c", "y", "a"}, l.Range(0, -1))
+
+	assert.EqualValues(t, 1, l.Remove(-1, "a"))
+	assert.EqualValues(t, []string{"a", "x", "b", "a", "c", "y"}, l.Range(0, -1))
+	assert.EqualValues(t, 2, l.Remove(0, "a"))
+	assert.EqualValues(t, []string{"x", "b", "c", "y"}, l.Range(0, -1))
+	assert.EqualValues(t, 0, l.Remove(1, "a"))
+}
+
+func TestList_Pos(t *testing.T) {
+	withSmallNodes(t)
+	l := NewList()
+	l.PushTail("a", "b", "c", "1", "2", "3", "c", "c")
+	assert.EqualValues(t, []int{2}, l.Pos("c", 1, 1, 0))
+	assert.EqualValues(t, []int{6}, l.Pos("c", 2, 1, 0))
+	assert.EqualValues(t, []int{7, 6}, l.Pos("c", -1, 2, 0))
+	assert.EqualValues(t, []int{2, 6, 7}, l.Pos("c", 1, 0, 0))
+	assert.EqualValues(t, []int{2}, l.Pos("c", 1, 0, 4))
+	assert.Empty(t, l.Pos("x", 1, 0, 0))
+}
+
+// TestList_Random checks the quicklist against a plain slice
+func TestList_Random(t *testing.T) {
+	withSmallNodes(t)
+	l := NewList()
+	var model []string
+	for i := 0; i < 2000; i++ {
+		v := strconv.Itoa(rand.Intn(10))
+		switch rand.Intn(6) {
+		case 0:
+			l.PushHead(v)
+			model = append([]string{v}, model...)
+		case 1:
+			l.PushTail(v)
+			model = append(model, v)
+		case 2:
+			if len(model) > 0 {
+				l.PopHead()
+				model = model[1:]
+			}
+		case 3:
+			if len(model) > 0 {
+				idx := rand.Intn(len(model))
+				l.Insert(model[idx], v, false)
+				for j, e := range model {
+					if e == model[idx] {
+						model = append(model[:j], append([]string{v}, model[j:]...)...)
+						break
+					}
+				}
+			}
+		case 4:
+			l.Remove(1, v)
+			for j, e := range model {
+				if e == v {
+					model = append(model[:j], model[j+1:]...)
+					break
+				}
+			}
+		case 5:
+			if len(model) > 0 {
+				idx := rand.Intn(len(model))
+				got, _ := l.Index(idx)
+				assert.EqualValues(t, model[idx], got)
+			}
+		}
+		assert.EqualValues(t, len(model), l.Len())
+	}
+	assert.EqualValues(t, append([]string{}, model...), l.Range(0, -1))
+}
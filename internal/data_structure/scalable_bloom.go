@@ -0,0 +1,291 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// BloomErrorTighteningRatio scales the error rate of each new layer so that
+// the error rates of the chain sum to less than the one asked for
+const BloomErrorTighteningRatio = 0.5
+
+// BloomMaxExpansion bounds the expansion of a filter, like the one of a
+// cuckoo filter
+const BloomMaxExpansion = 32768
+
+var (
+	// ErrBloomFull is returned when adding a new item to a non scaling
+	// filter holding its capacity
+	ErrBloomFull = errors.New("non scaling filter is full")
+	// ErrBloomTooLarge is returned when the layer a filter needs would make
+	// its bit arrays larger than BloomMaxBytes
+	ErrBloomTooLarge = errors.New("filter would exceed the maximum size")
+)
+
+// ScalableBloom is a bloom filter that grows like the ones of RedisBloom: a
+// chain of Bloom layers, the last one taking the new items. Once it holds
+// its capacity, a layer Expansion times larger and with an error rate
+// tightened by BloomErrorTighteningRatio is added, so that the false
+// positive rate stays below Error however many items are added.
+type ScalableBloom struct {
+	Error     float64 // the error rate asked for
+	Expansion uint64  // 0 for a non scaling filter
+	layers    []*Bloom
+	counts    []uint64 // items added to each layer
+}
+
+// NewScalableBloom returns a filter holding capacity items in its first
+// layer, non scaling when expansion is 0. It fails with ErrBloomTooLarge
+// when that layer is larger than BloomMaxBytes.
+func NewScalableBloom(capacity uint64, errorRate float64, expansion uint64) (*ScalableBloom, error) {
+	b := &ScalableBloom{Error: errorRate, Expansion: expansion}
+	if err := b.addLayer(capacity, errorRate*BloomErrorTighteningRatio); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// NewScalableBloomFromLayer returns a filter whose first layer is layer, an
+// item count unknown to it: the layer is seen as full.
+func NewScalableBloomFromLayer(layer *Bloom, expansion uint64) *ScalableBloom {
+	return &ScalableBloom{
+		Error:     layer.Error,
+		Expansion: expansion,
+		layers:    []*Bloom{layer},
+		counts:    []uint64{layer.Entries},
+	}
+}
+
+// addLayer adds a layer holding capacity items, unless the bit arrays of b
+// would exceed BloomMaxBytes
+func (b *ScalableBloom) addLayer(capacity uint64, errorRate float64) error {
+	size, ok := bloomBytes(capacity, errorRate)
+	if !ok || b.Bits()/8+size > BloomMaxBytes {
+		return ErrBloomTooLarge
+	}
+	b.layers = append(b.layers, CreateBloomFilter(capacity, errorRate))
+	b.counts = append(b.counts, 0)
+	return nil
+}
+
+// Exist reports whether item was probably added
+func (b *ScalableBloom) Exist(item string) bool {
+	return b.existHash(b.layers[0].CalcHash(item))
+}
+
+func (b *ScalableBloom) existHash(hash HashValue) bool {
+	// the last layers are the largest, so the most likely to hold item
+	for i := len(b.layers) - 1; i >= 0; i-- {
+		if b.layers[i].ExistHash(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds item and reports whether it is new, false meaning that it was
+// probably added before
+func (b *ScalableBloom) Add(item string) (bool, error) {
+	hash := b.layers[0].CalcHash(item)
+	if b.existHash(hash) {
+		return false, nil
+	}
+	last := len(b.layers) - 1
+	if b.counts[last] >= b.layers[last].Entries {
+		if b.Expansion == 0 {
+			return false, ErrBloomFull
+		}
+		hi, capacity := bits.Mul64(b.layers[last].Entries, b.Expansion)
+		if hi != 0 {
+			return false, ErrBloomTooLarge
+		}
+		if err := b.addLayer(capacity, b.layers[last].Error*BloomErrorTighteningRatio); err != nil {
+			return false, err
+		}
+		last++
+	}
+	b.layers[last].AddHash(hash)
+	b.counts[last]++
+	return true, nil
+}
+
+// Count returns the number of items added
+func (b *ScalableBloom) Count() uint64 {
+	var n uint64
+	for _, count := range b.counts {
+		n += count
+	}
+	return n
+}
+
+// Capacity returns the number of items the layers hold before a new one is
+// needed
+func (b *ScalableBloom) Capacity() uint64 {
+	var n uint64
+	for _, layer := range b.layers {
+		n += layer.Entries
+	}
+	return n
+}
+
+// Layers returns the number of layers of the chain
+func (b *ScalableBloom) Layers() int {
+	return len(b.layers)
+}
+
+// MarshalBinary encodes the error rate, the expansion and the number of
+// layers, followed by the item count and the encoding of each layer
+func (b *ScalableBloom) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 24)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(b.Error))
+	buf = binary.LittleEndian.AppendUint64(buf, b.Expansion)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(b.layers)))
+	for i, layer := range b.layers {
+		data, _ := layer.MarshalBinary()
+		buf = binary.LittleEndian.AppendUint64(buf, b.counts[i])
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(data)))
+		buf = append(buf, data...)
+	}
+	return buf, nil
+}
+
+func (b *ScalableBloom) UnmarshalBinary(data []byte) error {
+	if len(data) < 24 {
+		return errors.New("bloom: encoded chain too short")
+	}
+	decoded := ScalableBloom{
+		Error:     math.Float64frombits(binary.LittleEndian.Uint64(data)),
+		Expansion: binary.LittleEndian.Uint64(data[8:]),
+	}
+	if decoded.Expansion > BloomMaxExpansion {
+		return errors.New("bloom: invalid expansion")
+	}
+	n := binary.LittleEndian.Uint64(data[16:])
+	data = data[24:]
+	if n == 0 {
+		return errors.New("bloom: chain without layers")
+	}
+	for i := uint64(0); i < n; i++ {
+		if len(data) < 16 {
+			return errors.New("bloom: truncated layer")
+		}
+		count, size := binary.LittleEndian.Uint64(data), binary.LittleEndian.Uint64(data[8:])
+		if uint64(len(data)-16) < size {
+			return errors.New("bloom: truncated layer")
+		}
+		layer := &Bloom{}
+		if err := layer.UnmarshalBinary(data[16 : 16+size]); err != nil {
+			return err
+		}
+		decoded.layers = append(decoded.layers, layer)
+		decoded.counts = append(decoded.counts, count)
+		data = data[16+size:]
+	}
+	if len(data) != 0 {
+		return errors.New("bloom: trailing bytes after the last layer")
+	}
+	*b = decoded
+	return nil
+}
+
+// Hashes returns the number of hash functions of the last layer
+func (b *ScalableBloom) Hashes() int {
+	return b.layers[len(b.layers)-1].Hashes
+}
+
+// Bits returns the size of the bit arrays of the layers, in bits
+func (b *ScalableBloom) Bits() uint64 {
+	var n uint64
+	for _, layer := range b.layers {
+		n += layer.bits
+	}
+	return n
+}
+
+// MarshalHeader encodes b without the bit arrays of its layers, which
+// Chunk returns
+func (b *ScalableBloom) MarshalHeader() []byte {
+	buf := make([]byte, 0, 24+24*len(b.layers))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(b.Error))
+	buf = binary.LittleEndian.AppendUint64(buf, b.Expansion)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(b.layers)))
+	for i, layer := range b.layers {
+		buf = binary.LittleEndian.AppendUint64(buf, b.counts[i])
+		buf = binary.LittleEndian.AppendUint64(buf, layer.Entries)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(layer.Error))
+	}
+	return buf
+}
+
+// UnmarshalHeader decodes the output of MarshalHeader into b, the bit
+// arrays of its layers being empty until LoadChunk fills them
+func (b *ScalableBloom) UnmarshalHeader(data []byte) error {
+	if len(data) < 24 || (len(data)-24)%24 != 0 {
+		return errors.New("bloom: bad header size")
+	}
+	decoded := ScalableBloom{
+		Error:     math.Float64frombits(binary.LittleEndian.Uint64(data)),
+		Expansion: binary.LittleEndian.Uint64(data[8:]),
+	}
+	if decoded.Expansion > BloomMaxExpansion {
+		return errors.New("bloom: invalid expansion")
+	}
+	n := binary.LittleEndian.Uint64(data[16:])
+	if n == 0 || n != uint64(len(data)-24)/24 {
+		return errors.New("bloom: bad number of layers")
+	}
+	for data = data[24:]; len(data) > 0; data = data[24:] {
+		entries := binary.LittleEndian.Uint64(data[8:])
+		errorRate := math.Float64frombits(binary.LittleEndian.Uint64(data[16:]))
+		if entries == 0 || !(errorRate > 0 && errorRate < 1) {
+			return errors.New("bloom: invalid layer")
+		}
+		if err := decoded.addLayer(entries, errorRate); err != nil {
+			return err
+		}
+		decoded.counts[len(decoded.counts)-1] = binary.LittleEndian.Uint64(data)
+	}
+	*b = decoded
+	return nil
+}
+
+// Chunk returns up to size bytes of the bit arrays of the layers, seen as
+// a single array, from offset. It is empty past the end.
+func (b *ScalableBloom) Chunk(offset uint64, size int) []byte {
+	var chunk []byte
+	for _, layer := range b.layers {
+		if offset >= layer.bytes {
+			offset -= layer.bytes
+			continue
+		}
+		end := min(offset+uint64(size-len(chunk)), layer.bytes)
+		chunk = append(chunk, layer.bf[offset:end]...)
+		if len(chunk) == size {
+			break
+		}
+		offset = 0
+	}
+	return chunk
+}
+
+// LoadChunk copies data, a chunk returned by Chunk, back at offset
+func (b *ScalableBloom) LoadChunk(offset uint64, data []byte) error {
+	for _, layer := range b.layers {
+		if len(data) == 0 {
+			return nil
+		}
+		if offset >= layer.bytes {
+			offset -= layer.bytes
+			continue
+		}
+		n := copy(layer.bf[offset:], data)
+		data = data[n:]
+		offset = 0
+	}
+	if len(data) != 0 {
+		return errors.New("bloom: chunk past the end of the filter")
+	}
+	return nil
+}
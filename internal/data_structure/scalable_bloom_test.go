@@ -0,0 +1,161 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScalableBloom_Add(t *testing.T) {
+	b, _ := NewScalableBloom(100, 0.01, 2)
+	added, err := b.Add("a")
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, _ = b.Add("a")
+	assert.False(t, added)
+	assert.True(t, b.Exist("a"))
+	assert.False(t, b.Exist("b"))
+	assert.EqualValues(t, 1, b.Count())
+}
+
+func TestScalableBloom_Scaling(t *testing.T) {
+	b, _ := NewScalableBloom(100, 0.01, 2)
+	for i := 0; i < 1000; i++ {
+		b.Add(strconv.Itoa(i))
+	}
+	// 100 + 200 + 400 + 800
+	assert.Equal(t, 4, b.Layers())
+	assert.EqualValues(t, 1500, b.Capacity())
+	assert.InDelta(t, 0.01*0.5*0.5*0.5*0.5, b.layers[3].Error, 1e-12)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, b.Exist(strconv.Itoa(i)))
+	}
+
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if b.Exist(strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 10000/100)
+}
+
+func TestScalableBloom_NonScaling(t *testing.T) {
+	b, _ := NewScalableBloom(10, 0.01, 0)
+	for i := 0; i < 10; i++ {
+		_, err := b.Add(strconv.Itoa(i))
+		assert.NoError(t, err)
+	}
+	_, err := b.Add("new")
+	assert.ErrorIs(t, err, ErrBloomFull)
+	added, err := b.Add("0")
+	assert.NoError(t, err)
+	assert.False(t, added)
+	assert.Equal(t, 1, b.Layers())
+}
+
+func TestScalableBloom_MarshalBinary(t *testing.T) {
+	b, _ := NewScalableBloom(10, 0.01, 4)
+	for i := 0; i < 30; i++ {
+		b.Add(strconv.Itoa(i))
+	}
+	data, err := b.MarshalBinary()
+	assert.NoError(t, err)
+
+	var decoded ScalableBloom
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, b.Layers(), decoded.Layers())
+	assert.Equal(t, b.Count(), decoded.Count())
+	assert.EqualValues(t, 4, decoded.Expansion)
+	assert.True(t, decoded.Exist("29"))
+	assert.Error(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+
+	legacy := NewScalableBloomFromLayer(CreateBloomFilter(10, 0.01), 2)
+	added, _ := legacy.Add("a")
+	assert.True(t, added)
+	assert.Equal(t, 2, legacy.Layers())
+}
+
+func TestScalableBloom_Chunks(t *testing.T) {
+	b, _ := NewScalableBloom(10, 0.01, 2)
+	for i := 0; i < 50; i++ {
+		b.Add(strconv.Itoa(i))
+	}
+	var copied ScalableBloom
+	assert.NoError(t, copied.UnmarshalHeader(b.MarshalHeader()))
+	assert.Equal(t, b.Layers(), copied.Layers())
+	assert.Equal(t, b.Bits(), copied.Bits())
+	assert.False(t, copied.Exist("0"))
+
+	// chunks of 7 bytes span the layers
+	offset := uint64(0)
+	for chunk := b.Chunk(0, 7); len(chunk) > 0; chunk = b.Chunk(offset, 7) {
+		assert.NoError(t, copied.LoadChunk(offset, chunk))
+		offset += uint64(len(chunk))
+	}
+	assert.Equal(t, b.Bits()/8, offset)
+	assert.Equal(t, b.Count(), copied.Count())
+	for i := 0; i < 50; i++ {
+		assert.True(t, copied.Exist(strconv.Itoa(i)))
+	}
+	assert.Error(t, copied.LoadChunk(offset-1, []byte{0, 0}))
+	assert.Error(t, copied.UnmarshalHeader(b.MarshalHeader()[:30]))
+}
+
+func TestScalableBloom_TooLarge(t *testing.T) {
+	_, err := NewScalableBloom(math.MaxUint64, 0.01, 2)
+	assert.ErrorIs(t, err, ErrBloomTooLarge)
+	_, err = NewScalableBloom(1<<40, 1e-300, 2)
+	assert.ErrorIs(t, err, ErrBloomTooLarge)
+
+	// the capacity of the next layer would wrap around
+	b, err := NewScalableBloom(2, 0.01, math.MaxUint64/2+1)
+	assert.NoError(t, err)
+	b.Add("a")
+	b.Add("b")
+	_, err = b.Add("c")
+	assert.ErrorIs(t, err, ErrBloomTooLarge)
+	assert.Equal(t, 1, b.Layers())
+
+	// the next layer would exceed BloomMaxBytes
+	b, _ = NewScalableBloom(1, 0.01, BloomMaxExpansion)
+	for i := 0; ; i++ {
+		if _, err = b.Add(strconv.Itoa(i)); err != nil {
+			break
+		}
+	}
+	assert.ErrorIs(t, err, ErrBloomTooLarge)
+	assert.LessOrEqual(t, b.Bits()/8, uint64(BloomMaxBytes))
+	assert.True(t, b.Exist("0"))
+
+	// a tiny layer still gets a bit array
+	b, _ = NewScalableBloom(1, 0.9, 2)
+	added, err := b.Add("a")
+	assert.NoError(t, err)
+	assert.True(t, added)
+}
+
+func TestScalableBloom_UnmarshalHeaderBounds(t *testing.T) {
+	b, _ := NewScalableBloom(10, 0.01, 2)
+	header := b.MarshalHeader()
+	var decoded ScalableBloom
+
+	tooLarge := slices.Clone(header)
+	binary.LittleEndian.PutUint64(tooLarge[32:], math.MaxUint64)
+	assert.Error(t, decoded.UnmarshalHeader(tooLarge))
+
+	badRate := slices.Clone(header)
+	binary.LittleEndian.PutUint64(badRate[40:], math.Float64bits(math.NaN()))
+	assert.Error(t, decoded.UnmarshalHeader(badRate))
+
+	badExpansion := slices.Clone(header)
+	binary.LittleEndian.PutUint64(badExpansion[8:], BloomMaxExpansion+1)
+	assert.Error(t, decoded.UnmarshalHeader(badExpansion))
+
+	assert.NoError(t, decoded.UnmarshalHeader(header))
+	assert.Equal(t, b.Bits(), decoded.Bits())
+}
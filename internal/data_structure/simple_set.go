@@ -1,51 +1,207 @@
 package data_structure
 
+import (
+	"math/rand"
+	"slices"
+	"strconv"
+
+	"goredis-lite/internal/config"
+)
+
+const EncodingIntset = "intset"
+
+// SimpleSet is a set of strings. Like in Redis, a set starts compact: a
+// sorted array of integers while every member is an integer, then an
+// unordered slice scanned on lookups. It converts to a map once it grows
+// past config.SetMaxIntsetEntries integers, config.SetMaxListpackEntries
+// members or stores a member longer than config.SetMaxListpackValue bytes,
+// and never converts back.
 type SimpleSet struct {
-	key  string
-	dict map[string]struct{}
+	key      string
+	encoding string
+	ints     []int64    // sorted, while EncodingIntset
+	list     []string   // while EncodingListpack
+	dict     *hashTable // while EncodingHashtable
 }
 
 func NewSimpleSet(key string) *SimpleSet {
 	return &SimpleSet{
-		key:  key,
-		dict: make(map[string]struct{}),
+		key:      key,
+		encoding: EncodingIntset,
+	}
+}
+
+// Encoding returns EncodingIntset, EncodingListpack or EncodingHashtable
+func (s *SimpleSet) Encoding() string {
+	return s.encoding
+}
+
+// parseInt returns the integer member is the canonical form of, like "12"
+// but not "012" or "+12"
+func parseInt(member string) (int64, bool) {
+	n, err := strconv.ParseInt(member, 10, 64)
+	return n, err == nil && strconv.FormatInt(n, 10) == member
+}
+
+// convert moves the members to a slice, or to a map when they don't fit one
+func (s *SimpleSet) convert(encoding string) {
+	members := s.Members()
+	s.ints, s.list = nil, nil
+	s.encoding = encoding
+	if encoding == EncodingListpack {
+		s.list = members
+		return
+	}
+	s.dict = newHashTable(len(members))
+	for _, m := range members {
+		s.dict.add(m)
+	}
+}
+
+// fitsListpack reports whether the set can stay a listpack with member added
+func (s *SimpleSet) fitsListpack(member string) bool {
+	return s.Len() < config.SetMaxListpackEntries && len(member) <= config.SetMaxListpackValue
+}
+
+func (s *SimpleSet) add(member string) bool {
+	switch s.encoding {
+	case EncodingIntset:
+		n, ok := parseInt(member)
+		if ok {
+			i, found := slices.BinarySearch(s.ints, n)
+			if found {
+				return false
+			}
+			if len(s.ints) < config.SetMaxIntsetEntries {
+				s.ints = slices.Insert(s.ints, i, n)
+				return true
+			}
+			s.convert(EncodingHashtable)
+		} else if s.fitsListpack(member) {
+			s.convert(EncodingListpack)
+		} else {
+			s.convert(EncodingHashtable)
+		}
+		return s.add(member)
+	case EncodingListpack:
+		if slices.Contains(s.list, member) {
+			return false
+		}
+		if s.fitsListpack(member) {
+			s.list = append(s.list, member)
+			return true
+		}
+		s.convert(EncodingHashtable)
 	}
+	return s.dict.add(member)
 }
 
 func (s *SimpleSet) Add(members ...string) int {
 	added := 0
 	for _, m := range members {
-		if _, exist := s.dict[m]; !exist {
-			s.dict[m] = struct{}{}
+		if s.add(m) {
 			added++
 		}
 	}
 	return added
 }
 
+func (s *SimpleSet) rem(member string) bool {
+	switch s.encoding {
+	case EncodingIntset:
+		n, ok := parseInt(member)
+		if !ok {
+			return false
+		}
+		i, found := slices.BinarySearch(s.ints, n)
+		if found {
+			s.ints = slices.Delete(s.ints, i, i+1)
+		}
+		return found
+	case EncodingListpack:
+		i := slices.Index(s.list, member)
+		if i >= 0 {
+			s.list = slices.Delete(s.list, i, i+1)
+		}
+		return i >= 0
+	}
+	return s.dict.remove(member)
+}
+
 func (s *SimpleSet) Rem(members ...string) int {
 	removed := 0
 	for _, m := range members {
-		if _, exist := s.dict[m]; exist {
-			delete(s.dict, m)
+		if s.rem(m) {
 			removed++
 		}
 	}
 	return removed
 }
 
+func (s *SimpleSet) Len() int {
+	switch s.encoding {
+	case EncodingIntset:
+		return len(s.ints)
+	case EncodingListpack:
+		return len(s.list)
+	}
+	return s.dict.len()
+}
+
 func (s *SimpleSet) IsMember(member string) int {
-	_, exist := s.dict[member]
+	var exist bool
+	switch s.encoding {
+	case EncodingIntset:
+		if n, ok := parseInt(member); ok {
+			_, exist = slices.BinarySearch(s.ints, n)
+		}
+	case EncodingListpack:
+		exist = slices.Contains(s.list, member)
+	default:
+		exist = s.dict.contains(member)
+	}
 	if exist {
 		return 1
 	}
 	return 0
 }
 
+// RandomMember returns a member picked at random without copying the set:
+// at a random index of a compact set, in a random bucket of a hashtable. The
+// set must not be empty.
+func (s *SimpleSet) RandomMember() string {
+	switch s.encoding {
+	case EncodingIntset:
+		return strconv.FormatInt(s.ints[rand.Intn(len(s.ints))], 10)
+	case EncodingListpack:
+		return s.list[rand.Intn(len(s.list))]
+	}
+	return s.dict.random()
+}
+
+// Members returns a new slice of the members, sorted while the set is an
+// intset, in insertion order while it is a listpack
 func (s *SimpleSet) Members() []string {
-	m := make([]string, 0, len(s.dict))
-	for k, _ := range s.dict {
-		m = append(m, k)
+	switch s.encoding {
+	case EncodingIntset:
+		m := make([]string, len(s.ints))
+		for i, n := range s.ints {
+			m[i] = strconv.FormatInt(n, 10)
+		}
+		return m
+	case EncodingListpack:
+		return slices.Clone(s.list)
 	}
-	return m
-}
\ No newline at end of file
+	return s.dict.appendTo(make([]string, 0, s.dict.len()))
+}
+
+// Scan returns members from cursor on and the cursor of the next call, 0
+// once every member was returned. Like in Redis, a compact set is returned
+// whole while a hashtable is returned about count members at a time, see
+// hashTable.scan.
+func (s *SimpleSet) Scan(cursor uint64, count int) ([]string, uint64) {
+	if s.encoding != EncodingHashtable {
+		return s.Members(), 0
+	}
+	return s.dict.scan(cursor, count, nil)
+}
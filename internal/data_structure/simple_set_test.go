@@ -0,0 +1,80 @@
+package data_structure
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/config"
+)
+
+func TestSimpleSet_Intset(t *testing.T) {
+	s := NewSimpleSet("s")
+	assert.EqualValues(t, 3, s.Add("3", "-1", "20", "3"))
+	assert.EqualValues(t, EncodingIntset, s.Encoding())
+	assert.EqualValues(t, []string{"-1", "3", "20"}, s.Members())
+	assert.EqualValues(t, 1, s.IsMember("20"))
+	assert.EqualValues(t, 0, s.IsMember("020"))
+	assert.EqualValues(t, 0, s.Rem("x", "4"))
+	assert.EqualValues(t, 1, s.Rem("-1"))
+	assert.EqualValues(t, 2, s.Len())
+
+	// "07" is not the canonical form of an integer
+	s.Add("07")
+	assert.EqualValues(t, EncodingListpack, s.Encoding())
+	assert.EqualValues(t, []string{"3", "20", "07"}, s.Members())
+	assert.EqualValues(t, 1, s.IsMember("3"))
+	assert.EqualValues(t, 0, s.IsMember("7"))
+}
+
+func TestSimpleSet_ConvertOnEntries(t *testing.T) {
+	s := NewSimpleSet("s")
+	for i := 0; i < config.SetMaxIntsetEntries; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	assert.EqualValues(t, EncodingIntset, s.Encoding())
+	s.Add("-1")
+	assert.EqualValues(t, EncodingHashtable, s.Encoding())
+	assert.EqualValues(t, config.SetMaxIntsetEntries+1, s.Len())
+
+	s = NewSimpleSet("s")
+	for i := 0; i < config.SetMaxListpackEntries; i++ {
+		s.Add("m" + strconv.Itoa(i))
+	}
+	assert.EqualValues(t, EncodingListpack, s.Encoding())
+	s.Add("one more")
+	assert.EqualValues(t, EncodingHashtable, s.Encoding())
+	assert.EqualValues(t, 1, s.IsMember("m0"))
+}
+
+func TestSimpleSet_ConvertOnValueLength(t *testing.T) {
+	s := NewSimpleSet("s")
+	s.Add("1")
+	s.Add(strings.Repeat("x", config.SetMaxListpackValue+1))
+	assert.EqualValues(t, EncodingHashtable, s.Encoding())
+	members := s.Members()
+	sort.Strings(members)
+	assert.EqualValues(t, []string{"1", strings.Repeat("x", config.SetMaxListpackValue+1)}, members)
+	assert.EqualValues(t, 2, s.Rem(members...))
+	assert.EqualValues(t, 0, s.Len())
+}
+
+func TestSimpleSet_RandomMember(t *testing.T) {
+	for _, members := range [][]string{
+		{"1", "2", "3"}, // intset
+		{"a", "b", "c"}, // listpack
+		{"a", "b", strings.Repeat("x", config.SetMaxListpackValue+1)}, // hashtable
+	} {
+		s := NewSimpleSet("s")
+		s.Add(members...)
+		seen := map[string]bool{}
+		for i := 0; i < 1000; i++ {
+			member := s.RandomMember()
+			assert.Contains(t, members, member, s.Encoding())
+			seen[member] = true
+		}
+		assert.Len(t, seen, 3, s.Encoding())
+	}
+}
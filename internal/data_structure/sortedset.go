@@ -1,25 +1,230 @@
 package data_structure
 
+import (
+	"slices"
+
+	"goredis-lite/internal/config"
+)
+
+// EncodingSkiplist is reported for a sorted set held in a BPlusTree, under
+// the name Redis gives its non compact encoding
+const EncodingSkiplist = "skiplist"
+
+// SortedSet orders members by score, then member. A small sorted set keeps
+// its items in one sorted slice, like a Redis listpack, and moves them to a
+// BPlusTree once it grows past config.ZSetMaxListpackEntries members or
+// stores a member longer than config.ZSetMaxListpackValue bytes. It never
+// converts back.
 type SortedSet struct {
-	Tree         *BPlusTree
-	MemberScores map[string]float64
+	Tree   *BPlusTree // nil while compact
+	items  []Item     // sorted, while compact
+	degree int
+}
+
+// ScoreBound is one end of a score range, Exclusive for "(score"
+type ScoreBound struct {
+	Score     float64
+	Exclusive bool
+}
+
+// LexBound is one end of a member range: Inf is -1 for "-", 1 for "+", 0 when
+// the bound is Member, exclusive for "(member" and inclusive for "[member"
+type LexBound struct {
+	Member    string
+	Exclusive bool
+	Inf       int
 }
 
 func NewSortedSet(degree int) *SortedSet {
-	return &SortedSet{
-		Tree:         NewBPlusTree(degree),
-		MemberScores: make(map[string]float64),
+	return &SortedSet{degree: degree}
+}
+
+// Encoding returns EncodingListpack while the sorted set is compact,
+// EncodingSkiplist otherwise
+func (ss *SortedSet) Encoding() string {
+	if ss.Tree == nil {
+		return EncodingListpack
 	}
+	return EncodingSkiplist
+}
+
+// index returns the position of member in items, or -1
+func (ss *SortedSet) index(member string) int {
+	return slices.IndexFunc(ss.items, func(item Item) bool { return item.Member == member })
 }
 
+func (ss *SortedSet) convert() {
+	ss.Tree = NewBPlusTree(ss.degree)
+	for _, item := range ss.items {
+		ss.Tree.Add(item.Score, item.Member)
+	}
+	ss.items = nil
+}
+
+// Add returns AddIgnored, AddNew, AddUpdated or AddUnchanged like
+// BPlusTree.Add
 func (ss *SortedSet) Add(score float64, member string) int {
+	if ss.Tree == nil {
+		if len(member) == 0 {
+			return AddIgnored
+		}
+		i := ss.index(member)
+		switch {
+		case i >= 0 && ss.items[i].Score == score:
+			return AddUnchanged
+		case i >= 0:
+			ss.items = slices.Delete(ss.items, i, i+1)
+			ss.insert(Item{Score: score, Member: member})
+			return AddUpdated
+		case len(ss.items) < config.ZSetMaxListpackEntries && len(member) <= config.ZSetMaxListpackValue:
+			ss.insert(Item{Score: score, Member: member})
+			return AddNew
+		}
+		ss.convert()
+	}
 	return ss.Tree.Add(score, member)
 }
 
+// insert adds item to items, keeping them sorted
+func (ss *SortedSet) insert(item Item) {
+	i, _ := slices.BinarySearchFunc(ss.items, item, func(a, b Item) int { return a.CompareTo(&b) })
+	ss.items = slices.Insert(ss.items, i, item)
+}
+
 func (ss *SortedSet) GetScore(member string) (float64, bool) {
+	if ss.Tree == nil {
+		if i := ss.index(member); i >= 0 {
+			return ss.items[i].Score, true
+		}
+		return 0, false
+	}
 	return ss.Tree.Score(member)
 }
 
+// GetRank returns the rank of member, -1 when missing
 func (ss *SortedSet) GetRank(member string) int {
+	if ss.Tree == nil {
+		return ss.index(member)
+	}
 	return ss.Tree.GetRank(member)
-}
\ No newline at end of file
+}
+
+func (ss *SortedSet) Len() int {
+	if ss.Tree == nil {
+		return len(ss.items)
+	}
+	return ss.Tree.Len()
+}
+
+// IncrBy adds incr to the score of member, 0 when missing, and returns the
+// new score
+func (ss *SortedSet) IncrBy(incr float64, member string) float64 {
+	score, _ := ss.GetScore(member)
+	score += incr
+	ss.Add(score, member)
+	return score
+}
+
+// Remove deletes member and reports whether it was in the set
+func (ss *SortedSet) Remove(member string) bool {
+	if ss.Tree == nil {
+		i := ss.index(member)
+		if i >= 0 {
+			ss.items = slices.Delete(ss.items, i, i+1)
+		}
+		return i >= 0
+	}
+	return ss.Tree.Remove(member)
+}
+
+// RemoveRange deletes the items ranked start to end-1 and returns their
+// number. 0 <= start <= end <= Len().
+func (ss *SortedSet) RemoveRange(start, end int) int {
+	if ss.Tree == nil {
+		ss.items = slices.Delete(ss.items, start, end)
+		return end - start
+	}
+	items := ss.Tree.Items(start, end)
+	for _, item := range items {
+		ss.Tree.Remove(item.Member)
+	}
+	return len(items)
+}
+
+// Items returns the items ranked start to end-1, lowest score first.
+// 0 <= start <= end <= Len().
+func (ss *SortedSet) Items(start, end int) []Item {
+	if ss.Tree == nil {
+		return slices.Clone(ss.items[start:end])
+	}
+	items := ss.Tree.Items(start, end)
+	res := make([]Item, len(items))
+	for i, item := range items {
+		res[i] = *item
+	}
+	return res
+}
+
+// countWhile returns the number of leading items, in order, for which pred
+// holds
+func (ss *SortedSet) countWhile(pred func(item *Item) bool) int {
+	if ss.Tree == nil {
+		n, _ := slices.BinarySearchFunc(ss.items, true, func(item Item, _ bool) int {
+			if pred(&item) {
+				return -1
+			}
+			return 1
+		})
+		return n
+	}
+	return ss.Tree.countWhile(pred)
+}
+
+// ScoreRange returns the ranks [start, end) of the items whose score is
+// between lo and hi
+func (ss *SortedSet) ScoreRange(lo, hi ScoreBound) (int, int) {
+	start := ss.countWhile(func(item *Item) bool {
+		return item.Score < lo.Score || (lo.Exclusive && item.Score == lo.Score)
+	})
+	end := ss.countWhile(func(item *Item) bool {
+		return item.Score < hi.Score || (!hi.Exclusive && item.Score == hi.Score)
+	})
+	return start, max(start, end)
+}
+
+// LexRange returns the ranks [start, end) of the members between lo and hi.
+// Like in Redis, the result is only meaningful when every member has the
+// same score.
+func (ss *SortedSet) LexRange(lo, hi LexBound) (int, int) {
+	start := ss.countWhile(func(item *Item) bool {
+		return lo.before(item.Member)
+	})
+	end := ss.countWhile(func(item *Item) bool {
+		return !hi.after(item.Member)
+	})
+	return start, max(start, end)
+}
+
+// before reports whether member sorts before the range starting at b
+func (b LexBound) before(member string) bool {
+	switch {
+	case b.Inf != 0:
+		return b.Inf > 0
+	case b.Exclusive:
+		return member <= b.Member
+	default:
+		return member < b.Member
+	}
+}
+
+// after reports whether member sorts after the range ending at b
+func (b LexBound) after(member string) bool {
+	switch {
+	case b.Inf != 0:
+		return b.Inf < 0
+	case b.Exclusive:
+		return member >= b.Member
+	default:
+		return member > b.Member
+	}
+}
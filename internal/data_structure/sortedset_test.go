@@ -1,8 +1,14 @@
 package data_structure
 
 import (
-	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/config"
 )
 
 func TestZSet_GetRank(t *testing.T) {
@@ -55,4 +61,136 @@ func TestZSet_GetRank(t *testing.T) {
 	score, _ = ss.GetScore("k8")
 	assert.EqualValues(t, 7, rank)
 	assert.EqualValues(t, 80.0, score)
-}
\ No newline at end of file
+}
+func TestZSet_ItemsAndRanges(t *testing.T) {
+	ss := NewSortedSet(3)
+	for i, m := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		ss.Add(float64(i/2), m) // 0 0 1 1 2 2 3 3
+	}
+	assert.EqualValues(t, 8, ss.Len())
+	assert.EqualValues(t, []Item{{1, "c"}, {1, "d"}, {2, "e"}}, ss.Items(2, 5))
+	assert.Empty(t, ss.Items(3, 3))
+
+	start, end := ss.ScoreRange(ScoreBound{Score: 1}, ScoreBound{Score: 2})
+	assert.EqualValues(t, []int{2, 6}, []int{start, end})
+	start, end = ss.ScoreRange(ScoreBound{Score: 1, Exclusive: true}, ScoreBound{Score: 3, Exclusive: true})
+	assert.EqualValues(t, []int{4, 6}, []int{start, end})
+	start, end = ss.ScoreRange(ScoreBound{Score: 5}, ScoreBound{Score: 1})
+	assert.Equal(t, start, end)
+
+	lex := NewSortedSet(3)
+	for _, m := range []string{"a", "b", "c", "d", "e", "f"} {
+		lex.Add(0, m)
+	}
+	start, end = lex.LexRange(LexBound{Inf: -1}, LexBound{Member: "c"})
+	assert.EqualValues(t, []int{0, 3}, []int{start, end})
+	start, end = lex.LexRange(LexBound{Member: "b", Exclusive: true}, LexBound{Member: "e", Exclusive: true})
+	assert.EqualValues(t, []int{2, 4}, []int{start, end})
+	start, end = lex.LexRange(LexBound{Member: "e"}, LexBound{Inf: 1})
+	assert.EqualValues(t, []int{4, 6}, []int{start, end})
+}
+
+func TestZSet_IncrByRemove(t *testing.T) {
+	ss := NewSortedSet(3)
+	assert.EqualValues(t, 2.5, ss.IncrBy(2.5, "a"))
+	assert.EqualValues(t, 1.5, ss.IncrBy(-1, "a"))
+	ss.Add(1, "b")
+	assert.EqualValues(t, 1, ss.GetRank("a"))
+	assert.True(t, ss.Remove("b"))
+	assert.False(t, ss.Remove("b"))
+	assert.EqualValues(t, 0, ss.GetRank("a"))
+	assert.EqualValues(t, 1, ss.Len())
+}
+
+// TestZSet_OrderWithTies checks that members sharing a score stay ordered
+// and reachable once they span several leaves, with updates moving them
+func TestZSet_OrderWithTies(t *testing.T) {
+	ss := NewSortedSet(3)
+	scores := make(map[string]float64)
+	for i := 0; i < 500; i++ {
+		member := strconv.Itoa(rand.Intn(100))
+		score := float64(rand.Intn(5))
+		ss.Add(score, member)
+		scores[member] = score
+	}
+	expected := make([]Item, 0, len(scores))
+	for member, score := range scores {
+		expected = append(expected, Item{Score: score, Member: member})
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i].CompareTo(&expected[j]) < 0 })
+	assert.EqualValues(t, expected, ss.Items(0, ss.Len()))
+	for rank, item := range expected {
+		assert.EqualValues(t, rank, ss.GetRank(item.Member))
+	}
+}
+
+func TestZSet_RemoveRange(t *testing.T) {
+	ss := NewSortedSet(3)
+	for i, m := range []string{"a", "b", "c", "d", "e", "f"} {
+		ss.Add(float64(i), m)
+	}
+	start, end := ss.ScoreRange(ScoreBound{Score: 1}, ScoreBound{Score: 3})
+	assert.EqualValues(t, 3, ss.RemoveRange(start, end))
+	assert.EqualValues(t, []Item{{0, "a"}, {4, "e"}, {5, "f"}}, ss.Items(0, ss.Len()))
+	assert.EqualValues(t, 0, ss.RemoveRange(1, 1))
+}
+
+func TestZSet_ConvertOnEntries(t *testing.T) {
+	ss := NewSortedSet(3)
+	for i := 0; i < config.ZSetMaxListpackEntries; i++ {
+		ss.Add(float64(-i), strconv.Itoa(i))
+	}
+	assert.EqualValues(t, EncodingListpack, ss.Encoding())
+	assert.EqualValues(t, AddUpdated, ss.Add(1, "0"))
+	assert.EqualValues(t, AddNew, ss.Add(0, "one more"))
+	assert.EqualValues(t, EncodingSkiplist, ss.Encoding())
+	assert.EqualValues(t, config.ZSetMaxListpackEntries+1, ss.Len())
+	assert.EqualValues(t, config.ZSetMaxListpackEntries, ss.GetRank("0"))
+	assert.EqualValues(t, 0, ss.GetRank(strconv.Itoa(config.ZSetMaxListpackEntries-1)))
+}
+
+func TestZSet_ConvertOnValueLength(t *testing.T) {
+	ss := NewSortedSet(3)
+	ss.Add(1, "a")
+	long := strings.Repeat("x", config.ZSetMaxListpackValue+1)
+	ss.Add(0, long)
+	assert.EqualValues(t, EncodingSkiplist, ss.Encoding())
+	assert.EqualValues(t, []Item{{0, long}, {1, "a"}}, ss.Items(0, 2))
+}
+
+// TestZSet_Encodings checks that a compact sorted set and one held in a
+// BPlusTree give the same answers
+func TestZSet_Encodings(t *testing.T) {
+	entries := config.ZSetMaxListpackEntries
+	t.Cleanup(func() { config.ZSetMaxListpackEntries = entries })
+	// a sorted set never converts back once it moved to a tree
+	config.ZSetMaxListpackEntries = 0
+	tree := NewSortedSet(3)
+	tree.Add(0, "x")
+	tree.Remove("x")
+	config.ZSetMaxListpackEntries = 1 << 30
+	compact := NewSortedSet(3)
+
+	for i := 0; i < 2000; i++ {
+		member := strconv.Itoa(rand.Intn(50))
+		score := float64(rand.Intn(10))
+		switch rand.Intn(4) {
+		case 0, 1:
+			assert.EqualValues(t, tree.Add(score, member), compact.Add(score, member))
+		case 2:
+			assert.EqualValues(t, tree.Remove(member), compact.Remove(member))
+		case 3:
+			lo, hi := ScoreBound{Score: score}, ScoreBound{Score: score + 2, Exclusive: true}
+			start, end := tree.ScoreRange(lo, hi)
+			compactStart, compactEnd := compact.ScoreRange(lo, hi)
+			assert.EqualValues(t, []int{start, end}, []int{compactStart, compactEnd})
+			if rand.Intn(10) == 0 {
+				assert.EqualValues(t, tree.RemoveRange(start, end), compact.RemoveRange(start, end))
+			}
+		}
+		assert.EqualValues(t, tree.GetRank(member), compact.GetRank(member))
+	}
+	assert.EqualValues(t, EncodingSkiplist, tree.Encoding())
+	assert.EqualValues(t, EncodingListpack, compact.Encoding())
+	assert.EqualValues(t, tree.Items(0, tree.Len()), compact.Items(0, compact.Len()))
+}
@@ -1,8 +1,7 @@
 package data_structure
 
 type KeySpaceStat struct {
-	Key    int64
-	Expire int64
+	Key     int64
+	Expire  int64
+	Expired int64 // keys deleted so far because their TTL elapsed
 }
-
-var HashKeySpaceStat = KeySpaceStat{Key: 0, Expire: 0}
\ No newline at end of file
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"errors"
 	"io"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"goredis-lite/internal/constant"
@@ -17,7 +19,15 @@ type IOHandler struct {
 	ioMultiplexer io_multiplexing.IOMultiplexer
 	mu            sync.Mutex
 	server        *Server
-	conns         map[int]net.Conn // map from fd -> connection
+	conns         map[int]net.Conn     // map from fd -> connection
+	clients       map[int]*core.Client // map from fd -> connection state
+
+	// clients waiting for a blocking command, only touched by Run
+	blocked *core.BlockedClients
+	// wakeFds is a pipe whose read end is monitored, so that a worker
+	// serving a blocked client can interrupt Wait
+	wakeFds [2]int
+	woken   int32
 }
 
 func NewIOHandler(id int, server *Server) (*IOHandler, error) {
@@ -26,12 +36,60 @@ func NewIOHandler(id int, server *Server) (*IOHandler, error) {
 		return nil, err
 	}
 
-	return &IOHandler{
+	h := &IOHandler{
 		id:            id,
 		ioMultiplexer: multiplexer,
 		server:        server,
 		conns:         make(map[int]net.Conn), // map from fd to corresponding connection
-	}, nil
+		clients:       make(map[int]*core.Client),
+	}
+	h.blocked = core.NewBlockedClients(server.execute, h.wake)
+	if err := syscall.Pipe(h.wakeFds[:]); err != nil {
+		return nil, err
+	}
+	for _, fd := range h.wakeFds {
+		if err := syscall.SetNonblock(fd, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := multiplexer.Monitor(io_multiplexing.Event{Fd: h.wakeFds[0], Op: io_multiplexing.OpRead}); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// wake interrupts Wait, so that the blocked clients served by a worker get
+// their reply without waiting for IOMultiplexerTimeout. It is called by
+// workers.
+func (h *IOHandler) wake() {
+	if !atomic.CompareAndSwapInt32(&h.woken, 0, 1) {
+		return
+	}
+	syscall.Write(h.wakeFds[1], []byte{0})
+}
+
+// drainWake empties the wake pipe, then allows the next wake
+func (h *IOHandler) drainWake() {
+	buf := make([]byte, 64)
+	for {
+		if n, err := syscall.Read(h.wakeFds[0], buf); n <= 0 || err != nil {
+			break
+		}
+	}
+	atomic.StoreInt32(&h.woken, 0)
+}
+
+// unblock replies to the blocked clients served by a worker or whose
+// timeout elapsed
+func (h *IOHandler) unblock() {
+	h.blocked.Unblock(func(fd int, out []byte) {
+		h.mu.Lock()
+		conn, ok := h.conns[fd]
+		h.mu.Unlock()
+		if ok {
+			conn.Write(out)
+		}
+	})
 }
 
 // Add connection to the handler's epoll monitoring list
@@ -51,6 +109,7 @@ func (h *IOHandler) AddConn(conn net.Conn) error {
 		log.Printf("I/O Handler %d is monitoring fd %d", h.id, connFd)
 		// Store the connection object so it's not garbage collected
 		h.conns[connFd] = conn
+		h.clients[connFd] = core.NewClient()
 		// Add to epoll
 		h.ioMultiplexer.Monitor(io_multiplexing.Event{
 			Fd: connFd,
@@ -68,7 +127,9 @@ func (h *IOHandler) closeConn(fd int) {
 	if conn, ok := h.conns[fd]; ok {
 		conn.Close()
 		delete(h.conns, fd)
+		delete(h.clients, fd)
 	}
+	h.blocked.Remove(fd)
 }
 
 func (h *IOHandler) Run() {
@@ -82,34 +143,40 @@ func (h *IOHandler) Run() {
 
 		for _, event := range events {
 			connFd := event.Fd
+			if connFd == h.wakeFds[0] {
+				h.drainWake()
+				continue
+			}
 			h.mu.Lock()
 			conn, ok := h.conns[connFd]
+			client := h.clients[connFd]
 			h.mu.Unlock()
 			if !ok {
 				// Connection might have been closed by a concurrent write error
 				continue
 			}
-			// cmd, err := readCommand(connFd)
-			cmd, err := readCommandConn(conn)
+			cmds, err := readCommandsConn(conn, client.Reader)
+
+			// Pipelined commands are executed in order, on the Worker(s)
+			// owning their keys, and their replies are written back with a
+			// single write. Those following a blocking command wait with it.
+			out := h.blocked.Run(connFd, client, cmds)
+			if errors.Is(err, core.ErrProtocol) {
+				out = append(out, core.Encode(err, false)...)
+			}
+			if len(out) > 0 {
+				conn.Write(out)
+			}
+
 			if err != nil {
-				if err == io.EOF || err == syscall.ECONNRESET {
+				if err == io.EOF || err == syscall.ECONNRESET || errors.Is(err, core.ErrProtocol) {
 					// log.Printf("Client disconnected (fd: %d)", connFd)
 				} else {
 					log.Printf("Read error on fd %d: %v", connFd, err)
 				}
 				h.closeConn(connFd) // <-- Use our new closing function
-				continue
-			}
-
-			replyCh := make(chan []byte, 1)
-			task := &core.Task{
-				Command: cmd,
-				ReplyCh: replyCh,
 			}
-			// dispatch the command to the corresponding Worker
-			h.server.dispatch(task)
-			res := <-replyCh
-			conn.Write(res)
 		}
+		h.unblock()
 	}
 }
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"goredis-lite/internal/core"
+)
+
+// partitionRequest is the part of a multi-key command sent to one worker
+type partitionRequest struct {
+	workerID int
+	cmd      *core.Command
+	keys     []int // positions, among all the keys of the command, of the keys sent
+	replyCh  chan []byte
+}
+
+// execute runs cmd for client and returns the reply. A command whose keys may
+// live on several workers is split by partition, fanned out to the owning
+// workers and their replies are merged according to the command's tips.
+// A read-only command whose keys live on several workers runs on copies of
+// them. Any other command fails with CROSSSLOT unless its keys share a
+// worker. Commands flagged FlagGlobal run on the calling goroutine.
+func (s *Server) execute(cmd *core.Command, client *core.Client) []byte {
+	spec, errRes := core.LookupCommand(cmd)
+	if errRes == nil {
+		if spec.Flags&core.FlagGlobal != 0 {
+			return core.ExecuteCommand(nil, cmd, client)
+		}
+		switch spec.RequestPolicy() {
+		case core.RequestPolicyMultiShard:
+			return s.scatterGather(spec, cmd, client)
+		case core.RequestPolicyAllShards:
+			return s.broadcast(spec, cmd, client)
+		}
+		if !s.samePartition(spec, cmd) {
+			// a worker only writes, or waits for, the keys it owns
+			if spec.Flags&core.FlagReadonly == 0 {
+				return core.Encode(errCrossSlot, false)
+			}
+			return s.gatherKeys(s.keysByWorker(spec, cmd), cmd, client)
+		}
+	}
+	replyCh := make(chan []byte, 1)
+	s.dispatch(&core.Task{Command: cmd, Client: client, ReplyCh: replyCh})
+	return <-replyCh
+}
+
+var errCrossSlot = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+
+// samePartition reports whether every key of cmd lives on one worker
+func (s *Server) samePartition(spec *core.CommandSpec, cmd *core.Command) bool {
+	indexes := spec.KeyIndexes(cmd.Args)
+	for _, idx := range indexes[min(len(indexes), 1):] {
+		if s.getPartitionID(cmd.Args[idx]) != s.getPartitionID(cmd.Args[indexes[0]]) {
+			return false
+		}
+	}
+	return true
+}
+
+// keysByWorker groups the keys of cmd by the worker owning them
+func (s *Server) keysByWorker(spec *core.CommandSpec, cmd *core.Command) map[int][]string {
+	byWorker := make(map[int][]string)
+	for _, idx := range spec.KeyIndexes(cmd.Args) {
+		workerID := s.getPartitionID(cmd.Args[idx])
+		byWorker[workerID] = append(byWorker[workerID], cmd.Args[idx])
+	}
+	return byWorker
+}
+
+// gatherKeys runs cmd, a read-only command like SUNION, on copies of its
+// keys fetched from every worker owning some. Unlike a split command, it
+// needs all of them at once, e.g. to intersect sets.
+func (s *Server) gatherKeys(byWorker map[int][]string, cmd *core.Command, client *core.Client) []byte {
+	dumps := make([][]byte, 0, len(byWorker))
+	dumpCh := make(chan []byte, len(byWorker))
+	for workerID, keys := range byWorker {
+		go s.workers[workerID].Do(func(st *core.Storage) {
+			dumpCh <- st.DumpKeys(keys)
+		})
+	}
+	for range byWorker {
+		dumps = append(dumps, <-dumpCh)
+	}
+	return core.ExecuteOnDumps(dumps, cmd, client)
+}
+
+func (s *Server) scatterGather(spec *core.CommandSpec, cmd *core.Command, client *core.Client) []byte {
+	indexes := spec.KeyIndexes(cmd.Args)
+	if len(indexes) == 0 {
+		return core.Encode(fmt.Errorf("ERR wrong number of arguments for '%s' command", spec.Name), false)
+	}
+	// arguments before the first key and after the last key go to every worker
+	first, last := indexes[0], indexes[len(indexes)-1]+spec.Step
+	if last > len(cmd.Args) {
+		return core.Encode(fmt.Errorf("ERR wrong number of arguments for '%s' command", spec.Name), false)
+	}
+	prefix, suffix := cmd.Args[:first], cmd.Args[last:]
+
+	// group the keys, and the arguments following each of them, by partition
+	var requests []*partitionRequest
+	byWorker := make(map[int]*partitionRequest)
+	for n, idx := range indexes {
+		workerID := s.getPartitionID(cmd.Args[idx])
+		req, ok := byWorker[workerID]
+		if !ok {
+			req = &partitionRequest{
+				workerID: workerID,
+				cmd:      &core.Command{Cmd: cmd.Cmd, Args: append([]string{}, prefix...)},
+			}
+			byWorker[workerID] = req
+			requests = append(requests, req)
+		}
+		req.cmd.Args = append(req.cmd.Args, cmd.Args[idx:idx+spec.Step]...)
+		req.keys = append(req.keys, n)
+	}
+
+	// every key is on the same worker, nothing to merge
+	if len(requests) == 1 {
+		replyCh := make(chan []byte, 1)
+		s.workers[requests[0].workerID].TaskCh <- &core.Task{Command: cmd, Client: client, ReplyCh: replyCh}
+		return <-replyCh
+	}
+
+	// scatter
+	for _, req := range requests {
+		req.cmd.Args = append(req.cmd.Args, suffix...)
+		req.replyCh = make(chan []byte, 1)
+		s.workers[req.workerID].TaskCh <- &core.Task{Command: req.cmd, Client: client, ReplyCh: req.replyCh}
+	}
+	// gather
+	replies := make([][]byte, len(requests))
+	for i, req := range requests {
+		replies[i] = <-req.replyCh
+	}
+	return mergeReplies(spec.ResponsePolicy(), requests, replies, len(indexes), client)
+}
+
+// broadcast runs cmd on every worker, replies are merged in worker order
+func (s *Server) broadcast(spec *core.CommandSpec, cmd *core.Command, client *core.Client) []byte {
+	requests := make([]*partitionRequest, s.numWorkers)
+	for i := range s.workers {
+		requests[i] = &partitionRequest{workerID: i, cmd: cmd, replyCh: make(chan []byte, 1)}
+		s.workers[i].TaskCh <- &core.Task{Command: cmd, Client: client, ReplyCh: requests[i].replyCh}
+	}
+	replies := make([][]byte, len(requests))
+	for i, req := range requests {
+		replies[i] = <-req.replyCh
+	}
+	if len(replies) == 1 {
+		return replies[0]
+	}
+	if spec.ResponsePolicy() == core.ResponsePolicySpecial && spec.Name == "info" {
+		return mergeInfo(replies, client)
+	}
+	return mergeReplies(spec.ResponsePolicy(), requests, replies, 0, client)
+}
+
+func isErrorReply(reply []byte) bool {
+	return len(reply) > 0 && reply[0] == '-'
+}
+
+// mergeReplies combines the replies of the partitions into the reply the
+// client would have got from a single server
+func mergeReplies(policy string, requests []*partitionRequest, replies [][]byte, numKeys int, client *core.Client) []byte {
+	for _, reply := range replies {
+		if isErrorReply(reply) {
+			return reply
+		}
+	}
+
+	switch policy {
+	case core.ResponsePolicyAggSum:
+		var sum int64
+		for _, reply := range replies {
+			value, err := core.Decode(reply)
+			n, ok := value.(int64)
+			if err != nil || !ok {
+				return core.Encode(errors.New("ERR unexpected reply from partition"), false)
+			}
+			sum += n
+		}
+		return core.Encode(sum, false)
+	case core.ResponsePolicyAllSucceeded:
+		return replies[0]
+	case core.ResponsePolicyOrderedByKeys:
+		res := make([]interface{}, numKeys)
+		for i, reply := range replies {
+			value, err := core.Decode(reply)
+			elems, ok := value.([]interface{})
+			if err != nil || !ok || len(elems) != len(requests[i].keys) {
+				return core.Encode(errors.New("ERR unexpected reply from partition"), false)
+			}
+			for j, n := range requests[i].keys {
+				res[n] = elems[j]
+			}
+		}
+		return core.EncodeWithProto(res, false, client.Proto)
+	}
+	return core.Encode(fmt.Errorf("ERR unsupported response policy '%s'", policy), false)
+}
+
+// mergeInfo adds up the numeric fields of the INFO replies of every worker,
+// then lists the keyspace and expiration stats of each worker on its own line
+func mergeInfo(replies [][]byte, client *core.Client) []byte {
+	var order []string // section headers and field names, in output order
+	merged := make(map[string]string)
+	workers := make([]string, len(replies))
+	for i, reply := range replies {
+		if isErrorReply(reply) {
+			return reply
+		}
+		value, _ := core.Decode(reply)
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case core.VerbatimString:
+			text = v.Text
+		}
+
+		fields := make(map[string]string)
+		for _, line := range strings.Split(text, "\r\n") {
+			if strings.HasPrefix(line, "#") {
+				if i == 0 {
+					order = append(order, line)
+				}
+				continue
+			}
+			name, value, found := strings.Cut(line, ":")
+			if !found {
+				continue
+			}
+			fields[name] = value
+			if prev, exist := merged[name]; exist {
+				merged[name] = sumInfoValues(prev, value)
+			} else {
+				merged[name] = value
+				order = append(order, name)
+			}
+		}
+		keyspace := parseInfoPairs(fields["db0"])
+		workers[i] = fmt.Sprintf("worker%d:keys=%s,expires=%s,expired_keys=%s",
+			i, keyspace["keys"], keyspace["expires"], fields["expired_keys"])
+	}
+
+	buf := &strings.Builder{}
+	for i, entry := range order {
+		if strings.HasPrefix(entry, "#") {
+			if i > 0 {
+				buf.WriteString("\r\n")
+			}
+			buf.WriteString(entry + "\r\n")
+			continue
+		}
+		buf.WriteString(entry + ":" + merged[entry] + "\r\n")
+	}
+	buf.WriteString("\r\n# Workers\r\n")
+	for _, line := range workers {
+		buf.WriteString(line + "\r\n")
+	}
+	return core.EncodeWithProto(core.VerbatimString{Format: "txt", Text: buf.String()}, false, client.Proto)
+}
+
+// sumInfoValues adds two INFO values, either plain integers or
+// comma separated key=integer pairs. Other values are taken from a.
+func sumInfoValues(a, b string) string {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA == nil && errB == nil {
+		return strconv.FormatInt(x+y, 10)
+	}
+	if !strings.Contains(a, "=") {
+		return a
+	}
+	pairsB := parseInfoPairs(b)
+	var res []string
+	for _, pair := range strings.Split(a, ",") {
+		k, v, _ := strings.Cut(pair, "=")
+		res = append(res, k+"="+sumInfoValues(v, pairsB[k]))
+	}
+	return strings.Join(res, ",")
+}
+
+// keys=1,expires=0 => {"keys": "1", "expires": "0"}
+func parseInfoPairs(value string) map[string]string {
+	pairs := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if k, v, found := strings.Cut(pair, "="); found {
+			pairs[k] = v
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"goredis-lite/internal/core"
+)
+
+const crossSlot = "-CROSSSLOT Keys in request don't hash to the same slot\r\n"
+
+// newTestServer returns a server of n workers, without I/O handlers
+func newTestServer(n int) *Server {
+	s := &Server{workers: make([]*core.Worker, n), numWorkers: n}
+	for i := range s.workers {
+		s.workers[i] = core.NewWorker(i, 16)
+	}
+	return s
+}
+
+func run(s *Server, c *core.Client, args ...string) string {
+	return string(s.execute(&core.Command{Cmd: args[0], Args: args[1:]}, c))
+}
+
+// keyOn returns the first of prefix0, prefix1... owned by worker
+func keyOn(s *Server, worker int, prefix string) string {
+	for i := 0; ; i++ {
+		if key := prefix + strconv.Itoa(i); s.getPartitionID(key) == worker {
+			return key
+		}
+	}
+}
+
+func TestExecuteAcrossWorkers(t *testing.T) {
+	s := newTestServer(4)
+	c := core.NewClient()
+	a, b, sameAsA := keyOn(s, 0, "a"), keyOn(s, 1, "b"), keyOn(s, 0, "c")
+
+	// split by worker
+	run(s, c, "SADD", a, "x")
+	run(s, c, "SADD", b, "y")
+	assert.Equal(t, ":2\r\n", run(s, c, "EXISTS", a, b))
+	// gathered from every worker
+	assert.Equal(t, "*2\r\n$1\r\nx\r\n$1\r\ny\r\n", run(s, c, "SUNION", a, b))
+
+	// written where the keys are, or not at all
+	assert.Equal(t, crossSlot, run(s, c, "SMOVE", a, b, "x"))
+	assert.Equal(t, ":1\r\n", run(s, c, "SISMEMBER", a, "x"))
+	assert.Equal(t, ":1\r\n", run(s, c, "SMOVE", a, sameAsA, "x"))
+	assert.Equal(t, ":1\r\n", run(s, c, "SISMEMBER", sameAsA, "x"))
+	assert.Equal(t, ":2\r\n", run(s, c, "DEL", sameAsA, b))
+}
+
+func TestHashTag(t *testing.T) {
+	assert.Equal(t, "user1", hashTag("{user1}.queue"))
+	assert.Equal(t, "user1", hashTag("x{user1}{y}"))
+	assert.Equal(t, "{}.queue", hashTag("{}.queue"))
+	assert.Equal(t, "{user1", hashTag("{user1"))
+	assert.Equal(t, "queue", hashTag("queue"))
+}
+
+func TestListsAcrossWorkers(t *testing.T) {
+	s := newTestServer(4)
+	c := core.NewClient()
+	a, b := keyOn(s, 0, "a"), keyOn(s, 1, "b")
+
+	run(s, c, "RPUSH", a, "x", "y")
+	assert.Equal(t, crossSlot, run(s, c, "LMOVE", a, b, "LEFT", "LEFT"))
+	assert.Equal(t, crossSlot, run(s, c, "BLMOVE", a, b, "LEFT", "LEFT", "0"))
+	assert.Equal(t, crossSlot, run(s, c, "BLPOP", b, a, "0"))
+	assert.Equal(t, crossSlot, run(s, c, "BRPOP", b, a, "0"))
+	assert.Equal(t, ":2\r\n", run(s, c, "LLEN", a))
+
+	// hash tags put both lists on one worker
+	assert.Equal(t, ":2\r\n", run(s, c, "RPUSH", "{q}a", "x", "y"))
+	assert.Equal(t, "$1\r\nx\r\n", run(s, c, "LMOVE", "{q}a", "{q}b", "LEFT", "LEFT"))
+	assert.Equal(t, "*2\r\n$4\r\n{q}b\r\n$1\r\nx\r\n", run(s, c, "BLPOP", "{q}b", "{q}a", "0"))
+}
+
+func TestMSETNXAcrossWorkers(t *testing.T) {
+	s := newTestServer(4)
+	c := core.NewClient()
+	a, b := keyOn(s, 0, "a"), keyOn(s, 1, "b")
+
+	assert.Equal(t, crossSlot, run(s, c, "MSETNX", a, "1", b, "2"))
+	assert.Equal(t, "*2\r\n$-1\r\n$-1\r\n", run(s, c, "MGET", a, b))
+	assert.Equal(t, ":1\r\n", run(s, c, "MSETNX", "{k}a", "1", "{k}b", "2"))
+	assert.Equal(t, "*2\r\n$1\r\n1\r\n$1\r\n2\r\n", run(s, c, "MGET", "{k}a", "{k}b"))
+}
+
+func TestSetStoresAcrossWorkers(t *testing.T) {
+	s := newTestServer(4)
+	c := core.NewClient()
+	a, b, dest := keyOn(s, 0, "a"), keyOn(s, 1, "b"), keyOn(s, 2, "dest")
+
+	run(s, c, "SADD", a, "x", "y")
+	run(s, c, "SADD", b, "y")
+	for _, cmd := range []string{"SUNIONSTORE", "SINTERSTORE", "SDIFFSTORE"} {
+		assert.Equal(t, crossSlot, run(s, c, cmd, dest, a, b), cmd)
+		assert.Equal(t, crossSlot, run(s, c, cmd, a, a, b), cmd)
+	}
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", dest))
+	assert.Equal(t, ":2\r\n", run(s, c, "SCARD", a))
+	assert.Equal(t, crossSlot, run(s, c, "SMOVE", b, a, "y"))
+	assert.Equal(t, ":1\r\n", run(s, c, "SCARD", b))
+
+	run(s, c, "SADD", "{s}a", "x", "y")
+	run(s, c, "SADD", "{s}b", "y")
+	assert.Equal(t, ":1\r\n", run(s, c, "SDIFFSTORE", "{s}dest", "{s}a", "{s}b"))
+	assert.Equal(t, "*1\r\n$1\r\nx\r\n", run(s, c, "SMEMBERS", "{s}dest"))
+}
+
+func TestZSetStoresAcrossWorkers(t *testing.T) {
+	s := newTestServer(4)
+	c := core.NewClient()
+	a, b, dest := keyOn(s, 0, "a"), keyOn(s, 1, "b"), keyOn(s, 2, "dest")
+
+	run(s, c, "ZADD", a, "1", "x", "2", "y")
+	run(s, c, "ZADD", b, "3", "y")
+	for _, cmd := range []string{"ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE"} {
+		assert.Equal(t, crossSlot, run(s, c, cmd, dest, "2", a, b), cmd)
+		assert.Equal(t, crossSlot, run(s, c, cmd, dest, "1", a), cmd)
+	}
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", dest))
+
+	run(s, c, "ZADD", "{z}a", "1", "x", "2", "y")
+	run(s, c, "ZADD", "{z}b", "3", "y")
+	assert.Equal(t, ":1\r\n", run(s, c, "ZINTERSTORE", "{z}dest", "2", "{z}a", "{z}b"))
+	assert.Equal(t, "*2\r\n$1\r\ny\r\n$1\r\n5\r\n", run(s, c, "ZRANGE", "{z}dest", "0", "-1", "WITHSCORES"))
+}
+
+func TestBITOPAcrossWorkers(t *testing.T) {
+	s := newTestServer(4)
+	c := core.NewClient()
+	a, b, dest := keyOn(s, 0, "a"), keyOn(s, 1, "b"), keyOn(s, 2, "dest")
+
+	run(s, c, "SET", a, "ab")
+	run(s, c, "SET", b, "c")
+	assert.Equal(t, crossSlot, run(s, c, "BITOP", "AND", dest, a, b))
+	assert.Equal(t, crossSlot, run(s, c, "BITOP", "NOT", dest, a))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", dest))
+
+	run(s, c, "SET", "{b}a", "ab")
+	assert.Equal(t, ":2\r\n", run(s, c, "BITOP", "OR", "{b}dest", "{b}a"))
+	assert.Equal(t, "$2\r\nab\r\n", run(s, c, "GET", "{b}dest"))
+}
+
+func TestPFMERGEAcrossWorkers(t *testing.T) {
+	s := newTestServer(4)
+	c := core.NewClient()
+	a, b, dest := keyOn(s, 0, "a"), keyOn(s, 1, "b"), keyOn(s, 2, "dest")
+
+	run(s, c, "PFADD", a, "x", "y")
+	run(s, c, "PFADD", b, "z")
+	assert.Equal(t, ":3\r\n", run(s, c, "PFCOUNT", a, b))
+	assert.Equal(t, crossSlot, run(s, c, "PFMERGE", dest, a, b))
+	assert.Equal(t, crossSlot, run(s, c, "PFMERGE", a, b))
+	assert.Equal(t, ":0\r\n", run(s, c, "EXISTS", dest))
+	assert.Equal(t, ":2\r\n", run(s, c, "PFCOUNT", a))
+
+	run(s, c, "PFADD", "{h}a", "x", "y")
+	run(s, c, "PFADD", "{h}b", "z")
+	assert.Equal(t, "+OK\r\n", run(s, c, "PFMERGE", "{h}dest", "{h}a", "{h}b"))
+	assert.Equal(t, ":3\r\n", run(s, c, "PFCOUNT", "{h}dest"))
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"hash/fnv"
 	"io"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -21,8 +23,10 @@ import (
 
 var serverStatus int32 = constant.ServerStatusIdle
 
-func readCommand(fd int) (*core.Command, error) {
-	buf := make([]byte, 512)
+// readCommands reads what is available on fd into the connection's reader and
+// returns every command completed by this read
+func readCommands(fd int, reader *core.RespReader) ([]*core.Command, error) {
+	buf := make([]byte, constant.ReadBufferSize)
 	n, err := syscall.Read(fd, buf)
 	if err != nil {
 		return nil, err
@@ -30,18 +34,19 @@ func readCommand(fd int) (*core.Command, error) {
 	if n == 0 {
 		return nil, io.EOF
 	}
-
-	return core.ParseCmd(buf)
+	reader.Feed(buf[:n])
+	return reader.ReadCommands()
 }
 
-func readCommandConn(conn net.Conn) (*core.Command, error) {
-	buf := make([]byte, 512)
+func readCommandsConn(conn net.Conn, reader *core.RespReader) ([]*core.Command, error) {
+	buf := make([]byte, constant.ReadBufferSize)
 	// Use the Read method from the net.Conn interface
 	n, err := conn.Read(buf)
 	if err != nil {
 		return nil, err // This will properly handle io.EOF
 	}
-	return core.ParseCmd(buf[:n])
+	reader.Feed(buf[:n])
+	return reader.ReadCommands()
 }
 
 func WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
@@ -53,6 +58,9 @@ func WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
 		if atomic.CompareAndSwapInt32(&serverStatus, constant.ServerStatusIdle, constant.ServerStatusShuttingDown) {
 			// The swap was successful! We have now claimed the shutdown state.
 			log.Println("Shutting down gracefully")
+			if err := core.PrepareForShutdown(); err != nil {
+				log.Println("error saving on shutdown:", err)
+			}
 			os.Exit(0)
 		}
 	}
@@ -76,12 +84,29 @@ func (s *Server) getNextIOHandler() int {
 	return int(atomic.AddInt64(&s.nextIOHandler, 1)-1) % s.numIOHandlers
 }
 
+// getPartitionID hashes the hash tag of key, when it has one, instead of
+// the whole key, like Redis Cluster does: "{user1}.queue" and
+// "{user1}.done" live on the same worker, so that one command can use both
 func (s *Server) getPartitionID(key string) int {
 	hasher := fnv.New32a()
-	hasher.Write([]byte(key))
+	hasher.Write([]byte(hashTag(key)))
 	return int(hasher.Sum32()) % s.numWorkers
 }
 
+// hashTag returns the part of key between the first { and the next }, or
+// key itself when that part is missing or empty
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
 // set abc 123
 // abc -> 1
 // get abc
@@ -89,10 +114,8 @@ func (s *Server) getPartitionID(key string) int {
 func (s *Server) dispatch(task *core.Task) {
 	// Commands like PING etc., don't have a key.
 	// We can send them to any worker.
-	var key string
 	var workerID int
-	if len(task.Command.Args) > 0 {
-		key = task.Command.Args[0]
+	if key, ok := firstKey(task.Command); ok {
 		workerID = s.getPartitionID(key)
 	} else {
 		workerID = rand.Intn(s.numWorkers)
@@ -101,10 +124,51 @@ func (s *Server) dispatch(task *core.Task) {
 	s.workers[workerID].TaskCh <- task
 }
 
+// firstKey returns the first key of cmd according to the command table
+func firstKey(cmd *core.Command) (string, bool) {
+	spec, errRes := core.LookupCommand(cmd)
+	if errRes != nil {
+		return "", false
+	}
+	indexes := spec.KeyIndexes(cmd.Args)
+	if len(indexes) == 0 {
+		return "", false
+	}
+	return cmd.Args[indexes[0]], true
+}
+
+// Count, PartitionOf, Run and Execute let persistence reach the worker
+// storages, see core.Partitions
+
+func (s *Server) Count() int {
+	return s.numWorkers
+}
+
+func (s *Server) PartitionOf(key string) int {
+	return s.getPartitionID(key)
+}
+
+func (s *Server) Run(id int, fn func(st *core.Storage)) {
+	s.workers[id].Do(fn)
+}
+
+func (s *Server) Execute(cmd *core.Command, client *core.Client) []byte {
+	return s.execute(cmd, client)
+}
+
+// runSaveRules starts a BGSAVE whenever one of config.SaveRules is met
+func (s *Server) runSaveRules() {
+	ticker := time.NewTicker(constant.SaveRulesCheckFrequency)
+	defer ticker.Stop()
+	for range ticker.C {
+		core.CheckSaveRules()
+	}
+}
+
 func NewServer() *Server {
 	numCores := runtime.NumCPU()
-	numIOHandlers := numCores / 2
-	numWorkers := numCores / 2
+	numIOHandlers := max(numCores/2, 1)
+	numWorkers := max(numCores/2, 1)
 	log.Printf("Initializing server with %d workers and %d io handler\n", numWorkers, numIOHandlers)
 
 	s := &Server{
@@ -125,11 +189,17 @@ func NewServer() *Server {
 		}
 		s.ioHandlers[i] = handler
 	}
+
+	core.UsePartitions(s)
+	go s.runSaveRules()
 	return s
 }
 
 func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 	defer wg.Done()
+	if err := core.LoadDataFromDisk(); err != nil {
+		log.Fatal("error loading data from disk: ", err)
+	}
 	log.Println("starting an I/O Multiplexing TCP server on", config.Port)
 	listener, err := net.Listen(config.Protocol, config.Port)
 	if err != nil {
@@ -166,6 +236,8 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 	}
 
 	events := make([]io_multiplexing.Event, config.MaxConnection)
+	clients := make(map[int]*core.Client) // per-connection state
+	blocked := core.NewBlockedClients(core.Execute, nil)
 	lastActiveExpireExecTime := time.Now()
 	for atomic.LoadInt32(&serverStatus) != constant.ServerStatusShuttingDown {
 		// Check last execution time and call if it is more than 100ms ago.
@@ -176,6 +248,8 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 				}
 			}
 			core.ActiveDeleteExpiredKeys() // Busy
+			core.CheckSaveRules()
+			core.FsyncAOF()
 			atomic.SwapInt32(&serverStatus, constant.ServerStatusIdle)
 			// Idle
 			lastActiveExpireExecTime = time.Now()
@@ -213,21 +287,37 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 					log.Fatal(err)
 				}
 			} else {
-				cmd, err := readCommand(events[i].Fd)
+				connFd := events[i].Fd
+				client, ok := clients[connFd]
+				if !ok {
+					client = core.NewClient()
+					clients[connFd] = client
+				}
+				cmds, err := readCommands(connFd, client.Reader)
+				if out := blocked.Run(connFd, client, cmds); len(out) > 0 {
+					if _, err := syscall.Write(connFd, out); err != nil {
+						log.Println("err write:", err)
+					}
+				}
 				if err != nil {
 					if err == io.EOF || err == syscall.ECONNRESET {
 						log.Println("client disconnected")
-						_ = syscall.Close(events[i].Fd)
+					} else if errors.Is(err, core.ErrProtocol) {
+						syscall.Write(connFd, core.Encode(err, false))
+					} else {
+						log.Println("read error:", err)
 						continue
 					}
-					log.Println("read error:", err)
-					continue
-				}
-				if err = core.ExecuteAndResponse(cmd, events[i].Fd); err != nil {
-					log.Println("err write:", err)
+					delete(clients, connFd)
+					blocked.Remove(connFd)
+					_ = syscall.Close(connFd)
 				}
 			}
 		}
+		// clients may have been served by the commands above
+		blocked.Unblock(func(fd int, out []byte) {
+			syscall.Write(fd, out)
+		})
 		// Idle
 		atomic.SwapInt32(&serverStatus, constant.ServerStatusIdle)
 	}